@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AsprofOptions структурированное представление аргументов asprof.
+// Неизвестные форме токены сохраняются в Extra, чтобы строка аргументов не терялась при редактировании.
+type AsprofOptions struct {
+	Event    string
+	Duration string
+	Interval string
+	Include  []string
+	Exclude  []string
+	Threads  bool
+	Cstack   string
+	Alloc    string
+	Lock     string
+	Extra    []string
+}
+
+// asprofFlag описание опции командной строки asprof
+type asprofFlag struct {
+	short      string
+	long       string
+	hasValue   bool
+	minVersion string // версия async-profiler, в которой опция появилась
+}
+
+// Опции asprof, которые понимает k8s-jprof
+var asprofFlags = []asprofFlag{
+	{short: "-e", long: "--event", hasValue: true},
+	{short: "-d", long: "--duration", hasValue: true},
+	{short: "-i", long: "--interval", hasValue: true},
+	{short: "-j", long: "--jstackdepth", hasValue: true},
+	{short: "-t", long: "--threads"},
+	{short: "-s", long: "--simple"},
+	{short: "-g", long: "--sig"},
+	{short: "-a", long: "--ann"},
+	{short: "-l", long: "--lib"},
+	{short: "-I", long: "--include", hasValue: true},
+	{short: "-X", long: "--exclude", hasValue: true},
+	{long: "--alloc", hasValue: true},
+	{long: "--live", minVersion: "2.9"},
+	{long: "--lock", hasValue: true},
+	{long: "--wall", hasValue: true, minVersion: "3.0"},
+	{long: "--nativemem", hasValue: true, minVersion: "3.0"},
+	{long: "--all-user"},
+	{long: "--sched"},
+	{long: "--cstack", hasValue: true},
+	{long: "--begin", hasValue: true},
+	{long: "--end", hasValue: true},
+	{long: "--ttsp"},
+	{long: "--jfropts", hasValue: true},
+	{long: "--jfrsync", hasValue: true},
+	{long: "--chunksize", hasValue: true},
+	{long: "--chunktime", hasValue: true},
+	{long: "--signal", hasValue: true},
+	{long: "--clock", hasValue: true},
+	{long: "--all", minVersion: "4.0"},
+}
+
+// Опции, которыми управляет само приложение
+var managedAsprofFlags = map[string]string{
+	"-f":     "output file is managed by k8s-jprof",
+	"--file": "output file is managed by k8s-jprof",
+	"-o":     "output format is chosen in \"Convert to\"",
+}
+
+// Известные события async-profiler
+var asprofEvents = []string{
+	"cpu", "alloc", "lock", "wall", "itimer", "ctimer", "nativemem",
+	"cpu-clock", "page-faults", "context-switches", "cycles", "instructions",
+	"cache-references", "cache-misses", "branch-instructions", "branch-misses",
+	"bus-cycles", "L1-dcache-load-misses", "LLC-load-misses", "dTLB-load-misses",
+}
+
+// Режимы --cstack и версии, в которых они появились
+var asprofCstackModes = map[string]string{
+	"fp":    "",
+	"dwarf": "2.6",
+	"lbr":   "",
+	"vm":    "3.0",
+	"vmx":   "3.0",
+	"no":    "",
+}
+
+var (
+	durationValuePattern = regexp.MustCompile(`^[0-9]+(ms|s|m|h)?$`)
+	intervalValuePattern = regexp.MustCompile(`^[0-9]+(ns|us|ms|s|k|K|m|M|g|G)?$`)
+	thresholdPattern     = regexp.MustCompile(`^[0-9]+(ns|us|ms|s|b|k|K|m|M|g|G)?$`)
+	javaMethodPattern    = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$<][A-Za-z0-9_$<>*]*)+$`)
+)
+
+func findAsprofFlag(name string) (asprofFlag, bool) {
+	for _, flag := range asprofFlags {
+		if name == flag.short || name == flag.long {
+			return flag, true
+		}
+	}
+	return asprofFlag{}, false
+}
+
+// splitArgs разбивает строку аргументов на токены с учетом кавычек и экранирования
+func splitArgs(s string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inToken := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inToken = true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inToken = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// quoteArg заключает токен в кавычки, если без них он не переживет splitArgs
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\r\n'\"\\$`;&|<>()*?[]{}!#~") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// ParseAsprofArgs разбирает строку аргументов asprof в структуру
+func ParseAsprofArgs(args string) (AsprofOptions, error) {
+	var opts AsprofOptions
+
+	tokens, err := splitArgs(args)
+	if err != nil {
+		return opts, err
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		name, value, hasInlineValue := strings.Cut(token, "=")
+		if !strings.HasPrefix(token, "--") {
+			name, value, hasInlineValue = token, "", false
+		}
+
+		flag, known := findAsprofFlag(name)
+		if !known || !flag.hasValue {
+			if known && flag.short == "-t" {
+				opts.Threads = true
+			} else {
+				opts.Extra = append(opts.Extra, token)
+			}
+			continue
+		}
+
+		if !hasInlineValue {
+			if i+1 >= len(tokens) {
+				return opts, fmt.Errorf("option %s requires a value", name)
+			}
+			i++
+			value = tokens[i]
+		}
+
+		switch flag.short + flag.long {
+		case "-e--event":
+			opts.Event = value
+		case "-d--duration":
+			opts.Duration = value
+		case "-i--interval":
+			opts.Interval = value
+		case "-I--include":
+			opts.Include = append(opts.Include, value)
+		case "-X--exclude":
+			opts.Exclude = append(opts.Exclude, value)
+		case "--cstack":
+			opts.Cstack = value
+		case "--alloc":
+			opts.Alloc = value
+		case "--lock":
+			opts.Lock = value
+		default:
+			opts.Extra = append(opts.Extra, name, value)
+		}
+	}
+
+	return opts, nil
+}
+
+// String собирает строку аргументов. ParseAsprofArgs(o.String()) возвращает ту же структуру
+func (o AsprofOptions) String() string {
+	argv := o.Argv()
+	for i := range argv {
+		argv[i] = quoteArg(argv[i])
+	}
+	return strings.Join(argv, " ")
+}
+
+// Argv возвращает аргументы asprof отдельными токенами для передачи в exec без shell
+func (o AsprofOptions) Argv() []string {
+	parts := []string{}
+	add := func(flag, value string) {
+		if value != "" {
+			parts = append(parts, flag, value)
+		}
+	}
+
+	add("-e", o.Event)
+	add("-d", o.Duration)
+	add("-i", o.Interval)
+	if o.Threads {
+		parts = append(parts, "-t")
+	}
+	for _, pattern := range o.Include {
+		add("-I", pattern)
+	}
+	for _, pattern := range o.Exclude {
+		add("-X", pattern)
+	}
+	add("--cstack", o.Cstack)
+	add("--alloc", o.Alloc)
+	add("--lock", o.Lock)
+	parts = append(parts, o.Extra...)
+
+	return parts
+}
+
+// asprofDefaultDuration длительность записи asprof без -d
+const asprofDefaultDuration = 60 * time.Second
+
+// RecordingDuration длительность записи из -d: число секунд или значение с единицами (ms, s, m, h)
+func (o AsprofOptions) RecordingDuration() time.Duration {
+	if o.Duration == "" || !durationValuePattern.MatchString(o.Duration) {
+		return asprofDefaultDuration
+	}
+	value := o.Duration
+	if _, err := strconv.Atoi(value); err == nil {
+		value += "s"
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return asprofDefaultDuration
+	}
+	return d
+}
+
+// Validate проверяет опции перед запуском. Ошибки блокируют запись, предупреждения - нет
+func (o AsprofOptions) Validate(version string) (errs []string, warnings []string) {
+	if o.Event != "" && !isKnownAsprofEvent(o.Event) && !javaMethodPattern.MatchString(o.Event) {
+		errs = append(errs, fmt.Sprintf("unknown event %q", o.Event))
+	}
+	if o.Event == "nativemem" && !versionAtLeast(version, "3.0") {
+		warnings = append(warnings, fmt.Sprintf("event nativemem requires async-profiler 3.0+, installed %s", version))
+	}
+
+	if o.Duration == "" {
+		warnings = append(warnings, "no duration (-d) set, asprof will record for 60 seconds")
+	} else if !durationValuePattern.MatchString(o.Duration) || strings.TrimLeft(o.Duration, "0") == "" {
+		errs = append(errs, fmt.Sprintf("invalid duration %q", o.Duration))
+	} else if _, err := strconv.Atoi(o.Duration); err != nil && !versionAtLeast(version, "3.0") {
+		warnings = append(warnings, fmt.Sprintf("duration units require async-profiler 3.0+, installed %s", version))
+	}
+
+	if o.Interval != "" && !intervalValuePattern.MatchString(o.Interval) {
+		errs = append(errs, fmt.Sprintf("invalid interval %q", o.Interval))
+	}
+	if o.Alloc != "" && !thresholdPattern.MatchString(o.Alloc) {
+		errs = append(errs, fmt.Sprintf("invalid alloc threshold %q", o.Alloc))
+	}
+	if o.Lock != "" && !thresholdPattern.MatchString(o.Lock) {
+		errs = append(errs, fmt.Sprintf("invalid lock threshold %q", o.Lock))
+	}
+
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if strings.TrimSpace(pattern) == "" {
+			errs = append(errs, "empty include/exclude pattern")
+		}
+	}
+
+	if o.Cstack != "" {
+		minVersion, known := asprofCstackModes[o.Cstack]
+		if !known {
+			errs = append(errs, fmt.Sprintf("unknown cstack mode %q", o.Cstack))
+		} else if minVersion != "" && !versionAtLeast(version, minVersion) {
+			warnings = append(warnings, fmt.Sprintf("--cstack %s requires async-profiler %s+, installed %s", o.Cstack, minVersion, version))
+		}
+	}
+
+	values := append([]string{o.Event, o.Duration, o.Interval, o.Cstack, o.Alloc, o.Lock}, o.Include...)
+	for _, value := range append(values, o.Exclude...) {
+		if hasControlChars(value) {
+			errs = append(errs, fmt.Sprintf("control characters are not allowed in %q", value))
+		}
+	}
+
+	// Прочие токены должны соответствовать грамматике asprof: опция и, если нужно, ее значение
+	for i := 0; i < len(o.Extra); i++ {
+		token := o.Extra[i]
+		if hasControlChars(token) {
+			errs = append(errs, fmt.Sprintf("control characters are not allowed in %q", token))
+			continue
+		}
+		if !strings.HasPrefix(token, "-") {
+			errs = append(errs, fmt.Sprintf("unexpected argument %q", token))
+			continue
+		}
+		name, _, hasInlineValue := strings.Cut(token, "=")
+		if reason, managed := managedAsprofFlags[name]; managed {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, reason))
+			continue
+		}
+		flag, known := findAsprofFlag(name)
+		if !known {
+			errs = append(errs, fmt.Sprintf("unknown option %s", name))
+			continue
+		}
+		if flag.hasValue && !hasInlineValue {
+			// Значение идет следующим токеном (ParseAsprofArgs гарантирует его наличие)
+			i++
+		}
+		if flag.minVersion != "" && !versionAtLeast(version, flag.minVersion) {
+			warnings = append(warnings, fmt.Sprintf("%s requires async-profiler %s+, installed %s", name, flag.minVersion, version))
+		}
+	}
+
+	return errs, warnings
+}
+
+func hasControlChars(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// asprofCommandArgv разбирает и проверяет строку аргументов и возвращает argv для asprof.
+// Никакие токены не интерпретируются shell'ом пода.
+func asprofCommandArgv(args, version string) ([]string, error) {
+	opts, err := ParseAsprofArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if errs, _ := opts.Validate(version); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return opts.Argv(), nil
+}
+
+func isKnownAsprofEvent(event string) bool {
+	for _, known := range asprofEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// versionAtLeast сравнивает версии вида "4.1". Неизвестная версия считается подходящей
+func versionAtLeast(version, minVersion string) bool {
+	parse := func(v string) []int {
+		var parts []int
+		for _, p := range strings.Split(v, ".") {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return nil
+			}
+			parts = append(parts, n)
+		}
+		return parts
+	}
+
+	have, want := parse(version), parse(minVersion)
+	if have == nil || want == nil {
+		return true
+	}
+	for i := 0; i < len(want); i++ {
+		h := 0
+		if i < len(have) {
+			h = have[i]
+		}
+		if h != want[i] {
+			return h > want[i]
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "plain", input: "-e cpu -d 30", want: []string{"-e", "cpu", "-d", "30"}},
+		{name: "extra whitespace", input: "  -e\tcpu \r\n -d 30 ", want: []string{"-e", "cpu", "-d", "30"}},
+		{name: "single quotes", input: "-I 'a b'", want: []string{"-I", "a b"}},
+		{name: "double quotes with escapes", input: `-I "say \"hi\" \\"`, want: []string{"-I", `say "hi" \`}},
+		{name: "escaped space", input: `a\ b`, want: []string{"a b"}},
+		{name: "empty quoted token", input: "''", want: []string{""}},
+		{name: "semicolon is literal", input: "-e cpu; rm -rf /", want: []string{"-e", "cpu;", "rm", "-rf", "/"}},
+		{name: "command substitution is literal", input: "-I $(reboot)", want: []string{"-I", "$(reboot)"}},
+		{name: "backticks are literal", input: "-I `id`", want: []string{"-I", "`id`"}},
+		{name: "pipes and redirects are literal", input: "a|b >c &", want: []string{"a|b", ">c", "&"}},
+		{name: "newline inside quotes is kept", input: "-I 'a\nb'", want: []string{"-I", "a\nb"}},
+		{name: "unterminated single quote", input: "-I 'abc", wantErr: true},
+		{name: "unterminated double quote", input: `-I "abc`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitArgs(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("splitArgs(%q) = %q, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitArgs(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitArgs(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteArgRoundTrip(t *testing.T) {
+	values := []string{
+		"cpu", "", "a b", "it's", `back\slash`, `"quoted"`, "a;b", "$(reboot)", "`id`",
+		"$HOME", "a|b", "a&&b", "*", "x\ny", "tab\there", "-leading-dash", "com.acme.*",
+	}
+	for _, value := range values {
+		quoted := quoteArg(value)
+		got, err := splitArgs(quoted)
+		if err != nil {
+			t.Errorf("splitArgs(quoteArg(%q) = %q): %v", value, quoted, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != value {
+			t.Errorf("splitArgs(quoteArg(%q) = %q) = %q, want one token", value, quoted, got)
+		}
+	}
+	if got := quoteArg("cpu"); got != "cpu" {
+		t.Errorf("quoteArg(%q) = %q, want it unquoted", "cpu", got)
+	}
+}
+
+func TestParseAsprofArgs(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    AsprofOptions
+		wantErr bool
+	}{
+		{input: "-e cpu -d 30", want: AsprofOptions{Event: "cpu", Duration: "30"}},
+		{input: "--event=wall --duration 1m -t", want: AsprofOptions{Event: "wall", Duration: "1m", Threads: true}},
+		{
+			input: "-e alloc --alloc=512k -I 'com.acme.*' -X '*Unsafe*' --cstack dwarf",
+			want:  AsprofOptions{Event: "alloc", Alloc: "512k", Include: []string{"com.acme.*"}, Exclude: []string{"*Unsafe*"}, Cstack: "dwarf"},
+		},
+		{input: "-e cpu --live --jfropts mem", want: AsprofOptions{Event: "cpu", Extra: []string{"--live", "--jfropts", "mem"}}},
+		{input: "-e cpu `id`", want: AsprofOptions{Event: "cpu", Extra: []string{"`id`"}}},
+		{input: "-e", wantErr: true},
+		{input: "-e 'cpu", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAsprofArgs(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseAsprofArgs(%q) = %+v, want error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAsprofArgs(%q): %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAsprofArgs(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+		// String() должен давать строку, которая разбирается в ту же структуру
+		again, err := ParseAsprofArgs(got.String())
+		if err != nil || !reflect.DeepEqual(again, got) {
+			t.Errorf("ParseAsprofArgs(%q) = %+v, %v, want %+v", got.String(), again, err, got)
+		}
+	}
+}
+
+func TestAsprofCommandArgv(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    []string
+		wantErr string
+	}{
+		{name: "plain", args: "-e cpu -d 30", want: []string{"-e", "cpu", "-d", "30"}},
+		{name: "quoted values", args: `-e "cpu" -d '30'`, want: []string{"-e", "cpu", "-d", "30"}},
+		{name: "long options", args: "--event=wall --duration=10s --interval 10ms", want: []string{"-e", "wall", "-d", "10s", "-i", "10ms"}},
+		{name: "semicolon in filter stays one token", args: "-e cpu -d 30 -I 'a;b'", want: []string{"-e", "cpu", "-d", "30", "-I", "a;b"}},
+		{name: "substitution in filter stays one token", args: "-e cpu -d 30 -I '$(reboot)'", want: []string{"-e", "cpu", "-d", "30", "-I", "$(reboot)"}},
+		{name: "backticks in filter stay one token", args: "-e cpu -d 30 -X '`id`'", want: []string{"-e", "cpu", "-d", "30", "-X", "`id`"}},
+		{name: "extra options pass through", args: "-e cpu -d 30 --live --jfropts=mem", want: []string{"-e", "cpu", "-d", "30", "--live", "--jfropts", "mem"}},
+		{name: "chained command", args: "-e cpu -d 30; rm -rf /", wantErr: "invalid duration"},
+		{name: "event with semicolon", args: "-e 'cpu;reboot' -d 30", wantErr: "unknown event"},
+		{name: "substitution in duration", args: "-e cpu -d '$(reboot)'", wantErr: "invalid duration"},
+		{name: "backticks as argument", args: "-e cpu -d 30 `id`", wantErr: "unexpected argument"},
+		{name: "newline in filter", args: "-e cpu -d 30 -I \"a\nb\"", wantErr: "control characters"},
+		{name: "NUL in filter", args: "-e cpu -d 30 -I 'a\x00b'", wantErr: "control characters"},
+		{name: "NUL in duration", args: "-e cpu -d '30\x00'", wantErr: "invalid duration"},
+		{name: "option as event value", args: "-e -d 30", wantErr: "unknown event"},
+		{name: "managed output file", args: "-e cpu -d 30 -f /tmp/x.jfr", wantErr: "output file is managed"},
+		{name: "unknown option", args: "-e cpu -d 30 --exec=id", wantErr: "unknown option --exec"},
+		{name: "missing value", args: "-e cpu -d", wantErr: "requires a value"},
+		{name: "unterminated quote", args: "-e cpu -d 30 -I 'a", wantErr: "unterminated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := asprofCommandArgv(tt.args, "4.1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("asprofCommandArgv(%q) = %q, %v, want error containing %q", tt.args, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("asprofCommandArgv(%q): %v", tt.args, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("asprofCommandArgv(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasControlChars(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"cpu", false},
+		{"", false},
+		{"a b;$(id)`x`", false},
+		{"юникод", false},
+		{"a\nb", true},
+		{"a\rb", true},
+		{"a\tb", true},
+		{"a\x00b", true},
+		{"a\x1bb", true},
+		{"a\x7fb", true},
+	}
+	for _, tt := range tests {
+		if got := hasControlChars(tt.input); got != tt.want {
+			t.Errorf("hasControlChars(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runCLI выполняет подкоманду командной строки. false - подкоманды нет, запускается UI
+func runCLI(args []string) (exitCode int, handled bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "convert":
+		return runConvertCommand(args[1:], os.Stdout, os.Stderr), true
+	case "diff":
+		return runDiffCommand(args[1:], os.Stdout, os.Stderr), true
+	case "merge":
+		return runMergeCommand(args[1:], os.Stdout, os.Stderr), true
+	case "summary":
+		return runSummaryCommand(args[1:], os.Stdout, os.Stderr), true
+	}
+	return 0, false
+}
+
+// runConvertCommand "k8s-jprof convert [options] file.jfr...": конвертация JFR с диска,
+// результаты пишутся рядом с исходными файлами
+func runConvertCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	formats := fs.String("o", "html", "output formats, comma separated: "+strings.Join(conversionFormats, ", "))
+	events := fs.String("event", "", "event types, comma separated: cpu, wall, alloc, lock (default: first found)")
+	threads := fs.Bool("threads", false, "split stacks by thread")
+	simple := fs.Bool("simple", false, "simple class names without package")
+	dotted := fs.Bool("dot", true, "dotted class names: java.lang.String instead of java/lang/String")
+	include := fs.String("include", "", "regexp: keep only stacks with a matching frame")
+	exclude := fs.String("exclude", "", "regexp: drop stacks with a matching frame")
+	from := fs.String("from", "", "start of the time window from recording start, e.g. 10s")
+	to := fs.String("to", "", "end of the time window from recording start, e.g. 1m30s")
+	title := fs.String("title", "", "flame graph title")
+	java := fs.Bool("java", false, "convert with jfr-converter.jar even when a format is supported natively")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: k8s-jprof convert [options] file.jfr...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var selected []string
+	for _, f := range strings.Split(*formats, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		known := false
+		for _, c := range conversionFormats {
+			known = known || c == f
+		}
+		if !known {
+			fmt.Fprintf(stderr, "Error: unknown format %q\n", f)
+			return 2
+		}
+		selected = append(selected, f)
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(stderr, "Error: no output formats")
+		return 2
+	}
+
+	opts := ConvertOptions{Threads: *threads, Simple: *simple, Dotted: *dotted, Include: *include, Exclude: *exclude, Title: *title}
+	for _, e := range strings.Split(*events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			opts.Events = append(opts.Events, e)
+		}
+	}
+	var err error
+	if opts.From, err = parseTimeOffset(*from); err != nil {
+		fmt.Fprintf(stderr, "Error: invalid -from: %v\n", err)
+		return 2
+	}
+	if opts.To, err = parseTimeOffset(*to); err != nil {
+		fmt.Fprintf(stderr, "Error: invalid -to: %v\n", err)
+		return 2
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	backend := loadConverterBackend()
+	if *java {
+		backend = converterJava
+	}
+	timeout := loadOperationTimeouts().Conversion
+
+	exitCode := 0
+	for _, path := range fs.Args() {
+		results, err := convertFile(context.Background(), path, selected, opts, backend, timeout)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %s: %v\n", path, err)
+			exitCode = 1
+			continue
+		}
+		for _, r := range results {
+			if r.err != nil {
+				fmt.Fprintf(stderr, "Error: %s: %s failed: %v\n", path, r.label(), r.err)
+				exitCode = 1
+				continue
+			}
+			fmt.Fprintln(stdout, r.path)
+		}
+	}
+	return exitCode
+}
+
+// runDiffCommand "k8s-jprof diff [options] before.jfr after.jfr": дифференциальный flame graph
+// и таблица фреймов с наибольшим изменением
+func runDiffCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "output HTML file (default: after-diff-before.html next to the after file)")
+	event := fs.String("event", "", "event type: cpu, wall, alloc, lock (default: first found)")
+	threads := fs.Bool("threads", false, "split stacks by thread")
+	include := fs.String("include", "", "regexp: keep only stacks with a matching frame")
+	exclude := fs.String("exclude", "", "regexp: drop stacks with a matching frame")
+	top := fs.Int("top", diffTopFrames, "number of frames in the change tables")
+	title := fs.String("title", "", "flame graph title")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: k8s-jprof diff [options] before.jfr after.jfr")
+		fmt.Fprintln(stderr, "Files may also be .jfr.gz or collapsed stacks (.collapsed, .txt).")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := ConvertOptions{Threads: *threads, Dotted: true, Include: *include, Exclude: *exclude, Title: *title}
+	if e := strings.TrimSpace(*event); e != "" {
+		opts.Events = []string{e}
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	before, after := fs.Arg(0), fs.Arg(1)
+	path := *output
+	if path == "" {
+		path = diffOutputPath(before, after)
+	}
+	diff, err := diffFiles(context.Background(), before, after, path, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, path)
+	fmt.Fprintln(stdout)
+	diff.WriteTable(stdout, *top)
+	return 0
+}
+
+// runMergeCommand "k8s-jprof merge [options] file...": один профиль из нескольких записей
+// (реплик или периодических снимков) в выбранных форматах
+func runMergeCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	formats := fs.String("o", "html", "output formats, comma separated: html, collapsed, pprof, pb.gz")
+	output := fs.String("out", "", "output path without extension (default: merged__<timestamp> next to the first file)")
+	weights := fs.String("weights", "", "sample weights, comma separated, one per file (default: 1 each)")
+	normalize := fs.Bool("normalize", false, "scale every file to the same number of samples before weighting")
+	events := fs.String("event", "", "event types, comma separated: cpu, wall, alloc, lock (default: first found)")
+	threads := fs.Bool("threads", false, "split stacks by thread")
+	simple := fs.Bool("simple", false, "simple class names without package")
+	dotted := fs.Bool("dot", true, "dotted class names: java.lang.String instead of java/lang/String")
+	include := fs.String("include", "", "regexp: keep only stacks with a matching frame")
+	exclude := fs.String("exclude", "", "regexp: drop stacks with a matching frame")
+	from := fs.String("from", "", "start of the time window from recording start, e.g. 10s")
+	to := fs.String("to", "", "end of the time window from recording start, e.g. 1m30s")
+	title := fs.String("title", "", "flame graph title")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: k8s-jprof merge [options] file...")
+		fmt.Fprintln(stderr, "Files may be .jfr, .jfr.gz or collapsed stacks (.collapsed, .txt).")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return 2
+	}
+
+	var selected []string
+	for _, f := range strings.Split(*formats, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if _, ok := nativeFormats[f]; !ok {
+			fmt.Fprintf(stderr, "Error: format %q is not available for merged profiles\n", f)
+			return 2
+		}
+		selected = append(selected, f)
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(stderr, "Error: no output formats")
+		return 2
+	}
+
+	sources := make([]MergeSource, fs.NArg())
+	for i, path := range fs.Args() {
+		sources[i] = MergeSource{Path: path, Weight: 1}
+	}
+	if *weights != "" {
+		values := strings.Split(*weights, ",")
+		if len(values) != len(sources) {
+			fmt.Fprintf(stderr, "Error: %d weights for %d files\n", len(values), len(sources))
+			return 2
+		}
+		for i, v := range values {
+			w, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || w <= 0 {
+				fmt.Fprintf(stderr, "Error: invalid weight %q\n", v)
+				return 2
+			}
+			sources[i].Weight = w
+		}
+	}
+
+	opts := ConvertOptions{Threads: *threads, Simple: *simple, Dotted: *dotted, Include: *include, Exclude: *exclude, Title: *title}
+	for _, e := range strings.Split(*events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			opts.Events = append(opts.Events, e)
+		}
+	}
+	var err error
+	if opts.From, err = parseTimeOffset(*from); err != nil {
+		fmt.Fprintf(stderr, "Error: invalid -from: %v\n", err)
+		return 2
+	}
+	if opts.To, err = parseTimeOffset(*to); err != nil {
+		fmt.Fprintf(stderr, "Error: invalid -to: %v\n", err)
+		return 2
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	base := *output
+	if base == "" {
+		base = mergeOutputBase(sources, time.Now())
+	}
+	results, metaPath, err := mergeFiles(context.Background(), sources, base, selected, opts, *normalize)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	exitCode := 0
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Fprintf(stderr, "Error: %s failed: %v\n", r.label(), r.err)
+			exitCode = 1
+			continue
+		}
+		fmt.Fprintln(stdout, r.path)
+	}
+	if metaPath != "" {
+		fmt.Fprintln(stdout, metaPath)
+	}
+	return exitCode
+}
+
+// runSummaryCommand "k8s-jprof summary [options] file.jfr...": сводка по записи в markdown;
+// с -save сводка сохраняется рядом с записью, как после записи из приложения
+func runSummaryCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	event := fs.String("event", "", "event type: cpu, wall, alloc, lock (default: first found)")
+	include := fs.String("include", "", "regexp: keep only stacks with a matching frame")
+	exclude := fs.String("exclude", "", "regexp: drop stacks with a matching frame")
+	save := fs.Bool("save", false, "write .summary.md and .summary.json next to the recording instead of printing")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: k8s-jprof summary [options] file.jfr...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := ConvertOptions{Dotted: true, Include: *include, Exclude: *exclude}
+	if e := strings.TrimSpace(*event); e != "" {
+		opts.Events = []string{e}
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range fs.Args() {
+		summary, err := summarizeFile(context.Background(), path, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %s: %v\n", path, err)
+			exitCode = 1
+			continue
+		}
+		title := "Recording summary: " + filepath.Base(path)
+		if !*save {
+			summary.WriteMarkdown(stdout, title)
+			continue
+		}
+		files, err := writeSummaryFiles(summary, recordingBase(path), title)
+		for _, f := range files {
+			fmt.Fprintln(stdout, f)
+		}
+		if err == nil {
+			err = addMetadataFiles(path, files)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %s: %v\n", path, err)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// clusterErrorKind вид ошибки обращения к кластеру
+type clusterErrorKind int
+
+const (
+	clusterErrorUnknown clusterErrorKind = iota
+	clusterErrorUnreachable
+	clusterErrorTLS
+	clusterErrorAuthExpired
+	clusterErrorForbidden
+	clusterErrorNotFound
+	clusterErrorTimeout
+	clusterErrorKubectlMissing
+	clusterErrorKubectlIncompatible
+)
+
+// Действия, которые overlay ошибки предлагает пользователю
+const (
+	remedyRetry            = "retry"
+	remedyReset            = "reset"
+	remedyOpenKubeDir      = "openKubeDir"
+	remedyChooseKubeconfig = "chooseKubeconfig"
+	remedyChooseNamespace  = "chooseNamespace"
+	remedyInstallKubectl   = "installKubectl"
+)
+
+const kubectlInstallURL = "https://kubernetes.io/docs/tasks/tools/"
+
+// Операции, для которых классифицируются ошибки
+const (
+	opListNamespaces = "listing namespaces"
+	opListPods       = "listing pods"
+)
+
+// ClusterError классифицированная ошибка kubectl с исходным stderr для раздела "Details"
+type ClusterError struct {
+	Kind   clusterErrorKind
+	Op     string // что делали: "listing namespaces", "listing pods", ...; пусто, если операцию называет вызывающий код
+	Stderr string
+	Err    error
+}
+
+func (e *ClusterError) Error() string {
+	msg := e.Title()
+	if e.Op != "" {
+		msg = e.Op + ": " + msg
+	}
+	if e.Stderr != "" {
+		msg += " | Details: " + e.Stderr
+	} else if e.Err != nil {
+		msg += fmt.Sprintf(" (%v)", e.Err)
+	}
+	return msg
+}
+
+func (e *ClusterError) Unwrap() error {
+	return e.Err
+}
+
+// Title короткое описание ошибки для overlay
+func (e *ClusterError) Title() string {
+	switch e.Kind {
+	case clusterErrorUnreachable:
+		return "Cluster is unreachable"
+	case clusterErrorTLS:
+		return "TLS certificate problem"
+	case clusterErrorAuthExpired:
+		return "Credentials expired or invalid"
+	case clusterErrorForbidden:
+		return "Access denied by RBAC"
+	case clusterErrorNotFound:
+		return "Resource not found"
+	case clusterErrorTimeout:
+		return "Cluster request timed out"
+	case clusterErrorKubectlMissing:
+		return "kubectl not found"
+	case clusterErrorKubectlIncompatible:
+		return "kubectl version is incompatible"
+	}
+	return "Cluster request failed"
+}
+
+// Hint подсказка, что сделать пользователю
+func (e *ClusterError) Hint() string {
+	switch e.Kind {
+	case clusterErrorUnreachable:
+		return "Check the network connection or enable VPN, then retry."
+	case clusterErrorTLS:
+		return "The server certificate is not trusted by this kubeconfig. Check certificate-authority data or the server address."
+	case clusterErrorAuthExpired:
+		return "Log in again to refresh the token (e.g. your cloud CLI login), or choose another kubeconfig."
+	case clusterErrorForbidden:
+		if e.Op == opListNamespaces {
+			return "Your account may not list namespaces in this cluster. Choose another kubeconfig or ask for permissions."
+		}
+		return "Your account may not list pods here. Choose a namespace you have access to or ask for permissions."
+	case clusterErrorNotFound:
+		if e.Op == opListNamespaces {
+			return "The cluster does not serve this resource. Check the kubeconfig context."
+		}
+		return "The namespace may have been deleted. Choose another namespace."
+	case clusterErrorTimeout:
+		return "The cluster did not answer in time. Retry or check the connection."
+	case clusterErrorKubectlMissing:
+		return "Install kubectl and make sure it is in PATH, then retry."
+	case clusterErrorKubectlIncompatible:
+		return "Update kubectl to a version supported by the cluster."
+	}
+	return "See details below."
+}
+
+// Remedies действия для данной ошибки; первая - основная
+func (e *ClusterError) Remedies() []string {
+	switch e.Kind {
+	case clusterErrorTLS:
+		return []string{remedyOpenKubeDir, remedyChooseKubeconfig, remedyRetry}
+	case clusterErrorAuthExpired:
+		return []string{remedyRetry, remedyChooseKubeconfig, remedyOpenKubeDir}
+	case clusterErrorForbidden, clusterErrorNotFound:
+		if e.Op == opListNamespaces {
+			// Без списка namespaces выбирать нечего - остается другой kubeconfig
+			return []string{remedyChooseKubeconfig, remedyRetry}
+		}
+		return []string{remedyChooseNamespace, remedyRetry}
+	case clusterErrorKubectlMissing, clusterErrorKubectlIncompatible:
+		return []string{remedyInstallKubectl, remedyRetry}
+	}
+	return []string{remedyRetry, remedyReset}
+}
+
+// remedyLabel надпись кнопки действия
+func remedyLabel(remedy string) string {
+	switch remedy {
+	case remedyRetry:
+		return "Retry"
+	case remedyReset:
+		return "Reset"
+	case remedyOpenKubeDir:
+		return "Open ~/.kube"
+	case remedyChooseKubeconfig:
+		return "Choose kubeconfig"
+	case remedyChooseNamespace:
+		return "Choose namespace"
+	case remedyInstallKubectl:
+		return "Install kubectl"
+	}
+	return remedy
+}
+
+// Признаки в stderr kubectl. Порядок проверки важен: например, ошибка exec-плагина
+// авторизации содержит "not found", но относится к учетным данным.
+var clusterErrorPatterns = []struct {
+	kind    clusterErrorKind
+	needles []string
+}{
+	{clusterErrorKubectlIncompatible, []string{"unknown flag", "unknown shorthand flag", "unknown command", "exec plugin: invalid apiversion", "server version is too old"}},
+	{clusterErrorAuthExpired, []string{"unauthorized", "you must be logged in", "token has expired", "token is expired", "invalid_grant", "refresh token", "getting credentials", "authentication required", "the server has asked for the client to provide credentials"}},
+	{clusterErrorTLS, []string{"x509:", "certificate", "tls: "}},
+	{clusterErrorTimeout, []string{"i/o timeout", "timeout awaiting", "client.timeout exceeded", "context deadline exceeded", "tls handshake timeout"}},
+	{clusterErrorForbidden, []string{"forbidden", "cannot list resource", "cannot get resource"}},
+	{clusterErrorNotFound, []string{"notfound", "not found"}},
+	{clusterErrorUnreachable, []string{"unable to connect to the server", "connection refused", "no such host", "network is unreachable", "no route to host", "connection reset", "dial tcp", "http2: server sent goaway", "http2: client connection lost"}},
+}
+
+// clusterTransportEOFPattern обрыв соединения с API-сервером: "read tcp 10.0.0.1:5000->10.0.0.2:443: read: EOF".
+// Голый "EOF" не годится: он бывает и в выводе команд в поде
+var clusterTransportEOFPattern = regexp.MustCompile(`\b(read|write) (tcp|udp)[46]? \S+: (read: |write: )?eof\b`)
+
+// classifyKubectlError определяет вид ошибки по контексту, ошибке запуска и stderr
+func classifyKubectlError(ctx context.Context, op string, err error, stderr string) *ClusterError {
+	stderr = strings.TrimSpace(stderr)
+	ce := &ClusterError{Kind: clusterErrorUnknown, Op: op, Stderr: stderr, Err: err}
+
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		ce.Kind = clusterErrorKubectlMissing
+		return ce
+	case ctx != nil && ctx.Err() == context.DeadlineExceeded:
+		ce.Kind = clusterErrorTimeout
+		return ce
+	}
+
+	lower := strings.ToLower(stderr)
+	for _, pattern := range clusterErrorPatterns {
+		for _, needle := range pattern.needles {
+			if strings.Contains(lower, needle) {
+				ce.Kind = pattern.kind
+				return ce
+			}
+		}
+		if pattern.kind == clusterErrorUnreachable && clusterTransportEOFPattern.MatchString(lower) {
+			ce.Kind = pattern.kind
+			return ce
+		}
+	}
+	return ce
+}
+
+// runKubectlOutput выполняет kubectl и возвращает stdout; ошибки классифицируются
+func runKubectlOutput(ctx context.Context, op, kubeconfigPath string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", append([]string{"--kubeconfig", kubeconfigPath}, args...)...)
+
+	// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
+	setSysProcAttr(cmd)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, classifyKubectlError(ctx, op, err, stderr.String())
+	}
+	return output, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClassifyKubectlError(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	exitErr := errors.New("exit status 1")
+	missing := &exec.Error{Name: "kubectl", Err: exec.ErrNotFound}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		err    error
+		stderr string
+		want   clusterErrorKind
+	}{
+		{
+			name:   "unknown authority",
+			stderr: "Unable to connect to the server: x509: certificate signed by unknown authority",
+			want:   clusterErrorTLS,
+		},
+		{
+			name:   "certificate verification",
+			stderr: `Unable to connect to the server: tls: failed to verify certificate: x509: certificate has expired or is not yet valid: current time 2024-06-01T10:00:00Z is after 2024-05-01T00:00:00Z`,
+			want:   clusterErrorTLS,
+		},
+		{
+			name:   "expired token",
+			stderr: "error: You must be logged in to the server (Unauthorized)",
+			want:   clusterErrorAuthExpired,
+		},
+		{
+			name:   "invalid token",
+			stderr: "E0601 10:00:00.000000   12345 memcache.go:265] couldn't get current server API group list: the server has asked for the client to provide credentials",
+			want:   clusterErrorAuthExpired,
+		},
+		{
+			// "not found" в ошибке exec-плагина относится к учетным данным, а не к ресурсу
+			name:   "missing auth plugin",
+			stderr: "Unable to connect to the server: getting credentials: exec: executable kubelogin not found",
+			want:   clusterErrorAuthExpired,
+		},
+		{
+			name:   "forbidden",
+			stderr: `Error from server (Forbidden): namespaces is forbidden: User "dev@example.com" cannot list resource "namespaces" in API group "" at the cluster scope`,
+			want:   clusterErrorForbidden,
+		},
+		{
+			name:   "namespace not found",
+			stderr: `Error from server (NotFound): namespaces "payments" not found`,
+			want:   clusterErrorNotFound,
+		},
+		{
+			name:   "connection refused",
+			stderr: "Unable to connect to the server: dial tcp 10.0.0.1:6443: connect: connection refused",
+			want:   clusterErrorUnreachable,
+		},
+		{
+			name:   "unknown host",
+			stderr: "Unable to connect to the server: dial tcp: lookup api.cluster.example on 127.0.0.53:53: no such host",
+			want:   clusterErrorUnreachable,
+		},
+		{
+			name:   "connection reset by VPN",
+			stderr: "Unable to connect to the server: read tcp 10.8.0.6:51234->10.0.0.1:443: read: connection reset by peer",
+			want:   clusterErrorUnreachable,
+		},
+		{
+			name:   "connection dropped mid-request",
+			stderr: "error: error upgrading connection: read tcp 192.168.1.10:52144->10.0.0.1:443: read: EOF",
+			want:   clusterErrorUnreachable,
+		},
+		{
+			name:   "server closed HTTP/2 connection",
+			stderr: `error: http2: server sent GOAWAY and closed the connection; LastStreamID=1999, ErrCode=NO_ERROR, debug=""`,
+			want:   clusterErrorUnreachable,
+		},
+		{
+			// EOF в выводе команды в поде - не сетевая ошибка
+			name:   "EOF from pod command",
+			stderr: "tar: Unexpected EOF in archive\ntar: Error is not recoverable: exiting now\ncommand terminated with exit code 2",
+			want:   clusterErrorUnknown,
+		},
+		{
+			name:   "dial timeout",
+			stderr: "Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout",
+			want:   clusterErrorTimeout,
+		},
+		{
+			name:   "old kubectl",
+			stderr: `error: unknown command "can-i" for "kubectl auth"`,
+			want:   clusterErrorKubectlIncompatible,
+		},
+		{
+			name: "kubectl not installed",
+			err:  missing,
+			want: clusterErrorKubectlMissing,
+		},
+		{
+			name:   "wrapped missing kubectl",
+			err:    errors.Join(errors.New("listing pods"), missing),
+			stderr: "",
+			want:   clusterErrorKubectlMissing,
+		},
+		{
+			// kubectl убит по таймауту: stderr может содержать что угодно
+			name:   "deadline",
+			ctx:    expired,
+			err:    errors.New("signal: killed"),
+			stderr: "Error from server (Forbidden): partial output",
+			want:   clusterErrorTimeout,
+		},
+		{
+			name:   "unrecognized",
+			stderr: "error: the server doesn't have a resource type \"pods\"",
+			want:   clusterErrorUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			err := tt.err
+			if err == nil {
+				err = exitErr
+			}
+			ce := classifyKubectlError(ctx, opListPods, err, "  "+tt.stderr+"\n")
+			if ce.Kind != tt.want {
+				t.Errorf("kind = %q, want %q", ce.Title(), (&ClusterError{Kind: tt.want}).Title())
+			}
+			if ce.Stderr != tt.stderr || ce.Op != opListPods || !errors.Is(ce, err) {
+				t.Errorf("error details not kept: %+v", ce)
+			}
+		})
+	}
+}
+
+func TestClusterErrorRemedies(t *testing.T) {
+	tests := []struct {
+		kind clusterErrorKind
+		op   string
+		want []string
+	}{
+		{clusterErrorUnreachable, opListPods, []string{remedyRetry, remedyReset}},
+		{clusterErrorTLS, opListNamespaces, []string{remedyOpenKubeDir, remedyChooseKubeconfig, remedyRetry}},
+		{clusterErrorForbidden, opListNamespaces, []string{remedyChooseKubeconfig, remedyRetry}},
+		{clusterErrorForbidden, opListPods, []string{remedyChooseNamespace, remedyRetry}},
+		{clusterErrorKubectlMissing, "", []string{remedyInstallKubectl, remedyRetry}},
+	}
+	for _, tt := range tests {
+		ce := &ClusterError{Kind: tt.kind, Op: tt.op}
+		if got := ce.Remedies(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s while %s: remedies = %q, want %q", ce.Title(), tt.op, got, tt.want)
+		}
+	}
+}
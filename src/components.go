@@ -1,6 +1,8 @@
 package main
 
 import (
+	"image/color"
+
 	"gioui.org/font"
 	"gioui.org/layout"
 	"gioui.org/op/paint"
@@ -80,16 +82,20 @@ func (vbc *VersionBadgeComponent) Layout(gtx layout.Context, th *material.Theme)
 	return badge.Layout(gtx)
 }
 
-// HeaderComponent объединяет заголовок и версию
+// HeaderComponent объединяет заголовок, кнопки "History" и "Open JFR…" и версию
 type HeaderComponent struct {
 	titleComponent       *TitleComponent
 	versionBadgeComponent *VersionBadgeComponent
+	openJFRButton        *widget.Clickable
+	historyButton        *widget.Clickable
 }
 
-func NewHeaderComponent(logoImage paint.ImageOp, versionBadge *widget.Clickable, version string) *HeaderComponent {
+func NewHeaderComponent(logoImage paint.ImageOp, versionBadge *widget.Clickable, openJFRButton *widget.Clickable, historyButton *widget.Clickable, version string) *HeaderComponent {
 	return &HeaderComponent{
 		titleComponent:        NewTitleComponent(logoImage),
 		versionBadgeComponent: NewVersionBadgeComponent(versionBadge, version),
+		openJFRButton:         openJFRButton,
+		historyButton:         historyButton,
 	}
 }
 
@@ -101,6 +107,26 @@ func (hc *HeaderComponent) Layout(gtx layout.Context, th *material.Theme, onClos
 			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 				return hc.titleComponent.Layout(gtx, th)
 			}),
+			// История записей
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Right: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					btn := material.Button(th, hc.historyButton, "History")
+					btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+					btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+					btn.TextSize = unit.Sp(12)
+					return btn.Layout(gtx)
+				})
+			}),
+			// Открытие сохраненного JFR
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Right: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					btn := material.Button(th, hc.openJFRButton, "Open JFR…")
+					btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+					btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+					btn.TextSize = unit.Sp(12)
+					return btn.Layout(gtx)
+				})
+			}),
 			// Версия справа
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return layout.Inset{Right: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
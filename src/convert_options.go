@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// convertOptionsSettingName опции конвертации запоминаются вместе с форматом: глобально и для kubeconfig/namespace
+const convertOptionsSettingName = "convert_options.mem"
+
+// convertEventKinds виды событий, которые можно выбрать для конвертации
+var convertEventKinds = []string{"cpu", "wall", "alloc", "lock"}
+
+func getConvertOptionsFilePath() string {
+	return filepath.Join(getConfigDir(), convertOptionsSettingName)
+}
+
+// encode опции одной строкой JSON, как они хранятся в .mem файлах
+func (o ConvertOptions) encode() string {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeConvertOptions разбирает сохраненные опции; некорректные значения отбрасываются
+func decodeConvertOptions(value string) (ConvertOptions, bool) {
+	opts := defaultConvertOptions()
+	if err := json.Unmarshal([]byte(value), &opts); err != nil {
+		return defaultConvertOptions(), false
+	}
+	if err := opts.Validate(); err != nil {
+		return defaultConvertOptions(), false
+	}
+	return opts, true
+}
+
+// loadConvertOptions глобально сохраненные опции конвертации
+func loadConvertOptions() ConvertOptions {
+	data, err := os.ReadFile(getConvertOptionsFilePath())
+	if err != nil {
+		return defaultConvertOptions()
+	}
+	opts, ok := decodeConvertOptions(strings.TrimSpace(string(data)))
+	if !ok {
+		log.Printf("Warning: invalid %s, using defaults", getConvertOptionsFilePath())
+	}
+	return opts
+}
+
+// saveConvertOptions запоминает опции глобально и для текущих kubeconfig/namespace
+func (a *Application) saveConvertOptions() {
+	value := a.convertOptions.encode()
+	path := getConvertOptionsFilePath()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte(value), 0644)
+	a.rememberSetting(convertOptionsSettingName, value)
+}
+
+// ConvertOptionsForm поля опций конвертации: используется у выбора формата записи и в окне "Open JFR…"
+type ConvertOptionsForm struct {
+	expanded     bool
+	toggleButton widget.Clickable
+	list         widget.List
+
+	events        []widget.Bool // по индексам convertEventKinds; ничего не выбрано - автоматически
+	threads       widget.Bool
+	simple        widget.Bool
+	dotted        widget.Bool
+	includeEditor widget.Editor
+	excludeEditor widget.Editor
+	fromEditor    widget.Editor
+	toEditor      widget.Editor
+	titleEditor   widget.Editor
+
+	synced    string // опции, с которыми форма синхронизирована
+	formError string
+}
+
+func NewConvertOptionsForm() *ConvertOptionsForm {
+	f := &ConvertOptionsForm{
+		events: make([]widget.Bool, len(convertEventKinds)),
+		list: widget.List{
+			List: layout.List{
+				Axis: layout.Vertical,
+			},
+		},
+	}
+	for _, editor := range f.editors() {
+		editor.SingleLine = true
+	}
+	return f
+}
+
+func (f *ConvertOptionsForm) editors() []*widget.Editor {
+	return []*widget.Editor{&f.includeEditor, &f.excludeEditor, &f.fromEditor, &f.toEditor, &f.titleEditor}
+}
+
+func (f *ConvertOptionsForm) IsExpanded() bool {
+	return f.expanded
+}
+
+// set заполняет форму из опций
+func (f *ConvertOptionsForm) set(opts ConvertOptions) {
+	selected := map[string]bool{}
+	for _, e := range opts.Events {
+		selected[e] = true
+	}
+	for i, kind := range convertEventKinds {
+		f.events[i].Value = selected[kind]
+	}
+	f.threads.Value = opts.Threads
+	f.simple.Value = opts.Simple
+	f.dotted.Value = opts.Dotted
+	f.includeEditor.SetText(opts.Include)
+	f.excludeEditor.SetText(opts.Exclude)
+	f.fromEditor.SetText(formatTimeOffset(opts.From))
+	f.toEditor.SetText(formatTimeOffset(opts.To))
+	f.titleEditor.SetText(opts.Title)
+	f.synced = opts.encode()
+	f.formError = ""
+}
+
+// options собирает опции из полей формы
+func (f *ConvertOptionsForm) options() (ConvertOptions, error) {
+	opts := ConvertOptions{
+		Threads: f.threads.Value,
+		Simple:  f.simple.Value,
+		Dotted:  f.dotted.Value,
+		Include: strings.TrimSpace(f.includeEditor.Text()),
+		Exclude: strings.TrimSpace(f.excludeEditor.Text()),
+		Title:   strings.TrimSpace(f.titleEditor.Text()),
+	}
+	for i, kind := range convertEventKinds {
+		if f.events[i].Value {
+			opts.Events = append(opts.Events, kind)
+		}
+	}
+	var err error
+	if opts.From, err = parseTimeOffset(f.fromEditor.Text()); err != nil {
+		return opts, fmt.Errorf("invalid from: %v", err)
+	}
+	if opts.To, err = parseTimeOffset(f.toEditor.Text()); err != nil {
+		return opts, fmt.Errorf("invalid to: %v", err)
+	}
+	return opts, opts.Validate()
+}
+
+// sync переносит корректные изменения формы в опции приложения и сохраняет их
+func (f *ConvertOptionsForm) sync(a *Application) {
+	opts, err := f.options()
+	if err != nil {
+		f.formError = err.Error()
+		return
+	}
+	f.formError = ""
+	if value := opts.encode(); value != f.synced {
+		f.synced = value
+		a.convertOptions = opts
+		a.saveConvertOptions()
+	}
+}
+
+// summary краткое описание опций для кнопки
+func (o ConvertOptions) summary() string {
+	var parts []string
+	if len(o.Events) > 0 {
+		parts = append(parts, strings.Join(o.Events, "+"))
+	}
+	if o.Threads {
+		parts = append(parts, "threads")
+	}
+	if o.Include != "" || o.Exclude != "" {
+		parts = append(parts, "filtered")
+	}
+	if o.From > 0 || o.To > 0 {
+		parts = append(parts, "window")
+	}
+	if len(parts) == 0 {
+		return "Options"
+	}
+	return "Options: " + strings.Join(parts, ", ")
+}
+
+// LayoutToggle отрисовывает кнопку открытия опций рядом с выбором формата
+func (f *ConvertOptionsForm) LayoutToggle(gtx layout.Context, th *material.Theme, app *Application) layout.Dimensions {
+	for f.toggleButton.Clicked(gtx) {
+		if f.expanded {
+			f.expanded = false
+		} else {
+			app.closeAllSelectors()
+			f.set(app.convertOptions)
+			f.expanded = true
+		}
+	}
+
+	if f.toggleButton.Hovered() {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+
+	btn := material.Button(th, &f.toggleButton, app.convertOptions.summary())
+	if f.expanded {
+		btn.Background = color.NRGBA{R: 220, G: 220, B: 220, A: 255}
+	} else {
+		btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+	}
+	btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+	return btn.Layout(gtx)
+}
+
+// LayoutForm отрисовывает раскрытую форму под выбором формата и сохраняет изменения
+func (f *ConvertOptionsForm) LayoutForm(gtx layout.Context, th *material.Theme, app *Application) layout.Dimensions {
+	if !f.expanded {
+		return layout.Dimensions{}
+	}
+	f.sync(app)
+
+	rows := f.rows(th)
+	return layout.Background{}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			// Gray border
+			defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+			paint.Fill(gtx.Ops, color.NRGBA{R: 180, G: 180, B: 180, A: 255})
+			return layout.Dimensions{Size: gtx.Constraints.Max}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: unit.Dp(1), Bottom: unit.Dp(1), Left: unit.Dp(1), Right: unit.Dp(1)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				// White background inside
+				defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+				paint.Fill(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+				return material.List(th, &f.list).Layout(gtx, len(rows), func(gtx layout.Context, index int) layout.Dimensions {
+					return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx, rows[index])
+				})
+			})
+		},
+	)
+}
+
+// rows строки формы; окно "Open JFR…" встраивает их в свой список
+func (f *ConvertOptionsForm) rows(th *material.Theme) []layout.Widget {
+	labeled := func(label string, content layout.Widget) layout.Widget {
+		return func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(100))
+					gtx.Constraints.Max.X = gtx.Dp(unit.Dp(100))
+					return material.Label(th, unit.Sp(14), label+":").Layout(gtx)
+				}),
+				layout.Flexed(1, content),
+			)
+		}
+	}
+	checkboxes := func(checks []*widget.Bool, labels []string) layout.Widget {
+		return func(gtx layout.Context) layout.Dimensions {
+			children := make([]layout.FlexChild, 0, len(checks))
+			for i := range checks {
+				check, label := checks[i], labels[i]
+				children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						checkbox := material.CheckBox(th, check, label)
+						checkbox.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
+						return checkbox.Layout(gtx)
+					})
+				}))
+			}
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+		}
+	}
+	editor := func(editor *widget.Editor, hint string) layout.Widget {
+		return func(gtx layout.Context) layout.Dimensions {
+			return layout.Background{}.Layout(gtx,
+				func(gtx layout.Context) layout.Dimensions {
+					defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+					paint.Fill(gtx.Ops, color.NRGBA{R: 248, G: 248, B: 248, A: 255})
+					return layout.Dimensions{Size: gtx.Constraints.Min}
+				},
+				func(gtx layout.Context) layout.Dimensions {
+					return layout.UniformInset(unit.Dp(6)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						e := material.Editor(th, editor, hint)
+						e.TextSize = unit.Sp(14)
+						e.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
+						e.HintColor = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+						return e.Layout(gtx)
+					})
+				},
+			)
+		}
+	}
+
+	eventChecks := make([]*widget.Bool, len(f.events))
+	for i := range f.events {
+		eventChecks[i] = &f.events[i]
+	}
+
+	return []layout.Widget{
+		labeled("Events", checkboxes(eventChecks, convertEventKinds)),
+		labeled("Stacks", checkboxes(
+			[]*widget.Bool{&f.threads, &f.simple, &f.dotted},
+			[]string{"Split by thread", "Simple class names", "Dotted class names"},
+		)),
+		labeled("Include", editor(&f.includeEditor, "regexp: keep stacks with a matching frame")),
+		labeled("Exclude", editor(&f.excludeEditor, "regexp: drop stacks with a matching frame")),
+		labeled("From", editor(&f.fromEditor, "offset from recording start, e.g. 10s")),
+		labeled("To", editor(&f.toEditor, "offset from recording start, e.g. 1m30s")),
+		labeled("Title", editor(&f.titleEditor, "flame graph title (default: by event type)")),
+		func(gtx layout.Context) layout.Dimensions {
+			if f.formError == "" {
+				return layout.Dimensions{}
+			}
+			label := material.Label(th, unit.Sp(12), "Error: "+f.formError)
+			label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+			return label.Layout(gtx)
+		},
+	}
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// ConvertPanel окно "Open JFR…": повторная конвертация сохраненного JFR с выбором форматов и опций
+type ConvertPanel struct {
+	visible    bool
+	jfrPath    string
+	converting bool
+	generation uint64 // результаты конвертации, запущенной для другого файла, отбрасываются
+	result     string
+	htmlPath   string
+
+	formatChecks []widget.Bool // по индексам conversionFormats
+	form         *ConvertOptionsForm
+
+	convertButton widget.Clickable
+	diffButton    widget.Clickable // сравнение открытого файла с базовой записью
+	viewButton    widget.Clickable // просмотр flame graph в приложении
+	browserButton widget.Clickable
+	closeButton   widget.Clickable
+	list          widget.List
+}
+
+func NewConvertPanel() *ConvertPanel {
+	p := &ConvertPanel{
+		formatChecks: make([]widget.Bool, len(conversionFormats)),
+		form:         NewConvertOptionsForm(),
+		list: widget.List{
+			List: layout.List{
+				Axis: layout.Vertical,
+			},
+		},
+	}
+	return p
+}
+
+// show открывает окно для файла; форматы и опции по умолчанию - выбранные для записи
+func (p *ConvertPanel) show(jfrPath string, formats []string, opts ConvertOptions) {
+	p.visible = true
+	p.jfrPath = jfrPath
+	p.converting = false
+	p.generation++
+	p.result = ""
+	p.htmlPath = ""
+
+	selected := map[string]bool{}
+	for _, f := range formats {
+		selected[f] = true
+	}
+	for i, f := range conversionFormats {
+		p.formatChecks[i].Value = selected[f]
+	}
+	p.form.set(opts)
+}
+
+func (p *ConvertPanel) selectedFormats() []string {
+	var formats []string
+	for i, f := range conversionFormats {
+		if p.formatChecks[i].Value {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// openJFR запрашивает JFR-файл и открывает окно конвертации
+func (a *Application) openJFR() {
+	if a.isRecording || a.isChoosingFolder {
+		return
+	}
+	a.closeAllSelectors()
+	a.isChoosingFolder = true
+	a.choosingMessage = "Choosing JFR file..."
+
+	go func() {
+		path, err := chooseFileDialog("Open JFR recording", "jfr")
+		if err != nil {
+			path = ""
+		}
+		a.post(jfrChosenEvent{path: path})
+	}()
+}
+
+// jfrChosenEvent диалог выбора JFR закрыт
+type jfrChosenEvent struct {
+	path string // пусто, если пользователь отменил выбор
+}
+
+func (e jfrChosenEvent) apply(a *Application) {
+	a.isChoosingFolder = false
+	a.choosingMessage = ""
+	if e.path == "" {
+		return
+	}
+	var formats []string
+	if a.formatSelector != nil {
+		formats = a.formatSelector.GetSelectedFormats()
+	}
+	a.convertPanel.show(e.path, formats, a.convertOptions)
+}
+
+// startConversion конвертирует открытый файл в фоне
+func (a *Application) startConversion() {
+	p := a.convertPanel
+	formats := p.selectedFormats()
+	if len(formats) == 0 {
+		p.result = "Error: select at least one format"
+		return
+	}
+	opts, err := p.form.options()
+	if err != nil {
+		p.result = "Error: " + err.Error()
+		return
+	}
+
+	p.converting = true
+	p.result = "Converting..."
+	p.htmlPath = ""
+	generation := p.generation
+	jfrPath := p.jfrPath
+	backend := a.converterBackend
+	timeout := a.timeouts.Conversion
+
+	go func() {
+		results, err := convertFile(context.Background(), jfrPath, formats, opts, backend, timeout)
+		a.post(jfrConvertedEvent{generation: generation, results: results, err: err})
+	}()
+}
+
+// jfrConvertedEvent конвертация из окна "Open JFR…" завершена
+type jfrConvertedEvent struct {
+	generation uint64
+	results    []conversionResult
+	err        error
+}
+
+func (e jfrConvertedEvent) apply(a *Application) {
+	p := a.convertPanel
+	if e.generation != p.generation {
+		return
+	}
+	p.converting = false
+	if e.err != nil {
+		p.result = "Error: " + e.err.Error()
+		return
+	}
+
+	var saved, failed, paths []string
+	for _, r := range e.results {
+		if r.err != nil {
+			failed = append(failed, fmt.Sprintf("%s failed: %v", r.label(), r.err))
+			continue
+		}
+		saved = append(saved, filepath.Base(r.path))
+		paths = append(paths, r.path)
+		if p.htmlPath == "" && filepath.Ext(r.path) == ".html" {
+			p.htmlPath = r.path
+		}
+	}
+	p.result = ""
+	if len(saved) > 0 {
+		p.result = fmt.Sprintf("Saved %s to %s", strings.Join(saved, ", "), filepath.Dir(p.jfrPath))
+	}
+	if len(failed) > 0 {
+		if p.result != "" {
+			p.result += " | "
+		}
+		p.result += "Error: " + strings.Join(failed, "; ")
+	}
+	a.addHistoryFiles(p.jfrPath, paths)
+}
+
+// chooseDiffBaseline запрашивает базовую запись для сравнения с открытым файлом
+func (a *Application) chooseDiffBaseline() {
+	if a.isChoosingFolder {
+		return
+	}
+	a.isChoosingFolder = true
+	a.choosingMessage = "Choosing baseline JFR file..."
+
+	go func() {
+		path, err := chooseFileDialog("Choose baseline JFR recording", "jfr")
+		if err != nil {
+			path = ""
+		}
+		a.post(diffBaselineChosenEvent{path: path})
+	}()
+}
+
+// diffBaselineChosenEvent диалог выбора базовой записи закрыт
+type diffBaselineChosenEvent struct {
+	path string // пусто, если пользователь отменил выбор
+}
+
+func (e diffBaselineChosenEvent) apply(a *Application) {
+	a.isChoosingFolder = false
+	a.choosingMessage = ""
+	p := a.convertPanel
+	if e.path == "" || !p.visible {
+		return
+	}
+	opts, err := p.form.options()
+	if err != nil {
+		p.result = "Error: " + err.Error()
+		return
+	}
+	p.converting = true
+	p.result = "Comparing with " + filepath.Base(e.path) + "..."
+	p.htmlPath = ""
+	a.startDiff(e.path, p.jfrPath, opts, p.generation, false)
+}
+
+// startDiff строит дифференциальный flame graph в фоне; результат показывает окно конвертации
+// или, для history = true, окно истории
+func (a *Application) startDiff(beforePath, afterPath string, opts ConvertOptions, generation uint64, history bool) {
+	go func() {
+		output := diffOutputPath(beforePath, afterPath)
+		_, err := diffFiles(context.Background(), beforePath, afterPath, output, opts)
+		a.post(diffFinishedEvent{generation: generation, history: history, path: output, err: err})
+	}()
+}
+
+// diffFinishedEvent сравнение записей завершено
+type diffFinishedEvent struct {
+	generation uint64
+	history    bool
+	path       string
+	err        error
+}
+
+func (e diffFinishedEvent) apply(a *Application) {
+	if e.history {
+		p := a.historyPanel
+		if e.err != nil {
+			p.message = "Error: " + e.err.Error()
+			return
+		}
+		p.message = "Saved " + filepath.Base(e.path)
+		go a.openInBrowser(e.path)
+		return
+	}
+
+	p := a.convertPanel
+	if e.generation != p.generation {
+		return
+	}
+	p.converting = false
+	if e.err != nil {
+		p.result = "Error: " + e.err.Error()
+		return
+	}
+	p.result = fmt.Sprintf("Saved %s to %s", filepath.Base(e.path), filepath.Dir(e.path))
+	p.htmlPath = e.path
+}
+
+// drawConvertPanel отрисовывает окно конвертации поверх основного UI
+func (a *Application) drawConvertPanel(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	p := a.convertPanel
+	if p == nil || !p.visible {
+		return layout.Dimensions{}
+	}
+
+	// Обработка кликов до отрисовки: кнопки могут закрыть окно
+	for p.closeButton.Clicked(gtx) {
+		p.visible = false
+		p.generation++ // результат незавершенной конвертации больше не нужен окну
+	}
+	for p.convertButton.Clicked(gtx) {
+		if !p.converting {
+			a.startConversion()
+		}
+	}
+	for p.diffButton.Clicked(gtx) {
+		if !p.converting {
+			a.chooseDiffBaseline()
+		}
+	}
+	for p.viewButton.Clicked(gtx) {
+		if opts, err := p.form.options(); err != nil {
+			p.result = "Error: " + err.Error()
+		} else {
+			a.openFlameViewer(p.jfrPath, opts)
+		}
+	}
+	for p.browserButton.Clicked(gtx) {
+		go a.openInBrowser(p.htmlPath)
+	}
+	if !p.visible {
+		return layout.Dimensions{}
+	}
+
+	labeledRow := func(label string, content layout.Widget) layout.Widget {
+		return func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(100))
+					gtx.Constraints.Max.X = gtx.Dp(unit.Dp(100))
+					return material.Label(th, unit.Sp(14), label+":").Layout(gtx)
+				}),
+				layout.Flexed(1, content),
+			)
+		}
+	}
+
+	rows := []layout.Widget{
+		// Заголовок и файл
+		func(gtx layout.Context) layout.Dimensions {
+			label := material.Label(th, unit.Sp(20), "Convert JFR")
+			label.Alignment = text.Middle
+			return label.Layout(gtx)
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			label := material.Label(th, unit.Sp(12), p.jfrPath)
+			label.Color = color.NRGBA{R: 80, G: 80, B: 80, A: 255}
+			label.Alignment = text.Middle
+			return label.Layout(gtx)
+		},
+		labeledRow("Formats", func(gtx layout.Context) layout.Dimensions {
+			children := make([]layout.FlexChild, 0, len(conversionFormats))
+			for i, f := range conversionFormats {
+				check := &p.formatChecks[i]
+				format := f
+				children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						checkbox := material.CheckBox(th, check, format)
+						checkbox.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
+						return checkbox.Layout(gtx)
+					})
+				}))
+			}
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+		}),
+	}
+	rows = append(rows, p.form.rows(th)...)
+	rows = append(rows,
+		// Кнопки
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if !p.converting && p.convertButton.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+					buttonText := "Convert"
+					if p.converting {
+						buttonText = "Converting..."
+					}
+					btn := material.Button(th, &p.convertButton, buttonText)
+					if p.converting {
+						btn.Background = color.NRGBA{R: 200, G: 200, B: 200, A: 255}
+						btn.Color = color.NRGBA{R: 100, G: 100, B: 100, A: 255}
+					} else {
+						btn.Background = color.NRGBA{R: 76, G: 175, B: 80, A: 255}
+						btn.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+					}
+					return btn.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if p.converting {
+						return layout.Dimensions{}
+					}
+					if p.diffButton.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+					btn := material.Button(th, &p.diffButton, "Diff with…")
+					btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+					btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+					return btn.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if p.converting {
+						return layout.Dimensions{}
+					}
+					if p.viewButton.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+					btn := material.Button(th, &p.viewButton, "View")
+					btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+					btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+					return btn.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(20)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if p.htmlPath == "" || p.converting {
+						return layout.Dimensions{}
+					}
+					if p.browserButton.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+					btn := material.Button(th, &p.browserButton, "Open in Browser")
+					btn.Background = color.NRGBA{R: 33, G: 150, B: 243, A: 255}
+					btn.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+					return btn.Layout(gtx)
+				}),
+				layout.Flexed(1, layout.Spacer{}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if p.closeButton.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+					btn := material.Button(th, &p.closeButton, "Close")
+					btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+					btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+					return btn.Layout(gtx)
+				}),
+			)
+		},
+		// Результат
+		func(gtx layout.Context) layout.Dimensions {
+			if p.result == "" {
+				return layout.Dimensions{}
+			}
+			label := material.Label(th, unit.Sp(12), p.result)
+			if strings.Contains(p.result, "Error") {
+				label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+			} else {
+				label.Color = color.NRGBA{R: 50, G: 150, B: 50, A: 255}
+			}
+			return label.Layout(gtx)
+		},
+	)
+
+	// Создаем кликабельную область на весь экран для блокировки
+	clickable := &widget.Clickable{}
+	return material.Clickable(gtx, clickable, func(gtx layout.Context) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+		paint.Fill(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Max.X = gtx.Dp(unit.Dp(700))
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return material.List(th, &p.list).Layout(gtx, len(rows), func(gtx layout.Context, index int) layout.Dimensions {
+				return layout.Inset{Top: unit.Dp(6), Bottom: unit.Dp(6)}.Layout(gtx, rows[index])
+			})
+		})
+	})
+}
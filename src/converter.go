@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Бэкенды конвертации JFR: встроенный (Go) и jfr-converter.jar (нужна Java)
+const (
+	converterNative = "native"
+	converterJava   = "java"
+)
+
+// nativeFormats форматы, которые конвертируются без Java, и расширения результата
+var nativeFormats = map[string]string{
+	"collapsed": ".collapsed",
+	"html":      ".html",
+	"pprof":     ".pprof",
+	"pb.gz":     ".pb.gz",
+}
+
+// javaConverterPath jfr-converter.jar из поставки приложения
+const javaConverterPath = "./data/jfr-converter.jar"
+
+func getConverterBackendFilePath() string {
+	return filepath.Join(getConfigDir(), "converter_backend.mem")
+}
+
+// loadConverterBackend предпочитаемый бэкенд: "java" в converter_backend.mem включает jfr-converter.jar
+// для всех форматов, по умолчанию используется встроенный конвертер
+func loadConverterBackend() string {
+	data, err := os.ReadFile(getConverterBackendFilePath())
+	if err == nil && strings.TrimSpace(string(data)) == converterJava {
+		return converterJava
+	}
+	return converterNative
+}
+
+// javaAvailable можно ли конвертировать через jfr-converter.jar: есть java в PATH и сам jar
+func javaAvailable() bool {
+	if _, err := exec.LookPath("java"); err != nil {
+		return false
+	}
+	_, err := os.Stat(javaConverterPath)
+	return err == nil
+}
+
+// conversionFormats форматы, в которые конвертируется JFR
+var conversionFormats = []string{"html", "collapsed", "pprof", "pb.gz", "heatmap", "otlp"}
+
+// ConvertOptions параметры конвертации, общие для встроенного конвертера и jfr-converter.
+// Сохраняются вместе с выбором форматов, поэтому у полей есть JSON-имена.
+type ConvertOptions struct {
+	Events  []string      `json:"events,omitempty"`  // cpu, wall, alloc, lock: по файлу на каждый; пусто - первый вид, для которого есть события
+	Threads bool          `json:"threads,omitempty"` // отдельный стек для каждого потока
+	Simple  bool          `json:"simple,omitempty"`  // имена классов без пакета
+	Dotted  bool          `json:"dotted,omitempty"`  // java.lang.String вместо java/lang/String
+	Include string        `json:"include,omitempty"` // регулярное выражение: только стеки с подходящим фреймом
+	Exclude string        `json:"exclude,omitempty"` // регулярное выражение: без стеков с подходящим фреймом
+	From    time.Duration `json:"from,omitempty"`    // начало окна от начала записи; 0 - с начала
+	To      time.Duration `json:"to,omitempty"`      // конец окна от начала записи; 0 - до конца
+	Title   string        `json:"title,omitempty"`   // заголовок flame graph; пусто - по виду событий
+}
+
+// defaultConvertOptions опции по умолчанию: имена классов через точку
+func defaultConvertOptions() ConvertOptions {
+	return ConvertOptions{Dotted: true}
+}
+
+// Validate проверяет опции до запуска конвертации
+func (o ConvertOptions) Validate() error {
+	for _, event := range o.events() {
+		if _, err := o.readOptions(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// events виды событий для конвертации; "" - вид выбирается по файлу
+func (o ConvertOptions) events() []string {
+	if len(o.Events) == 0 {
+		return []string{""}
+	}
+	return o.Events
+}
+
+// readOptions опции встроенного конвертера для одного вида событий
+func (o ConvertOptions) readOptions(event string) (JFRReadOptions, error) {
+	r := JFRReadOptions{Event: event, Threads: o.Threads, Simple: o.Simple, Dotted: o.Dotted, From: o.From, To: o.To}
+	if event != "" {
+		known := false
+		for _, k := range jfrEventKinds {
+			known = known || k.kind == event
+		}
+		if !known {
+			return r, fmt.Errorf("unknown event type %q", event)
+		}
+	}
+	var err error
+	if o.Include != "" {
+		if r.Include, err = regexp.Compile(o.Include); err != nil {
+			return r, fmt.Errorf("invalid include pattern: %v", err)
+		}
+	}
+	if o.Exclude != "" {
+		if r.Exclude, err = regexp.Compile(o.Exclude); err != nil {
+			return r, fmt.Errorf("invalid exclude pattern: %v", err)
+		}
+	}
+	if o.From < 0 || o.To < 0 || (o.To > 0 && o.To <= o.From) {
+		return r, fmt.Errorf("invalid time range: from %v to %v", o.From, o.To)
+	}
+	return r, nil
+}
+
+// title заголовок flame graph для вида событий
+func (o ConvertOptions) title(event string) string {
+	if o.Title == "" {
+		return flameGraphTitle(event)
+	}
+	if len(o.Events) > 1 {
+		return o.Title + " (" + event + ")"
+	}
+	return o.Title
+}
+
+// parseTimeOffset смещение от начала записи: "90s", "1m30s" или число секунд
+func parseTimeOffset(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+	return time.ParseDuration(value + "s")
+}
+
+// formatTimeOffset смещение для поля ввода; 0 - пустое поле
+func formatTimeOffset(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// javaArgs те же опции для jfr-converter.jar
+func (o ConvertOptions) javaArgs(event string) []string {
+	var args []string
+	if event != "" {
+		args = append(args, "--"+event)
+	}
+	if o.Threads {
+		args = append(args, "--threads")
+	}
+	if o.Simple {
+		args = append(args, "--simple")
+	}
+	if o.Dotted {
+		args = append(args, "--dot")
+	}
+	if o.Include != "" {
+		args = append(args, "--include", o.Include)
+	}
+	if o.Exclude != "" {
+		args = append(args, "--exclude", o.Exclude)
+	}
+	// jfr-converter принимает время в миллисекундах от начала записи
+	if o.From > 0 {
+		args = append(args, "--from", strconv.FormatInt(o.From.Milliseconds(), 10))
+	}
+	if o.To > 0 {
+		args = append(args, "--to", strconv.FormatInt(o.To.Milliseconds(), 10))
+	}
+	if o.Title != "" {
+		args = append(args, "--title", o.title(event))
+	}
+	return args
+}
+
+// convertFile конвертирует JFR с диска во все форматы; результаты пишутся рядом с исходным файлом
+func convertFile(ctx context.Context, jfrPath string, formats []string, opts ConvertOptions, backend string, timeout time.Duration) ([]conversionResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(jfrPath); err != nil {
+		return nil, err
+	}
+
+	workDir, err := createRecordingWorkDir()
+	if err != nil {
+		return nil, fmt.Errorf("creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	results := convertAll(ctx, jfrPath, workDir, formats, recordingBase(jfrPath), backend, opts, timeout)
+
+	// Новые файлы попадают в sidecar записи, если он есть
+	var files []string
+	for _, r := range results {
+		if r.err == nil {
+			files = append(files, r.path)
+		}
+	}
+	if err := addMetadataFiles(jfrPath, files); err != nil {
+		log.Printf("Не удалось обновить метаданные записи: %v", err)
+	}
+	return results, nil
+}
+
+// recordingBase путь записи без расширения: "x.jfr" и сжатый "x.jfr.gz" дают "x"
+func recordingBase(path string) string {
+	path = strings.TrimSuffix(path, ".gz")
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// conversionResult результат конвертации в один формат для одного вида событий
+type conversionResult struct {
+	format string
+	event  string // пусто - вид событий выбран по файлу
+	path   string
+	err    error
+}
+
+// label формат и, если выбран явно, вид событий: "html" или "html/alloc"
+func (r conversionResult) label() string {
+	if r.event == "" {
+		return r.format
+	}
+	return r.format + "/" + r.event
+}
+
+// convertAll конвертирует JFR во все форматы и виды событий параллельно, у каждой конвертации свой таймаут.
+// Ошибка одной конвертации не мешает остальным.
+func convertAll(ctx context.Context, jfrPath, workDir string, formats []string, outputBase, backend string, opts ConvertOptions, timeout time.Duration) []conversionResult {
+	selected := map[string]bool{}
+	for _, format := range formats {
+		selected[format] = true
+	}
+
+	var results []conversionResult
+	for _, format := range formats {
+		for _, event := range opts.events() {
+			results = append(results, conversionResult{format: format, event: event})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(result *conversionResult) {
+			defer wg.Done()
+
+			// Явно выбранный вид событий добавляется к имени, чтобы результаты разных видов не перезаписывали друг друга.
+			// html и heatmap оба дают .html: при выборе обоих heatmap получает свой суффикс
+			base := outputBase
+			if result.event != "" {
+				base += "-" + result.event
+			}
+			if result.format == "heatmap" && selected["html"] {
+				base += "-heatmap"
+			}
+
+			// У каждой конвертации своя папка: jfr-converter пишет результат рядом с копией JFR
+			dir, err := os.MkdirTemp(workDir, "convert-")
+			if err != nil {
+				result.err = fmt.Errorf("converting JFR: %v", err)
+				return
+			}
+			defer os.RemoveAll(dir)
+
+			convertCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			result.path, result.err = convertJFR(convertCtx, jfrPath, dir, result.format, base, backend, opts, result.event)
+			if result.err != nil {
+				log.Printf("Конвертация в %s не удалась: %v", result.label(), result.err)
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// convertJFR конвертирует JFR в формат format. Встроенный конвертер используется, если он поддерживает
+// формат и не выбран Java-бэкенд; иначе - jfr-converter.jar, если есть Java
+func convertJFR(ctx context.Context, jfrPath, workDir, format, outputBase, backend string, opts ConvertOptions, event string) (string, error) {
+	_, native := nativeFormats[format]
+	if native && (backend != converterJava || !javaAvailable()) {
+		return convertNative(ctx, jfrPath, format, outputBase, opts, event)
+	}
+	if !javaAvailable() {
+		if format == "heatmap" {
+			// Для heatmap нужен jfr-converter; без него отдаем хотя бы flame graph
+			log.Printf("jfr-converter is unavailable, writing a flame graph instead of heatmap")
+			return convertNative(ctx, jfrPath, "html", outputBase, opts, event)
+		}
+		return "", fmt.Errorf("converting JFR: format %s needs jfr-converter.jar and Java (install a JRE or choose a format supported natively)", format)
+	}
+	return convertWithJava(ctx, jfrPath, workDir, format, outputBase, opts.javaArgs(event))
+}
+
+// convertNative конвертирует JFR встроенным конвертером; чтение прерывается по таймауту ctx
+func convertNative(ctx context.Context, jfrPath, format, outputBase string, opts ConvertOptions, event string) (string, error) {
+	readOpts, err := opts.readOptions(event)
+	if err != nil {
+		return "", fmt.Errorf("converting JFR: %v", err)
+	}
+	profile, err := ReadJFRProfile(ctx, jfrPath, readOpts)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "", fmt.Errorf("converting JFR: timed out")
+	}
+	if err != nil {
+		return "", fmt.Errorf("converting JFR: %v", err)
+	}
+
+	outputPath := outputBase + nativeFormats[format]
+	if err := writeProfileFile(profile, format, outputPath, opts.title(profile.Event)); err != nil {
+		return "", fmt.Errorf("converting JFR: %v", err)
+	}
+	return outputPath, nil
+}
+
+// writeProfileFile пишет профиль в формат, поддерживаемый встроенным конвертером
+func writeProfileFile(profile *Profile, format, outputPath, title string) error {
+	return writeFileAtomically(outputPath, func(file *os.File) error {
+		switch format {
+		case "collapsed":
+			return profile.WriteCollapsed(file)
+		case "pprof", "pb.gz":
+			return profile.WritePprof(file)
+		case "html":
+			return profile.WriteFlameGraph(file, title)
+		}
+		return fmt.Errorf("unsupported format %s", format)
+	})
+}
+
+// writeFileAtomically пишет файл через временный файл рядом, чтобы не оставлять недописанный результат
+func writeFileAtomically(path string, write func(file *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Chmod(0644) // CreateTemp создает файл только для владельца
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return moveFile(tmp.Name(), path)
+}
@@ -4,12 +4,24 @@
 package main
 
 import (
+	"fmt"
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 )
 
-var dialogProcess *exec.Cmd
+var (
+	dialogMu      sync.Mutex
+	dialogProcess *exec.Cmd
+)
+
+// setDialogProcess запоминает процесс открытого диалога (вызывается из фоновой горутины)
+func setDialogProcess(cmd *exec.Cmd) {
+	dialogMu.Lock()
+	dialogProcess = cmd
+	dialogMu.Unlock()
+}
 
 func openModalFolderDialog() (string, bool) {
 	var cmd *exec.Cmd
@@ -23,12 +35,12 @@ func openModalFolderDialog() (string, bool) {
 	}
 	
 	// Сохраняем ссылку на процесс для возможности его закрытия
-	dialogProcess = cmd
+	setDialogProcess(cmd)
 	
 	output, err := cmd.Output()
 	
 	// Очищаем ссылку после завершения
-	dialogProcess = nil
+	setDialogProcess(nil)
 	
 	if err != nil {
 		return "", false
@@ -50,8 +62,49 @@ func openModalFolderDialog() (string, bool) {
 }
 
 func closeAnyOpenDialogs() {
+	dialogMu.Lock()
+	defer dialogMu.Unlock()
 	if dialogProcess != nil && dialogProcess.Process != nil {
 		dialogProcess.Process.Kill()
 		dialogProcess = nil
 	}
-}
\ No newline at end of file
+}
+
+// runDialogCommand запускает команду диалога и возвращает выбранный путь
+func runDialogCommand(cmd *exec.Cmd) (string, bool) {
+	setDialogProcess(cmd)
+	output, err := cmd.Output()
+	setDialogProcess(nil)
+
+	if err != nil {
+		return "", false
+	}
+
+	path := strings.TrimSpace(string(output))
+	return path, path != ""
+}
+
+func openModalFileDialog(title, ext string) (string, bool) {
+	if runtime.GOOS == "darwin" {
+		// POSIX path сразу возвращает обычный путь вместо alias
+		return runDialogCommand(exec.Command("osascript", "-e", fmt.Sprintf(`POSIX path of (choose file with prompt %q)`, title)))
+	}
+
+	args := []string{"--file-selection", "--title=" + title}
+	if ext != "" {
+		args = append(args, fmt.Sprintf("--file-filter=*.%s", ext), "--file-filter=*")
+	}
+	return runDialogCommand(exec.Command("zenity", args...))
+}
+
+func saveModalFileDialog(title, defaultName, ext string) (string, bool) {
+	if runtime.GOOS == "darwin" {
+		return runDialogCommand(exec.Command("osascript", "-e", fmt.Sprintf(`POSIX path of (choose file name with prompt %q default name %q)`, title, defaultName)))
+	}
+
+	args := []string{"--file-selection", "--save", "--confirm-overwrite", "--title=" + title, "--filename=" + defaultName}
+	if ext != "" {
+		args = append(args, fmt.Sprintf("--file-filter=*.%s", ext))
+	}
+	return runDialogCommand(exec.Command("zenity", args...))
+}
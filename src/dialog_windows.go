@@ -4,12 +4,24 @@
 package main
 
 import (
+	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
 )
 
-var dialogProcess *exec.Cmd
+var (
+	dialogMu      sync.Mutex
+	dialogProcess *exec.Cmd
+)
+
+// setDialogProcess запоминает процесс открытого диалога (вызывается из фоновой горутины)
+func setDialogProcess(cmd *exec.Cmd) {
+	dialogMu.Lock()
+	dialogProcess = cmd
+	dialogMu.Unlock()
+}
 
 func openModalFolderDialog() (string, bool) {
 	// Используем PowerShell с нативным диалогом Windows (полностью скрытый процесс)
@@ -33,12 +45,12 @@ func openModalFolderDialog() (string, bool) {
 	}
 	
 	// Сохраняем ссылку на процесс для возможности его закрытия
-	dialogProcess = cmd
+	setDialogProcess(cmd)
 	
 	output, err := cmd.Output()
 	
 	// Очищаем ссылку после завершения
-	dialogProcess = nil
+	setDialogProcess(nil)
 	
 	if err != nil {
 		return "", false
@@ -49,8 +61,68 @@ func openModalFolderDialog() (string, bool) {
 }
 
 func closeAnyOpenDialogs() {
+	dialogMu.Lock()
+	defer dialogMu.Unlock()
 	if dialogProcess != nil && dialogProcess.Process != nil {
 		dialogProcess.Process.Kill()
 		dialogProcess = nil
 	}
-}
\ No newline at end of file
+}
+
+// runDialogScript запускает PowerShell-скрипт диалога и возвращает выбранный путь
+func runDialogScript(script string) (string, bool) {
+	cmd := exec.Command("powershell", "-WindowStyle", "Hidden", "-Command", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	}
+
+	setDialogProcess(cmd)
+	output, err := cmd.Output()
+	setDialogProcess(nil)
+
+	if err != nil {
+		return "", false
+	}
+
+	path := strings.TrimSpace(string(output))
+	return path, path != ""
+}
+
+// dialogFilter формирует фильтр Windows Forms для расширения файла
+func dialogFilter(ext string) string {
+	if ext == "" {
+		return "All files (*.*)|*.*"
+	}
+	return fmt.Sprintf("%s files (*.%s)|*.%s|All files (*.*)|*.*", strings.ToUpper(ext), ext, ext)
+}
+
+func openModalFileDialog(title, ext string) (string, bool) {
+	script := fmt.Sprintf(`
+		Add-Type -AssemblyName System.Windows.Forms
+		$dialog = New-Object System.Windows.Forms.OpenFileDialog
+		$dialog.Title = '%s'
+		$dialog.Filter = '%s'
+		$result = $dialog.ShowDialog()
+		if ($result -eq [System.Windows.Forms.DialogResult]::OK) {
+			Write-Output $dialog.FileName
+		}
+	`, title, dialogFilter(ext))
+	return runDialogScript(script)
+}
+
+func saveModalFileDialog(title, defaultName, ext string) (string, bool) {
+	script := fmt.Sprintf(`
+		Add-Type -AssemblyName System.Windows.Forms
+		$dialog = New-Object System.Windows.Forms.SaveFileDialog
+		$dialog.Title = '%s'
+		$dialog.Filter = '%s'
+		$dialog.FileName = '%s'
+		$dialog.OverwritePrompt = $true
+		$result = $dialog.ShowDialog()
+		if ($result -eq [System.Windows.Forms.DialogResult]::OK) {
+			Write-Output $dialog.FileName
+		}
+	`, title, dialogFilter(ext), defaultName)
+	return runDialogScript(script)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// diffTopFrames сколько фреймов показывает таблица изменений
+const diffTopFrames = 20
+
+// frameNormalizers части имен фреймов, которые меняются между запусками JVM:
+// номера и адреса лямбд и скрытых классов, номера сгенерированных аксессоров и прокси
+var frameNormalizers = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\$\$Lambda(\$\d+)?([./]0x[0-9a-f]+)?`), "$$$$Lambda"},
+	{regexp.MustCompile(`[./]0x[0-9a-f]{6,}`), ""},
+	{regexp.MustCompile(`(Generated(?:Serialization)?(?:Method|Constructor)Accessor)\d+`), "${1}"},
+	{regexp.MustCompile(`\$Proxy\d+`), "$$Proxy"},
+}
+
+// normalizeFrameName имя фрейма без частей, которые отличаются от запуска к запуску
+func normalizeFrameName(name string) string {
+	for _, n := range frameNormalizers {
+		name = n.pattern.ReplaceAllString(name, n.replacement)
+	}
+	return name
+}
+
+// FrameDelta изменение фрейма между профилями. Значения - доли от всех сэмплов своего профиля,
+// поэтому записи разной длительности сравнимы
+type FrameDelta struct {
+	Name        string
+	SelfBefore  float64
+	SelfAfter   float64
+	TotalBefore float64
+	TotalAfter  float64
+}
+
+func (f FrameDelta) SelfDelta() float64 {
+	return f.SelfAfter - f.SelfBefore
+}
+
+func (f FrameDelta) TotalDelta() float64 {
+	return f.TotalAfter - f.TotalBefore
+}
+
+// ProfileDiff сравнение двух профилей одного вида событий
+type ProfileDiff struct {
+	Event  string
+	Before int64 // всего сэмплов в базовом профиле
+	After  int64
+	Frames []FrameDelta // по имени фрейма
+
+	stacks []flameStack // стеки "после" с базовыми значениями, приведенными к общему числу сэмплов "после"
+}
+
+// DiffProfiles сравнивает нормализованные стеки двух профилей
+func DiffProfiles(before, after *Profile) *ProfileDiff {
+	d := &ProfileDiff{Event: after.Event}
+
+	type stackValues struct {
+		frames        []Frame
+		before, after int64
+	}
+	index := map[string]int{}
+	var stacks []stackValues
+	add := func(p *Profile, isAfter bool) {
+		for _, s := range p.Stacks {
+			frames := make([]Frame, len(s.Frames))
+			for i, f := range s.Frames {
+				frames[i] = Frame{Name: normalizeFrameName(f.Name), Type: f.Type}
+			}
+			key := collapsedKey(frames, false)
+			i, ok := index[key]
+			if !ok {
+				i = len(stacks)
+				index[key] = i
+				stacks = append(stacks, stackValues{frames: frames})
+			}
+			if isAfter {
+				stacks[i].after += s.Samples
+				d.After += s.Samples
+			} else {
+				stacks[i].before += s.Samples
+				d.Before += s.Samples
+			}
+		}
+	}
+	add(before, false)
+	add(after, true)
+
+	// Базовые значения масштабируются к числу сэмплов "после", чтобы ширина и цвет были сравнимы
+	scale := 1.0
+	if d.Before > 0 && d.After > 0 {
+		scale = float64(d.After) / float64(d.Before)
+	}
+	frames := map[string]*FrameDelta{}
+	frame := func(name string) *FrameDelta {
+		f, ok := frames[name]
+		if !ok {
+			f = &FrameDelta{Name: name}
+			frames[name] = f
+		}
+		return f
+	}
+	share := func(value, total int64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(value) / float64(total)
+	}
+	for _, s := range stacks {
+		d.stacks = append(d.stacks, flameStack{frames: s.frames, value: s.after, baseline: int64(math.Round(float64(s.before) * scale))})
+		if len(s.frames) == 0 {
+			continue
+		}
+		leaf := frame(s.frames[len(s.frames)-1].Name)
+		leaf.SelfBefore += share(s.before, d.Before)
+		leaf.SelfAfter += share(s.after, d.After)
+		// Рекурсивный фрейм учитывается в total один раз на стек
+		seen := map[string]bool{}
+		for _, f := range s.frames {
+			if seen[f.Name] {
+				continue
+			}
+			seen[f.Name] = true
+			fd := frame(f.Name)
+			fd.TotalBefore += share(s.before, d.Before)
+			fd.TotalAfter += share(s.after, d.After)
+		}
+	}
+	for _, f := range frames {
+		d.Frames = append(d.Frames, *f)
+	}
+	sort.Slice(d.Frames, func(i, j int) bool { return d.Frames[i].Name < d.Frames[j].Name })
+	return d
+}
+
+// Top фреймы с наибольшим по модулю изменением self или total
+func (d *ProfileDiff) Top(n int, total bool) []FrameDelta {
+	delta := func(f FrameDelta) float64 {
+		if total {
+			return f.TotalDelta()
+		}
+		return f.SelfDelta()
+	}
+	var top []FrameDelta
+	for _, f := range d.Frames {
+		if delta(f) != 0 {
+			top = append(top, f)
+		}
+	}
+	sort.SliceStable(top, func(i, j int) bool { return math.Abs(delta(top[i])) > math.Abs(delta(top[j])) })
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// formatShare доля в процентах: "12.34%"
+func formatShare(value float64) string {
+	return fmt.Sprintf("%.2f%%", 100*value)
+}
+
+// formatShareDelta изменение доли со знаком: "+1.20%"
+func formatShareDelta(value float64) string {
+	return fmt.Sprintf("%+.2f%%", 100*value)
+}
+
+// WriteTable пишет таблицы изменений self и total в текстовом виде
+func (d *ProfileDiff) WriteTable(w io.Writer, n int) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "Samples: before %d, after %d\n", d.Before, d.After)
+	for _, total := range []bool{false, true} {
+		if total {
+			fmt.Fprintf(bw, "\nTop %d changes in total time:\n", n)
+		} else {
+			fmt.Fprintf(bw, "\nTop %d changes in self time:\n", n)
+		}
+		fmt.Fprintf(bw, "%9s %9s %9s  %s\n", "before", "after", "delta", "frame")
+		for _, f := range d.Top(n, total) {
+			before, after, delta := f.SelfBefore, f.SelfAfter, f.SelfDelta()
+			if total {
+				before, after, delta = f.TotalBefore, f.TotalAfter, f.TotalDelta()
+			}
+			fmt.Fprintf(bw, "%9s %9s %9s  %s\n", formatShare(before), formatShare(after), formatShareDelta(delta), f.Name)
+		}
+	}
+	return bw.Flush()
+}
+
+// htmlTable таблица изменений для страницы flame graph
+func (d *ProfileDiff) htmlTable(n int, total bool) string {
+	var sb strings.Builder
+	caption := "self"
+	if total {
+		caption = "total"
+	}
+	fmt.Fprintf(&sb, "<table class=\"delta\"><caption>Top %d changes in %s time</caption>\n", n, caption)
+	sb.WriteString("<tr><th>Frame</th><th>Before</th><th>After</th><th>Delta</th></tr>\n")
+	for _, f := range d.Top(n, total) {
+		before, after, delta := f.SelfBefore, f.SelfAfter, f.SelfDelta()
+		if total {
+			before, after, delta = f.TotalBefore, f.TotalAfter, f.TotalDelta()
+		}
+		class := "down"
+		if delta > 0 {
+			class = "up"
+		}
+		fmt.Fprintf(&sb, "<tr><td class=\"name\" title=\"%s\">%s</td><td>%s</td><td>%s</td><td class=\"%s\">%s</td></tr>\n",
+			html.EscapeString(f.Name), html.EscapeString(f.Name), formatShare(before), formatShare(after), class, formatShareDelta(delta))
+	}
+	sb.WriteString("</table>\n")
+	return sb.String()
+}
+
+// WriteFlameGraph пишет дифференциальный flame graph: ширина - профиль "после", цвет - изменение
+// относительно базового (красный - рост, синий - снижение); под графиком таблицы изменений
+func (d *ProfileDiff) WriteFlameGraph(w io.Writer, title string) error {
+	footer := fmt.Sprintf("<p style=\"text-align: center\">Samples: before %d, after %d</p>\n", d.Before, d.After) +
+		d.htmlTable(diffTopFrames, false) + d.htmlTable(diffTopFrames, true)
+	return writeFlameGraphPage(w, title, d.stacks, true, footer)
+}
+
+// readProfileFile профиль из JFR (в том числе .jfr.gz) или collapsed-файла
+func readProfileFile(ctx context.Context, path string, opts JFRReadOptions) (*Profile, error) {
+	if ext := filepath.Ext(path); ext == ".collapsed" || ext == ".txt" {
+		p, err := ReadCollapsedProfile(path, opts.Include, opts.Exclude)
+		if err == nil {
+			p.Event = opts.Event
+		}
+		return p, err
+	}
+	return ReadJFRProfile(ctx, path, opts)
+}
+
+// diffOutputPath результат сравнения рядом с файлом "после": x-diff-y.html
+func diffOutputPath(beforePath, afterPath string) string {
+	return recordingBase(afterPath) + "-diff-" + filepath.Base(recordingBase(beforePath)) + ".html"
+}
+
+// diffFiles сравнивает две записи и пишет дифференциальный flame graph в outputPath.
+// Вид событий берется из опций или определяется по файлу "после" и используется для обоих файлов
+func diffFiles(ctx context.Context, beforePath, afterPath, outputPath string, opts ConvertOptions) (*ProfileDiff, error) {
+	event := opts.events()[0]
+	readOpts, err := opts.readOptions(event)
+	if err != nil {
+		return nil, err
+	}
+	after, err := readProfileFile(ctx, afterPath, readOpts)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", filepath.Base(afterPath), err)
+	}
+	if after.Event != "" {
+		readOpts.Event = after.Event
+	}
+	before, err := readProfileFile(ctx, beforePath, readOpts)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", filepath.Base(beforePath), err)
+	}
+
+	diff := DiffProfiles(before, after)
+	title := opts.Title
+	if title == "" {
+		title = fmt.Sprintf("%s diff: %s vs %s", flameGraphTitle(diff.Event), filepath.Base(afterPath), filepath.Base(beforePath))
+	}
+	if err := writeFileAtomically(outputPath, func(file *os.File) error {
+		return diff.WriteFlameGraph(file, title)
+	}); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
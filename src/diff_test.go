@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNormalizeFrameName(t *testing.T) {
+	tests := map[string]string{
+		"com.acme.Handler$$Lambda$57/0x0000000800ff1234.run":       "com.acme.Handler$$Lambda.run",
+		"com.acme.Handler$$Lambda.0x000000080012abcd.run":          "com.acme.Handler$$Lambda.run",
+		"com.acme.Handler$$Lambda$12.run":                          "com.acme.Handler$$Lambda.run",
+		"jdk.internal.reflect.GeneratedMethodAccessor42.invoke":    "jdk.internal.reflect.GeneratedMethodAccessor.invoke",
+		"jdk.proxy2.$Proxy17.handle":                               "jdk.proxy2.$Proxy.handle",
+		"java.lang.invoke.LambdaForm$MH/0x0000000801234567.invoke": "java.lang.invoke.LambdaForm$MH.invoke",
+		"com.acme.Work.compute":                                    "com.acme.Work.compute",
+		"com.acme.Cache$Entry.get":                                 "com.acme.Cache$Entry.get",
+	}
+	for input, want := range tests {
+		if got := normalizeFrameName(input); got != want {
+			t.Errorf("normalizeFrameName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// formatDeltas доли фреймов с точностью до 0.01%, чтобы сравнивать без погрешности float
+func formatDeltas(frames []FrameDelta, total bool) []string {
+	var lines []string
+	for _, f := range frames {
+		if total {
+			lines = append(lines, fmt.Sprintf("%s %.4f -> %.4f", f.Name, f.TotalBefore, f.TotalAfter))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s %.4f -> %.4f", f.Name, f.SelfBefore, f.SelfAfter))
+		}
+	}
+	return lines
+}
+
+func TestDiffProfiles(t *testing.T) {
+	before, err := ReadCollapsedProfile(filepath.Join("testdata", "service_before.collapsed"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := ReadCollapsedProfile(filepath.Join("testdata", "service_after.collapsed"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after.Event = "cpu"
+	d := DiffProfiles(before, after)
+
+	if d.Event != "cpu" || d.Before != 100 || d.After != 200 {
+		t.Fatalf("event/before/after = %s/%d/%d, want cpu/100/200", d.Event, d.Before, d.After)
+	}
+
+	// Лямбды с разными номерами и адресами считаются одним фреймом
+	wantSelf := []string{
+		"[main] 0.0000 -> 0.0000",
+		"com.acme.Cache.get 0.4000 -> 0.1000",
+		"com.acme.Handler$$Lambda.run 0.1000 -> 0.2000",
+		"com.acme.Json.write 0.0000 -> 0.1500",
+		"com.acme.Legacy.encode 0.1000 -> 0.0000",
+		"com.acme.Lexer.next 0.3000 -> 0.4500",
+		"com.acme.Parser.parse 0.1000 -> 0.1000",
+		"com.acme.Server.handle 0.0000 -> 0.0000",
+	}
+	if got := formatDeltas(d.Frames, false); !reflect.DeepEqual(got, wantSelf) {
+		t.Errorf("self shares =\n%q\nwant\n%q", got, wantSelf)
+	}
+	wantTotal := []string{
+		"[main] 1.0000 -> 1.0000",
+		"com.acme.Cache.get 0.4000 -> 0.1000",
+		"com.acme.Handler$$Lambda.run 0.1000 -> 0.2000",
+		"com.acme.Json.write 0.0000 -> 0.1500",
+		"com.acme.Legacy.encode 0.1000 -> 0.0000",
+		"com.acme.Lexer.next 0.3000 -> 0.4500",
+		"com.acme.Parser.parse 0.4000 -> 0.5500",
+		"com.acme.Server.handle 1.0000 -> 1.0000",
+	}
+	if got := formatDeltas(d.Frames, true); !reflect.DeepEqual(got, wantTotal) {
+		t.Errorf("total shares =\n%q\nwant\n%q", got, wantTotal)
+	}
+
+	// Самое большое изменение первым; фреймы без изменений не попадают в список
+	top := d.Top(3, false)
+	if len(top) != 3 || top[0].Name != "com.acme.Cache.get" {
+		t.Fatalf("Top(3, self) = %q, want com.acme.Cache.get first", formatDeltas(top, false))
+	}
+	if got := fmt.Sprintf("%.4f", top[0].SelfDelta()); got != "-0.3000" {
+		t.Errorf("Cache.get self delta = %s, want -0.3000", got)
+	}
+	if got := len(d.Top(100, true)); got != 6 {
+		t.Errorf("Top(100, total) has %d frames, want 6 changed frames", got)
+	}
+
+	// Базовые значения стеков приводятся к числу сэмплов "после"
+	var baselines []string
+	for _, s := range d.stacks {
+		baselines = append(baselines, fmt.Sprintf("%s %d/%d", collapsedKey(s.frames, false), s.baseline, s.value))
+	}
+	sort.Strings(baselines)
+	wantBaselines := []string{
+		"[main];com.acme.Server.handle;com.acme.Cache.get 80/20",
+		"[main];com.acme.Server.handle;com.acme.Handler$$Lambda.run 20/40",
+		"[main];com.acme.Server.handle;com.acme.Json.write 0/30",
+		"[main];com.acme.Server.handle;com.acme.Legacy.encode 20/0",
+		"[main];com.acme.Server.handle;com.acme.Parser.parse 20/20",
+		"[main];com.acme.Server.handle;com.acme.Parser.parse;com.acme.Lexer.next 60/90",
+	}
+	if !reflect.DeepEqual(baselines, wantBaselines) {
+		t.Errorf("scaled baselines =\n%q\nwant\n%q", baselines, wantBaselines)
+	}
+}
+
+func TestDiffProfilesEmptyBaseline(t *testing.T) {
+	after := collapsedProfile("cpu", "samples", "main;a 3", "main;b 1")
+	d := DiffProfiles(&Profile{}, after)
+	if d.Before != 0 || d.After != 4 {
+		t.Fatalf("before/after = %d/%d, want 0/4", d.Before, d.After)
+	}
+	for _, f := range d.Frames {
+		if f.SelfBefore != 0 || f.TotalBefore != 0 {
+			t.Errorf("%s: before shares %v/%v, want 0", f.Name, f.SelfBefore, f.TotalBefore)
+		}
+	}
+}
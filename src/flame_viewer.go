@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// flameRowHeight высота строки flame graph
+const flameRowHeight = unit.Dp(18)
+
+// flamePalette цвета по типу фрейма, как в HTML flame graph
+var flamePalette = []color.NRGBA{
+	{R: 178, G: 225, B: 178, A: 255}, // interpreted
+	{R: 80, G: 225, B: 80, A: 255},   // JIT compiled
+	{R: 80, G: 204, B: 204, A: 255},  // inlined
+	{R: 225, G: 90, B: 90, A: 255},   // native
+	{R: 200, G: 200, B: 60, A: 255},  // C++
+	{R: 225, G: 125, B: 0, A: 255},   // kernel
+	{R: 204, G: 232, B: 128, A: 255}, // C1 compiled
+}
+
+// flameNode узел дерева flame graph; корень - "all"
+type flameNode struct {
+	name     string
+	typ      FrameType
+	total    int64
+	self     int64
+	depth    int
+	parent   *flameNode
+	children []*flameNode
+}
+
+// buildFlameTree дерево вызовов из стеков профиля; дети упорядочены по имени, как в HTML
+func buildFlameTree(p *Profile) (root *flameNode, maxDepth int) {
+	root = &flameNode{name: "all"}
+	index := map[*flameNode]map[Frame]*flameNode{}
+	for _, s := range p.Stacks {
+		node := root
+		node.total += s.Samples
+		for _, f := range s.Frames {
+			key := Frame{Name: f.Name, Type: f.Type}
+			children := index[node]
+			if children == nil {
+				children = map[Frame]*flameNode{}
+				index[node] = children
+			}
+			child, ok := children[key]
+			if !ok {
+				child = &flameNode{name: f.Name, typ: f.Type, depth: node.depth + 1, parent: node}
+				children[key] = child
+				node.children = append(node.children, child)
+			}
+			child.total += s.Samples
+			node = child
+		}
+		node.self += s.Samples
+		if len(s.Frames) > maxDepth {
+			maxDepth = len(s.Frames)
+		}
+	}
+	for node := range index {
+		sort.Slice(node.children, func(i, j int) bool {
+			a, b := node.children[i], node.children[j]
+			if a.name != b.name {
+				return a.name < b.name
+			}
+			return a.typ < b.typ
+		})
+	}
+	return root, maxDepth
+}
+
+// flameRect отрисованный фрейм для поиска под указателем
+type flameRect struct {
+	node    *flameNode
+	x, y, w int
+}
+
+// FlameViewer окно просмотра flame graph внутри приложения: зум по клику, подсказка под указателем,
+// подсветка поиска и сброс зума
+type FlameViewer struct {
+	visible    bool
+	loading    bool
+	generation uint64 // результат чтения другого файла отбрасывается
+	title      string
+	path       string
+	err        string
+
+	root     *flameNode
+	zoom     *flameNode
+	hover    *flameNode
+	hoverPos f32.Point
+	maxDepth int
+	rects    []flameRect // последняя отрисовка
+
+	searchEditor widget.Editor
+	searchText   string
+	pattern      *regexp.Regexp
+	matched      string
+
+	icicle      widget.Bool // корень сверху
+	resetButton widget.Clickable
+	closeButton widget.Clickable
+	list        widget.List
+}
+
+func NewFlameViewer() *FlameViewer {
+	v := &FlameViewer{
+		list: widget.List{
+			List: layout.List{
+				Axis: layout.Vertical,
+			},
+		},
+	}
+	v.searchEditor.SingleLine = true
+	return v
+}
+
+// openFlameViewer читает запись в фоне и показывает ее flame graph
+func (a *Application) openFlameViewer(path string, opts ConvertOptions) {
+	v := a.flameViewer
+	v.visible = true
+	v.loading = true
+	v.generation++
+	v.path = path
+	v.title = filepath.Base(path)
+	v.err = ""
+	v.root, v.zoom, v.hover, v.rects = nil, nil, nil, nil
+
+	generation := v.generation
+	go func() {
+		readOpts, err := opts.readOptions(opts.events()[0])
+		var profile *Profile
+		if err == nil {
+			profile, err = readProfileFile(context.Background(), path, readOpts)
+		}
+		a.post(flameViewerLoadedEvent{generation: generation, profile: profile, title: opts.title(readOpts.Event), err: err})
+	}()
+}
+
+// flameViewerLoadedEvent запись для окна просмотра прочитана
+type flameViewerLoadedEvent struct {
+	generation uint64
+	profile    *Profile
+	title      string
+	err        error
+}
+
+func (e flameViewerLoadedEvent) apply(a *Application) {
+	v := a.flameViewer
+	if e.generation != v.generation {
+		return
+	}
+	v.loading = false
+	if e.err != nil {
+		v.err = "Error: " + e.err.Error()
+		return
+	}
+	title := e.title
+	if e.profile.Event != "" && title == flameGraphTitle("") {
+		title = flameGraphTitle(e.profile.Event)
+	}
+	v.title = title + " — " + filepath.Base(v.path)
+	v.root, v.maxDepth = buildFlameTree(e.profile)
+	v.zoom = v.root
+	v.updateMatched()
+}
+
+// updateMatched доля сэмплов в найденных фреймах без двойного учета вложенных совпадений
+func (v *FlameViewer) updateMatched() {
+	v.matched = ""
+	if v.pattern == nil || v.root == nil {
+		return
+	}
+	var total int64
+	var visit func(n *flameNode)
+	visit = func(n *flameNode) {
+		if v.matches(n) {
+			total += n.total
+			return
+		}
+		for _, c := range n.children {
+			visit(c)
+		}
+	}
+	visit(v.root)
+	v.matched = "Matched: " + v.percent(total)
+}
+
+func (v *FlameViewer) matches(n *flameNode) bool {
+	return v.pattern != nil && n.parent != nil && v.pattern.MatchString(n.name)
+}
+
+func (v *FlameViewer) percent(value int64) string {
+	if v.root == nil || v.root.total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.2f%%", 100*float64(value)/float64(v.root.total))
+}
+
+// color цвет фрейма: по типу с небольшим сдвигом по имени, чтобы соседние фреймы различались
+func (v *FlameViewer) color(n *flameNode) color.NRGBA {
+	if n.parent == nil {
+		return color.NRGBA{R: 220, G: 220, B: 220, A: 255}
+	}
+	if v.matches(n) {
+		return color.NRGBA{R: 238, G: 0, B: 238, A: 255}
+	}
+	var hash int32
+	for _, r := range n.name {
+		hash = hash*31 + r
+	}
+	shift := int(hash&31) - 16
+	c := flamePalette[FrameNative]
+	if int(n.typ) < len(flamePalette) {
+		c = flamePalette[n.typ]
+	}
+	adjust := func(v uint8) uint8 {
+		return uint8(max(0, min(255, int(v)+shift)))
+	}
+	return color.NRGBA{R: adjust(c.R), G: adjust(c.G), B: adjust(c.B), A: 255}
+}
+
+// nodeAt фрейм под точкой по последней отрисовке
+func (v *FlameViewer) nodeAt(gtx layout.Context, pos f32.Point) *flameNode {
+	x, y := int(pos.X), int(pos.Y)
+	rowHeight := gtx.Dp(flameRowHeight)
+	for i := len(v.rects) - 1; i >= 0; i-- {
+		r := v.rects[i]
+		if x >= r.x && x < r.x+r.w && y >= r.y && y < r.y+rowHeight {
+			return r.node
+		}
+	}
+	return nil
+}
+
+// tooltip текст подсказки для фрейма
+func (v *FlameViewer) tooltip(n *flameNode) string {
+	text := fmt.Sprintf("%s (%d samples, %s", n.name, n.total, v.percent(n.total))
+	if n.self > 0 {
+		text += fmt.Sprintf(", self %d", n.self)
+	}
+	return text + ")"
+}
+
+// layoutGraph рисует flame graph на всю ширину и обрабатывает указатель
+func (v *FlameViewer) layoutGraph(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	rowHeight := gtx.Dp(flameRowHeight)
+	width := gtx.Constraints.Max.X
+	height := (v.maxDepth + 1) * rowHeight
+	size := image.Pt(width, height)
+
+	// События обрабатываются по прошлой отрисовке: раскладка та же, пока не изменились зум и размер
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: v, Kinds: pointer.Move | pointer.Press | pointer.Leave})
+		if !ok {
+			break
+		}
+		e, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Kind {
+		case pointer.Move:
+			v.hover = v.nodeAt(gtx, e.Position)
+			v.hoverPos = e.Position
+		case pointer.Press:
+			if n := v.nodeAt(gtx, e.Position); n != nil && e.Buttons == pointer.ButtonPrimary {
+				v.zoom = n
+			}
+		case pointer.Leave:
+			v.hover = nil
+		}
+	}
+
+	area := clip.Rect{Max: size}.Push(gtx.Ops)
+	event.Op(gtx.Ops, v)
+	if v.hover != nil {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+
+	y := func(depth int) int {
+		if v.icicle.Value {
+			return depth * rowHeight
+		}
+		return height - (depth+1)*rowHeight
+	}
+	v.rects = v.rects[:0]
+	minLabelWidth := gtx.Dp(24)
+	padding := gtx.Dp(3)
+	box := func(n *flameNode, x, w int, faded bool) {
+		top := y(n.depth)
+		v.rects = append(v.rects, flameRect{node: n, x: x, y: top, w: w})
+		c := v.color(n)
+		if faded {
+			c.A = 128
+		}
+		paint.FillShape(gtx.Ops, c, clip.Rect{Min: image.Pt(x, top), Max: image.Pt(x+max(w-1, 1), top+rowHeight-1)}.Op())
+		if w <= minLabelWidth {
+			return
+		}
+		offset := op.Offset(image.Pt(x+padding, top)).Push(gtx.Ops)
+		lgtx := gtx
+		lgtx.Constraints = layout.Exact(image.Pt(w-2*padding, rowHeight-1))
+		label := material.Label(th, unit.Sp(11), n.name)
+		label.MaxLines = 1
+		label.Color = color.NRGBA{A: 255}
+		layout.W.Layout(lgtx, label.Layout)
+		offset.Pop()
+	}
+
+	// Предки зума рисуются на всю ширину и приглушенно
+	for n := v.zoom.parent; n != nil; n = n.parent {
+		box(n, 0, width, true)
+	}
+	scale := 0.0
+	if v.zoom.total > 0 {
+		scale = float64(width) / float64(v.zoom.total)
+	}
+	var visit func(n *flameNode, x float64)
+	visit = func(n *flameNode, x float64) {
+		w := float64(n.total) * scale
+		box(n, int(x), int(x+w)-int(x), false)
+		childX := x
+		for _, c := range n.children {
+			if float64(c.total)*scale >= 0.5 {
+				visit(c, childX)
+			}
+			childX += float64(c.total) * scale
+		}
+	}
+	visit(v.zoom, 0)
+
+	if v.hover != nil {
+		v.drawTooltip(gtx, th, size)
+	}
+	area.Pop()
+	return layout.Dimensions{Size: size}
+}
+
+// drawTooltip подсказка рядом с указателем, не выходящая за границы графа
+func (v *FlameViewer) drawTooltip(gtx layout.Context, th *material.Theme, size image.Point) {
+	tgtx := gtx
+	tgtx.Constraints = layout.Constraints{Max: image.Pt(min(size.X, gtx.Dp(600)), size.Y)}
+	macro := op.Record(gtx.Ops)
+	dims := layout.UniformInset(unit.Dp(4)).Layout(tgtx, func(gtx layout.Context) layout.Dimensions {
+		label := material.Label(th, unit.Sp(12), v.tooltip(v.hover))
+		label.Color = color.NRGBA{R: 30, G: 30, B: 30, A: 255}
+		return label.Layout(gtx)
+	})
+	call := macro.Stop()
+
+	offset := gtx.Dp(12)
+	pos := image.Pt(int(v.hoverPos.X)+offset, int(v.hoverPos.Y)+offset)
+	pos.X = max(0, min(pos.X, size.X-dims.Size.X))
+	if pos.Y+dims.Size.Y > size.Y {
+		pos.Y = max(0, int(v.hoverPos.Y)-offset-dims.Size.Y)
+	}
+	stack := op.Offset(pos).Push(gtx.Ops)
+	paint.FillShape(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 225, A: 255}, clip.Rect{Max: dims.Size}.Op())
+	call.Add(gtx.Ops)
+	stack.Pop()
+}
+
+// drawFlameViewer отрисовывает окно просмотра flame graph поверх основного UI
+func (a *Application) drawFlameViewer(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	v := a.flameViewer
+	if v == nil || !v.visible {
+		return layout.Dimensions{}
+	}
+
+	// Обработка кликов до отрисовки: кнопки могут закрыть окно
+	for v.closeButton.Clicked(gtx) {
+		v.visible = false
+		v.generation++ // результат незавершенного чтения больше не нужен
+		v.root, v.zoom, v.hover, v.rects = nil, nil, nil, nil
+	}
+	for v.resetButton.Clicked(gtx) {
+		v.zoom = v.root
+	}
+	if !v.visible {
+		return layout.Dimensions{}
+	}
+	if text := v.searchEditor.Text(); text != v.searchText {
+		v.searchText = text
+		v.pattern = nil
+		v.matched = ""
+		if text != "" {
+			pattern, err := regexp.Compile(text)
+			if err != nil {
+				v.matched = "Invalid pattern"
+			} else {
+				v.pattern = pattern
+				v.updateMatched()
+			}
+		}
+	}
+	v.list.ScrollToEnd = !v.icicle.Value // в обычном режиме корень снизу
+
+	button := func(gtx layout.Context, click *widget.Clickable, label string, background, fg color.NRGBA) layout.Dimensions {
+		if click.Hovered() {
+			pointer.CursorPointer.Add(gtx.Ops)
+		}
+		btn := material.Button(th, click, label)
+		btn.Background = background
+		btn.Color = fg
+		btn.TextSize = unit.Sp(12)
+		btn.Inset = layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}
+		return btn.Layout(gtx)
+	}
+	light := color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+	dark := color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+
+	header := func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				label := material.Label(th, unit.Sp(18), v.title)
+				label.MaxLines = 1
+				return label.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx, material.CheckBox(th, &v.icicle, "Icicle").Layout)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return button(gtx, &v.resetButton, "Reset Zoom", light, dark)
+				})
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return button(gtx, &v.closeButton, "Close", light, dark)
+			}),
+		)
+	}
+	search := func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				return layout.Background{}.Layout(gtx,
+					func(gtx layout.Context) layout.Dimensions {
+						defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+						paint.Fill(gtx.Ops, color.NRGBA{R: 248, G: 248, B: 248, A: 255})
+						return layout.Dimensions{Size: gtx.Constraints.Min}
+					},
+					func(gtx layout.Context) layout.Dimensions {
+						return layout.UniformInset(unit.Dp(6)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							e := material.Editor(th, &v.searchEditor, "Search (regexp)...")
+							e.TextSize = unit.Sp(14)
+							e.HintColor = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+							return e.Layout(gtx)
+						})
+					},
+				)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				if v.matched == "" {
+					return layout.Dimensions{}
+				}
+				return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					label := material.Label(th, unit.Sp(12), v.matched)
+					if v.pattern == nil {
+						label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+					}
+					return label.Layout(gtx)
+				})
+			}),
+		)
+	}
+	body := func(gtx layout.Context) layout.Dimensions {
+		var message string
+		switch {
+		case v.loading:
+			message = "Reading " + filepath.Base(v.path) + "..."
+		case v.err != "":
+			message = v.err
+		case v.root == nil || v.root.total == 0:
+			message = "No samples"
+		}
+		if message != "" {
+			label := material.Label(th, unit.Sp(14), message)
+			if v.err != "" {
+				label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+			}
+			return label.Layout(gtx)
+		}
+		return material.List(th, &v.list).Layout(gtx, 1, func(gtx layout.Context, _ int) layout.Dimensions {
+			return v.layoutGraph(gtx, th)
+		})
+	}
+
+	// Создаем кликабельную область на весь экран для блокировки
+	clickable := &widget.Clickable{}
+	return material.Clickable(gtx, clickable, func(gtx layout.Context) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+		paint.Fill(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(header),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+				layout.Rigid(search),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+				layout.Flexed(1, body),
+			)
+		})
+	})
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+// flameGraphTitles заголовки flame graph по виду профиля
+var flameGraphTitles = map[string]string{
+	"cpu":   "CPU profile",
+	"wall":  "Wall clock profile",
+	"alloc": "Allocation profile",
+	"lock":  "Lock profile",
+}
+
+func flameGraphTitle(event string) string {
+	if title, ok := flameGraphTitles[event]; ok {
+		return title
+	}
+	return "Flame graph"
+}
+
+// flameStack стек flame graph: значение и, для сравнения, значение базового профиля
+type flameStack struct {
+	frames   []Frame
+	value    int64
+	baseline int64
+}
+
+// WriteFlameGraph пишет самодостаточный интерактивный HTML flame graph.
+// Вывод зависит только от профиля и заголовка: стеки и фреймы упорядочены, времени и случайных данных нет.
+func (p *Profile) WriteFlameGraph(w io.Writer, title string) error {
+	// Агрегируем стеки с учетом типов фреймов
+	index := map[string]int{}
+	var stacks []flameStack
+	for _, s := range p.Stacks {
+		key := collapsedKey(s.Frames, true)
+		i, ok := index[key]
+		if !ok {
+			i = len(stacks)
+			index[key] = i
+			stacks = append(stacks, flameStack{frames: s.Frames})
+		}
+		stacks[i].value += s.Samples
+	}
+	return writeFlameGraphPage(w, title, stacks, false, "")
+}
+
+// writeFlameGraphPage пишет страницу flame graph. С diff цвет фрейма показывает изменение относительно baseline,
+// footer - дополнительный HTML под графиком
+func writeFlameGraphPage(w io.Writer, title string, input []flameStack, diff bool, footer string) error {
+	// Сортируем, чтобы порядок не зависел от порядка событий в JFR
+	keys := make([]string, len(input))
+	order := make([]int, len(input))
+	for i := range input {
+		keys[i] = collapsedKey(input[i].frames, true)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] < keys[order[j]] })
+
+	// Фреймы нумеруются в порядке первого появления в отсортированных стеках
+	frameIDs := map[Frame]int{}
+	frames := [][]interface{}{}
+	stacks := make([][]int64, 0, len(input))
+	var baseline []int64
+	for _, i := range order {
+		s := input[i]
+		stack := []int64{s.value}
+		if diff {
+			baseline = append(baseline, s.baseline)
+		}
+		for _, f := range s.frames {
+			f.Line = 0 // строки во flame graph не показываются, фреймы одного метода объединяются
+			id, ok := frameIDs[f]
+			if !ok {
+				id = len(frames)
+				frameIDs[f] = id
+				frames = append(frames, []interface{}{f.Name, int(f.Type)})
+			}
+			stack = append(stack, int64(id))
+		}
+		stacks = append(stacks, stack)
+	}
+
+	// json.Marshal экранирует <, > и &, поэтому данные безопасно встраивать в <script>
+	framesJSON, err := json.Marshal(frames)
+	if err != nil {
+		return err
+	}
+	stacksJSON, err := json.Marshal(stacks)
+	if err != nil {
+		return err
+	}
+	baselineJSON, err := json.Marshal(baseline) // nil - обычный flame graph
+	if err != nil {
+		return err
+	}
+
+	page := strings.NewReplacer(
+		"{{title}}", html.EscapeString(title),
+		"{{frames}}", string(framesJSON),
+		"{{stacks}}", string(stacksJSON),
+		"{{baseline}}", string(baselineJSON),
+		"{{footer}}", footer,
+	).Replace(flameGraphTemplate)
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(page); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// flameGraphTemplate страница flame graph: поиск, зум по клику, reverse (стеки от листа) и icicle (корень сверху).
+// Цвета по типу фрейма как у async-profiler: Java - зеленые, inlined - бирюзовые, native - красные,
+// C++ - желтые, kernel - оранжевые. В сравнении цвет показывает изменение: красный - рост, синий - снижение.
+const flameGraphTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{title}}</title>
+<style>
+body { margin: 0; padding: 10px 10px 24px; font: 12px Verdana, sans-serif; background: #fff; color: #000; }
+h1 { margin: 4px 0 8px; font-size: 18px; font-weight: normal; text-align: center; }
+#toolbar { display: flex; align-items: center; gap: 8px; margin-bottom: 6px; }
+#toolbar button { font: inherit; padding: 2px 8px; cursor: pointer; }
+#toolbar button.on { background: #d8e8ff; }
+#search { font: inherit; width: 220px; padding: 2px 4px; }
+#matched { margin-left: auto; color: #555; }
+#canvas { width: 100%; display: block; }
+table.delta { border-collapse: collapse; margin: 16px auto 0; }
+table.delta caption { font-size: 14px; margin-bottom: 4px; }
+table.delta th, table.delta td { border: 1px solid #ddd; padding: 2px 8px; text-align: right; }
+table.delta td.name { text-align: left; max-width: 700px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+table.delta td.up { color: #c00; }
+table.delta td.down { color: #00c; }
+#status { position: fixed; left: 0; right: 0; bottom: 0; padding: 3px 10px; background: #f3f3f3; border-top: 1px solid #ddd;
+	white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+</style>
+</head>
+<body>
+<h1>{{title}}</h1>
+<div id="toolbar">
+<button id="reset" title="Reset zoom (Esc)">Reset zoom</button>
+<button id="reverse" title="Build stacks from leaf frames">Reverse</button>
+<button id="icicle" title="Draw root frames at the top">Icicle</button>
+<input id="search" type="search" placeholder="Search (regexp)..." title="Highlight matching frames (Ctrl+F)">
+<span id="matched"></span>
+</div>
+<canvas id="canvas"></canvas>
+{{footer}}
+<div id="status">&nbsp;</div>
+<script>
+'use strict';
+const frames = {{frames}};
+const stacks = {{stacks}};
+const baseline = {{baseline}};
+
+const rowHeight = 16;
+const palette = [
+	[178, 225, 178], // interpreted
+	[80, 225, 80],   // JIT compiled
+	[80, 204, 204],  // inlined
+	[225, 90, 90],   // native
+	[200, 200, 60],  // C++
+	[225, 125, 0],   // kernel
+	[204, 232, 128]  // C1 compiled
+];
+
+const canvas = document.getElementById('canvas');
+const ctx = canvas.getContext('2d');
+const statusBar = document.getElementById('status');
+const searchInput = document.getElementById('search');
+const matchedLabel = document.getElementById('matched');
+const reverseButton = document.getElementById('reverse');
+const icicleButton = document.getElementById('icicle');
+
+let reverse = false;
+let icicle = false;
+let root, zoom, maxDepth, rects = [], pattern = null;
+
+function frameName(node) {
+	return node.f < 0 ? 'all' : frames[node.f][0];
+}
+
+// Дерево из стеков; в reverse-режиме стек читается от листа к корню
+function build() {
+	const all = {f: -1, total: 0, self: 0, base: 0, depth: 0, parent: null, children: [], index: new Map()};
+	maxDepth = 0;
+	stacks.forEach((s, i) => {
+		const count = s[0], len = s.length - 1, base = baseline ? baseline[i] : 0;
+		let node = all;
+		all.total += count;
+		all.base += base;
+		for (let k = 0; k < len; k++) {
+			const f = reverse ? s[len - k] : s[k + 1];
+			let child = node.index.get(f);
+			if (!child) {
+				child = {f: f, total: 0, self: 0, base: 0, depth: node.depth + 1, parent: node, children: [], index: new Map()};
+				node.index.set(f, child);
+				node.children.push(child);
+			}
+			child.total += count;
+			child.base += base;
+			node = child;
+		}
+		node.self += count;
+		maxDepth = Math.max(maxDepth, len);
+	});
+	const sortChildren = node => {
+		node.children.sort((a, b) => {
+			const x = frameName(a), y = frameName(b);
+			return x < y ? -1 : x > y ? 1 : a.f - b.f;
+		});
+		node.children.forEach(sortChildren);
+	};
+	sortChildren(all);
+	return all;
+}
+
+function color(node) {
+	if (node.f < 0) {
+		return 'rgb(220, 220, 220)';
+	}
+	if (baseline) {
+		// Насыщенность по относительному изменению фрейма
+		const delta = node.total - node.base, max = Math.max(node.total, node.base);
+		const v = Math.round(245 - 170 * (max > 0 ? Math.min(1, Math.abs(delta) / max) : 0));
+		return delta > 0 ? 'rgb(255, ' + v + ', ' + v + ')' : delta < 0 ? 'rgb(' + v + ', ' + v + ', 255)' : 'rgb(245, 245, 245)';
+	}
+	// Небольшой разброс оттенка по имени, чтобы соседние фреймы различались
+	const name = frames[node.f][0];
+	let hash = 0;
+	for (let i = 0; i < name.length; i++) {
+		hash = (hash * 31 + name.charCodeAt(i)) | 0;
+	}
+	const shift = (hash & 31) - 16;
+	const c = palette[frames[node.f][1]] || palette[3];
+	return 'rgb(' + c.map(v => Math.max(0, Math.min(255, v + shift))).join(', ') + ')';
+}
+
+function matches(node) {
+	return pattern !== null && node.f >= 0 && pattern.test(frames[node.f][0]);
+}
+
+function percent(value) {
+	return root.total > 0 ? (100 * value / root.total).toFixed(2) + '%' : '0%';
+}
+
+function draw() {
+	const width = canvas.clientWidth;
+	const height = (maxDepth + 1) * rowHeight;
+	const ratio = window.devicePixelRatio || 1;
+	canvas.style.height = height + 'px';
+	canvas.width = Math.round(width * ratio);
+	canvas.height = Math.round(height * ratio);
+	ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+	ctx.font = '12px Verdana, sans-serif';
+	ctx.textBaseline = 'middle';
+	rects = [];
+
+	const y = depth => icicle ? depth * rowHeight : height - (depth + 1) * rowHeight;
+	const box = (node, x, w, faded) => {
+		rects.push({node: node, x: x, y: y(node.depth), w: w});
+		ctx.globalAlpha = faded ? 0.5 : 1;
+		ctx.fillStyle = matches(node) ? 'rgb(238, 0, 238)' : color(node);
+		ctx.fillRect(x, y(node.depth), w - 1, rowHeight - 1);
+		if (w > 24) {
+			const name = frameName(node);
+			const chars = Math.floor((w - 6) / 7);
+			const text = name.length > chars ? name.substring(0, Math.max(0, chars - 2)) + '..' : name;
+			ctx.fillStyle = '#000';
+			ctx.fillText(text, x + 3, y(node.depth) + rowHeight / 2);
+		}
+		ctx.globalAlpha = 1;
+	};
+
+	// Предки зума рисуются на всю ширину и приглушенно
+	for (let n = zoom.parent; n; n = n.parent) {
+		box(n, 0, width, true);
+	}
+	const scale = zoom.total > 0 ? width / zoom.total : 0;
+	const visit = (node, x) => {
+		box(node, x, node.total * scale, false);
+		let childX = x;
+		for (const child of node.children) {
+			if (child.total * scale >= 0.5) {
+				visit(child, childX);
+			}
+			childX += child.total * scale;
+		}
+	};
+	visit(zoom, 0);
+}
+
+// updateMatched доля сэмплов в найденных фреймах без двойного учета вложенных совпадений
+function updateMatched() {
+	if (pattern === null) {
+		matchedLabel.textContent = '';
+		return;
+	}
+	let total = 0;
+	const visit = node => {
+		if (matches(node)) {
+			total += node.total;
+			return;
+		}
+		node.children.forEach(visit);
+	};
+	visit(root);
+	matchedLabel.textContent = 'Matched: ' + percent(total);
+}
+
+function rebuild() {
+	root = build();
+	zoom = root;
+	updateMatched();
+	draw();
+}
+
+function findRect(event) {
+	const bounds = canvas.getBoundingClientRect();
+	const x = event.clientX - bounds.left, y = event.clientY - bounds.top;
+	for (let i = rects.length - 1; i >= 0; i--) {
+		const r = rects[i];
+		if (x >= r.x && x < r.x + r.w && y >= r.y && y < r.y + rowHeight) {
+			return r;
+		}
+	}
+	return null;
+}
+
+canvas.addEventListener('mousemove', event => {
+	const r = findRect(event);
+	canvas.style.cursor = r ? 'pointer' : 'default';
+	if (!r) {
+		statusBar.innerHTML = '&nbsp;';
+		return;
+	}
+	const node = r.node;
+	if (baseline) {
+		const delta = node.total - node.base;
+		statusBar.textContent = frameName(node) + ' (' + percent(node.total) + ', was ' + percent(node.base) +
+			', ' + (delta >= 0 ? '+' : '-') + percent(Math.abs(delta)) + ')';
+		return;
+	}
+	statusBar.textContent = frameName(node) + ' (' + node.total.toLocaleString() + ' samples, ' + percent(node.total) +
+		(node.self > 0 ? ', self ' + node.self.toLocaleString() : '') + ')';
+});
+
+canvas.addEventListener('click', event => {
+	const r = findRect(event);
+	if (r) {
+		zoom = r.node;
+		draw();
+	}
+});
+
+document.getElementById('reset').addEventListener('click', () => {
+	zoom = root;
+	draw();
+});
+
+reverseButton.addEventListener('click', () => {
+	reverse = !reverse;
+	reverseButton.classList.toggle('on', reverse);
+	rebuild();
+});
+
+icicleButton.addEventListener('click', () => {
+	icicle = !icicle;
+	icicleButton.classList.toggle('on', icicle);
+	draw();
+});
+
+searchInput.addEventListener('input', () => {
+	const query = searchInput.value;
+	pattern = null;
+	if (query !== '') {
+		try {
+			pattern = new RegExp(query);
+		} catch (e) {
+			pattern = new RegExp(query.replace(/[.*+?^${}()|[\]\\]/g, '\\$&'));
+		}
+	}
+	updateMatched();
+	draw();
+});
+
+window.addEventListener('keydown', event => {
+	if (event.key === 'Escape') {
+		zoom = root;
+		draw();
+	} else if ((event.ctrlKey || event.metaKey) && event.key === 'f') {
+		event.preventDefault();
+		searchInput.focus();
+	}
+});
+
+window.addEventListener('resize', draw);
+
+rebuild();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Эталоны обновляются командой: go test -run Golden -update
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// checkGolden рендерит вывод дважды: оба прогона должны совпасть побайтно между собой и с эталоном
+func checkGolden(t *testing.T, golden string, render func(w *bytes.Buffer) error) {
+	t.Helper()
+	var first, second bytes.Buffer
+	if err := render(&first); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if err := render(&second); err != nil {
+		t.Fatalf("second render: %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatalf("%s: output differs between two renders of the same profile", golden)
+	}
+	if *updateGolden {
+		if err := os.WriteFile(golden, first.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -run Golden -update to create it)", err)
+	}
+	if !bytes.Equal(first.Bytes(), want) {
+		t.Errorf("%s: output does not match golden file (run go test -run Golden -update and review the diff)", golden)
+	}
+}
+
+func TestFlameGraphGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.collapsed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no testdata/*.collapsed files")
+	}
+	for _, input := range inputs {
+		base := strings.TrimSuffix(input, ".collapsed")
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			profile, err := ReadCollapsedProfile(input, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, base+".html", func(w *bytes.Buffer) error {
+				return profile.WriteFlameGraph(w, "Golden: "+filepath.Base(base))
+			})
+		})
+	}
+}
+
+func TestDiffFlameGraphGolden(t *testing.T) {
+	before, err := ReadCollapsedProfile(filepath.Join("testdata", "service_before.collapsed"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := ReadCollapsedProfile(filepath.Join("testdata", "service_after.collapsed"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before.Event, after.Event = "cpu", "cpu"
+	checkGolden(t, filepath.Join("testdata", "service_diff.html"), func(w *bytes.Buffer) error {
+		return DiffProfiles(before, after).WriteFlameGraph(w, "Golden: service diff")
+	})
+}
@@ -0,0 +1,980 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// maxHistoryEntries сколько записей хранит история; старые отбрасываются
+const maxHistoryEntries = 500
+
+// Итог записи в истории
+const (
+	historyOutcomeOK      = "ok"      // JFR сохранен, все конвертации успешны
+	historyOutcomePartial = "partial" // JFR сохранен, часть конвертаций не удалась
+	historyOutcomeFailed  = "failed"  // запись не удалась
+)
+
+// HistoryEntry запись в истории: цель, параметры и результаты одной записи
+type HistoryEntry struct {
+	ID             string         `json:"id"`
+	Time           time.Time      `json:"time"`
+	Kubeconfig     string         `json:"kubeconfig"`
+	Namespace      string         `json:"namespace"`
+	Pod            string         `json:"pod"`
+	Args           string         `json:"args"`
+	Formats        []string       `json:"formats,omitempty"`
+	ConvertOptions ConvertOptions `json:"convert_options"`
+	Duration       time.Duration  `json:"duration"`
+	Folder         string         `json:"folder"`
+	JFRPath        string         `json:"jfr_path,omitempty"`
+	Files          []string       `json:"files,omitempty"` // результаты конвертации
+	MetaPath       string         `json:"meta_path,omitempty"`
+	Size           int64          `json:"size"` // суммарный размер JFR и результатов
+	Outcome        string         `json:"outcome"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// historyFile формат ~/.k8s-jprof/history.json
+type historyFile struct {
+	Version int            `json:"version"`
+	Entries []HistoryEntry `json:"entries"`
+}
+
+func getHistoryFilePath() string {
+	return filepath.Join(getConfigDir(), "history.json")
+}
+
+// readHistoryFile читает историю; записи идут от новых к старым. Нет файла - пустая история
+func readHistoryFile(path string) ([]HistoryEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []HistoryEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file historyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid history file: %v", err)
+	}
+	if file.Entries == nil {
+		file.Entries = []HistoryEntry{}
+	}
+	return file.Entries, nil
+}
+
+func writeHistoryFile(path string, entries []HistoryEntry) error {
+	data, err := json.MarshalIndent(historyFile{Version: 1, Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomically(path, func(file *os.File) error {
+		_, err := file.Write(data)
+		return err
+	})
+}
+
+// historyMu история обновляется и из UI, и из фоновой очистки по правилам хранения
+var historyMu sync.Mutex
+
+// updateHistory читает историю, изменяет ее и записывает обратно
+func updateHistory(change func(entries []HistoryEntry) []HistoryEntry) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	path := getHistoryFilePath()
+	entries, err := readHistoryFile(path)
+	if err != nil {
+		return err
+	}
+	entries = change(entries)
+	if len(entries) > maxHistoryEntries {
+		entries = entries[:maxHistoryEntries]
+	}
+	return writeHistoryFile(path, entries)
+}
+
+// newHistoryEntry запись истории по параметрам и результату записи
+func newHistoryEntry(p recordingParams, outcome recordingOutcome, err error) HistoryEntry {
+	now := time.Now()
+	entry := HistoryEntry{
+		ID:             now.Format("20060102_150405.000000000"),
+		Time:           now,
+		Kubeconfig:     p.kubeconfig,
+		Namespace:      p.namespace,
+		Pod:            p.pod,
+		Args:           p.asprofArgs,
+		Formats:        p.formats,
+		ConvertOptions: p.convertOpts,
+		Duration:       p.duration,
+		Folder:         p.outputFolder,
+		JFRPath:        outcome.jfrPath,
+		MetaPath:       outcome.metaPath,
+		Outcome:        historyOutcomeOK,
+	}
+	if err != nil {
+		entry.Outcome = historyOutcomeFailed
+		entry.Error = err.Error()
+		return entry
+	}
+
+	var failed []string
+	for _, c := range outcome.conversions {
+		if c.err != nil {
+			failed = append(failed, fmt.Sprintf("%s failed: %v", c.label(), c.err))
+			continue
+		}
+		entry.Files = append(entry.Files, c.path)
+	}
+	entry.Files = append(entry.Files, outcome.summaryFiles...)
+	if len(failed) > 0 {
+		entry.Outcome = historyOutcomePartial
+		entry.Error = strings.Join(failed, "; ")
+	}
+	entry.Size = entry.diskSize()
+	return entry
+}
+
+// paths все файлы записи: JFR, результаты конвертации и sidecar с метаданными
+func (e HistoryEntry) paths() []string {
+	var paths []string
+	if e.JFRPath != "" {
+		paths = append(paths, e.JFRPath)
+	}
+	paths = append(paths, e.Files...)
+	if e.MetaPath != "" {
+		paths = append(paths, e.MetaPath)
+	}
+	return paths
+}
+
+// diskSize суммарный размер существующих файлов записи
+func (e HistoryEntry) diskSize() int64 {
+	var size int64
+	for _, path := range e.paths() {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// htmlPath первый HTML среди результатов
+func (e HistoryEntry) htmlPath() string {
+	for _, path := range e.Files {
+		if filepath.Ext(path) == ".html" {
+			return path
+		}
+	}
+	return ""
+}
+
+// matches подходит ли запись под строку поиска: цель, аргументы, форматы и имена файлов
+func (e HistoryEntry) matches(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+	fields := []string{e.Kubeconfig, e.Namespace, e.Pod, e.Args, strings.Join(e.Formats, ","), e.Outcome, e.Error}
+	for _, path := range e.paths() {
+		fields = append(fields, filepath.Base(path))
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSize размер файла для списка: "512 B", "1.4 MB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// recordHistoryEntry добавляет завершенную запись в историю. Вызывается из фоновой горутины записи
+func recordHistoryEntry(entry HistoryEntry) {
+	if err := updateHistory(func(entries []HistoryEntry) []HistoryEntry {
+		return append([]HistoryEntry{entry}, entries...)
+	}); err != nil {
+		log.Printf("Не удалось сохранить историю записей: %v", err)
+	}
+}
+
+// addHistoryFiles добавляет результаты повторной конвертации к записям с этим JFR
+func (a *Application) addHistoryFiles(jfrPath string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	if err := updateHistory(func(entries []HistoryEntry) []HistoryEntry {
+		for i := range entries {
+			if entries[i].JFRPath != jfrPath {
+				continue
+			}
+			for _, file := range files {
+				known := false
+				for _, existing := range entries[i].Files {
+					known = known || existing == file
+				}
+				if !known {
+					entries[i].Files = append(entries[i].Files, file)
+				}
+			}
+			entries[i].Size = entries[i].diskSize()
+		}
+		return entries
+	}); err != nil {
+		log.Printf("Не удалось обновить историю записей: %v", err)
+	}
+	if a.historyPanel != nil && a.historyPanel.visible {
+		a.historyPanel.reload()
+	}
+}
+
+// Фильтры истории по итогу записи
+var historyFilters = []struct {
+	name    string
+	label   string
+	matches func(e HistoryEntry) bool
+}{
+	{"all", "All", func(e HistoryEntry) bool { return true }},
+	{"saved", "Saved", func(e HistoryEntry) bool { return e.Outcome != historyOutcomeFailed }},
+	{"failed", "Failed", func(e HistoryEntry) bool { return e.Outcome != historyOutcomeOK }},
+}
+
+// historyRow кнопки одной записи в списке
+type historyRow struct {
+	openButton    widget.Clickable
+	folderButton  widget.Clickable
+	convertButton widget.Clickable
+	diffButton    widget.Clickable
+	viewButton    widget.Clickable
+	rerunButton   widget.Clickable
+	deleteButton  widget.Clickable
+	mergeCheck    widget.Bool // запись выбрана для объединения
+}
+
+// HistoryPanel окно истории записей: поиск, фильтры и действия с результатами
+type HistoryPanel struct {
+	visible bool
+	entries []HistoryEntry
+	missing map[string]bool // записи, у которых JFR больше нет на диске
+	rows    map[string]*historyRow
+	message string
+
+	searchEditor     widget.Editor
+	filter           int // индекс в historyFilters
+	filterButtons    []widget.Clickable
+	currentNamespace widget.Bool // только записи текущего kubeconfig/namespace
+	confirmDelete    string      // ID записи, ожидающей подтверждения удаления
+	diffBaseline     string      // ID базовой записи для сравнения
+	mergeButton      widget.Clickable
+	mergeNormalize   widget.Bool // приводить записи к одному числу сэмплов
+	merging          bool
+	rerunPending     bool // проверяются права для повтора записи
+
+	retention *RetentionForm // раздел правил хранения
+
+	closeButton widget.Clickable
+	list        widget.List
+}
+
+func NewHistoryPanel() *HistoryPanel {
+	return &HistoryPanel{
+		rows:          map[string]*historyRow{},
+		missing:       map[string]bool{},
+		filterButtons: make([]widget.Clickable, len(historyFilters)),
+		retention:     NewRetentionForm(),
+		searchEditor: widget.Editor{
+			SingleLine: true,
+		},
+		list: widget.List{
+			List: layout.List{
+				Axis: layout.Vertical,
+			},
+		},
+	}
+}
+
+// reload перечитывает историю с диска и проверяет, какие файлы еще существуют
+func (p *HistoryPanel) reload() {
+	p.setSnapshot(loadHistorySnapshot())
+}
+
+// historySnapshot история с отметками отсутствующих JFR; читается в фоне, применяется в UI-горутине
+type historySnapshot struct {
+	entries []HistoryEntry
+	missing map[string]bool
+	err     error
+}
+
+func loadHistorySnapshot() historySnapshot {
+	entries, err := readHistoryFile(getHistoryFilePath())
+	if err != nil {
+		return historySnapshot{err: err}
+	}
+	s := historySnapshot{entries: entries, missing: map[string]bool{}}
+	for _, e := range entries {
+		if e.JFRPath == "" {
+			continue
+		}
+		if _, err := os.Stat(e.JFRPath); err != nil {
+			s.missing[e.ID] = true
+		}
+	}
+	return s
+}
+
+// setSnapshot показывает прочитанную историю; только состояние в памяти
+func (p *HistoryPanel) setSnapshot(s historySnapshot) {
+	if s.err != nil {
+		p.entries = nil
+		p.message = "Error: " + s.err.Error()
+		return
+	}
+	p.entries = s.entries
+	p.missing = s.missing
+}
+
+func (p *HistoryPanel) row(id string) *historyRow {
+	r, ok := p.rows[id]
+	if !ok {
+		r = &historyRow{}
+		p.rows[id] = r
+	}
+	return r
+}
+
+// visibleEntries записи под текущие поиск и фильтры
+func (p *HistoryPanel) visibleEntries(a *Application) []HistoryEntry {
+	var kubeconfig, namespace string
+	if a.kubeconfigSelector != nil && a.namespaceSelector != nil {
+		kubeconfig = a.kubeconfigSelector.GetSelectedConfig()
+		namespace = a.namespaceSelector.GetSelectedNamespace()
+	}
+	query := p.searchEditor.Text()
+	var visible []HistoryEntry
+	for _, e := range p.entries {
+		if !historyFilters[p.filter].matches(e) || !e.matches(query) {
+			continue
+		}
+		if p.currentNamespace.Value && (e.Kubeconfig != kubeconfig || e.Namespace != namespace) {
+			continue
+		}
+		visible = append(visible, e)
+	}
+	return visible
+}
+
+// openHistory показывает окно истории
+func (a *Application) openHistory() {
+	if a.isRecording || a.isChoosingFolder {
+		return
+	}
+	a.closeAllSelectors()
+	p := a.historyPanel
+	p.visible = true
+	p.message = ""
+	p.confirmDelete = ""
+	p.reload()
+}
+
+// removeRecordingFiles удаляет файлы записи с диска; уже удаленные файлы не считаются ошибкой
+func removeRecordingFiles(entry HistoryEntry) error {
+	var failed []string
+	for _, path := range entry.paths() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// removeHistoryEntries убирает записи из истории по ID
+func removeHistoryEntries(ids map[string]bool) error {
+	return updateHistory(func(entries []HistoryEntry) []HistoryEntry {
+		kept := entries[:0]
+		for _, e := range entries {
+			if !ids[e.ID] {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	})
+}
+
+// deleteHistoryEntry удаляет файлы записи с диска и саму запись из истории
+func (a *Application) deleteHistoryEntry(entry HistoryEntry) {
+	p := a.historyPanel
+	if err := removeRecordingFiles(entry); err != nil {
+		// Запись остается в истории, пока не удалены все ее файлы
+		p.message = "Error: " + err.Error()
+		return
+	}
+	if err := removeHistoryEntries(map[string]bool{entry.ID: true}); err != nil {
+		p.message = "Error: " + err.Error()
+		return
+	}
+	delete(p.rows, entry.ID)
+	p.message = fmt.Sprintf("Deleted %s", entry.target())
+	p.reload()
+}
+
+// reconvertHistoryEntry открывает окно конвертации для JFR записи с ее форматами и опциями
+func (a *Application) reconvertHistoryEntry(entry HistoryEntry) {
+	a.convertPanel.show(entry.JFRPath, entry.Formats, entry.ConvertOptions)
+}
+
+// diffHistoryEntry первый клик выбирает базовую запись, клик по другой записи сравнивает ее с базовой,
+// повторный клик по базовой снимает выбор
+func (a *Application) diffHistoryEntry(entry HistoryEntry) {
+	p := a.historyPanel
+	switch p.diffBaseline {
+	case entry.ID:
+		p.diffBaseline = ""
+		p.message = ""
+		return
+	case "":
+		p.diffBaseline = entry.ID
+		p.message = "Baseline: " + entry.target() + ". Choose a recording to compare with it"
+		return
+	}
+	var baseline *HistoryEntry
+	for i := range p.entries {
+		if p.entries[i].ID == p.diffBaseline {
+			baseline = &p.entries[i]
+		}
+	}
+	if baseline == nil || p.missing[baseline.ID] {
+		p.diffBaseline = ""
+		p.message = "Error: baseline recording is no longer available"
+		return
+	}
+	p.message = "Comparing " + filepath.Base(entry.JFRPath) + " with " + filepath.Base(baseline.JFRPath) + "..."
+	opts := entry.ConvertOptions
+	opts.Title = "" // заголовок записи не подходит для сравнения
+	a.startDiff(baseline.JFRPath, entry.JFRPath, opts, 0, true)
+}
+
+// mergeSelection записи, выбранные для объединения, в порядке истории
+func (p *HistoryPanel) mergeSelection() []HistoryEntry {
+	var selected []HistoryEntry
+	for _, e := range p.entries {
+		if r, ok := p.rows[e.ID]; ok && r.mergeCheck.Value && !p.missing[e.ID] && e.Outcome != historyOutcomeFailed {
+			selected = append(selected, e)
+		}
+	}
+	return selected
+}
+
+// mergeHistoryEntries объединяет выбранные записи в один профиль в папке первой из них.
+// Форматы и опции - выбранные для записи; otlp требует одного JFR и пропускается
+func (a *Application) mergeHistoryEntries() {
+	p := a.historyPanel
+	selected := p.mergeSelection()
+	if len(selected) < 2 {
+		p.message = "Error: select at least two recordings to merge"
+		return
+	}
+	var formats []string
+	if a.formatSelector != nil {
+		for _, f := range a.formatSelector.GetSelectedFormats() {
+			if _, ok := nativeFormats[f]; ok || f == "heatmap" {
+				formats = append(formats, f)
+			}
+		}
+	}
+	if len(formats) == 0 {
+		formats = []string{"html"}
+	}
+	sources := make([]MergeSource, len(selected))
+	for i, e := range selected {
+		sources[i] = MergeSource{Path: e.JFRPath, Weight: 1}
+	}
+	opts := a.convertOptions
+	normalize := p.mergeNormalize.Value
+
+	p.merging = true
+	p.message = fmt.Sprintf("Merging %d recordings...", len(sources))
+	go func() {
+		results, _, err := mergeFiles(context.Background(), sources, mergeOutputBase(sources, time.Now()), formats, opts, normalize)
+		a.post(mergeFinishedEvent{results: results, err: err})
+	}()
+}
+
+// mergeFinishedEvent объединение записей из истории завершено
+type mergeFinishedEvent struct {
+	results []conversionResult
+	err     error
+}
+
+func (e mergeFinishedEvent) apply(a *Application) {
+	p := a.historyPanel
+	p.merging = false
+	if e.err != nil {
+		p.message = "Error: " + e.err.Error()
+		return
+	}
+	var saved, failed []string
+	htmlPath, folder := "", ""
+	for _, r := range e.results {
+		if r.err != nil {
+			failed = append(failed, fmt.Sprintf("%s failed: %v", r.label(), r.err))
+			continue
+		}
+		saved = append(saved, filepath.Base(r.path))
+		folder = filepath.Dir(r.path)
+		if htmlPath == "" && filepath.Ext(r.path) == ".html" {
+			htmlPath = r.path
+		}
+	}
+	p.message = ""
+	if len(saved) > 0 {
+		p.message = fmt.Sprintf("Saved %s to %s", strings.Join(saved, ", "), folder)
+		for _, r := range p.rows {
+			r.mergeCheck.Value = false
+		}
+	}
+	if len(failed) > 0 {
+		if p.message != "" {
+			p.message += " | "
+		}
+		p.message += "Error: " + strings.Join(failed, "; ")
+	}
+	if htmlPath != "" {
+		go a.openInBrowser(htmlPath)
+	}
+}
+
+// rerunHistoryEntry повторяет запись с теми же целью, аргументами, форматами и папкой.
+// Как и Start, запись запускается только после проверки прав: для выбранного в селекторах пода
+// используется его проверка, для другого пода проверка выполняется перед запуском
+func (a *Application) rerunHistoryEntry(entry HistoryEntry) error {
+	if a.isRecording {
+		return fmt.Errorf("a recording is already running")
+	}
+	if a.historyPanel.rerunPending {
+		return fmt.Errorf("permissions for another re-run are being checked")
+	}
+	opts, err := ParseAsprofArgs(entry.Args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	if errs, _ := opts.Validate(a.version); len(errs) > 0 {
+		return fmt.Errorf("invalid arguments: %s", strings.Join(errs, "; "))
+	}
+	if _, err := os.Stat(kubeconfigPath(entry.Kubeconfig)); err != nil {
+		return fmt.Errorf("kubeconfig %s is not available", entry.Kubeconfig)
+	}
+
+	params := recordingParams{
+		kubeconfig:   entry.Kubeconfig,
+		namespace:    entry.Namespace,
+		pod:          entry.Pod,
+		asprofArgs:   entry.Args,
+		version:      a.version,
+		profilerTar:  a.profilerPath,
+		outputFolder: entry.Folder,
+		formats:      entry.Formats,
+		duration:     opts.RecordingDuration(),
+		timeouts:     a.timeouts,
+		converter:    a.converterBackend,
+		convertOpts:  entry.ConvertOptions,
+		naming:       a.naming,
+		event:        opts.Event,
+		preset:       a.presetName(entry.Args),
+	}
+
+	target := preflightTarget{kubeconfig: entry.Kubeconfig, namespace: entry.Namespace, pod: entry.Pod}
+	if target == a.currentPreflightTarget() {
+		if a.preflight.running {
+			return fmt.Errorf("permissions are still being checked, try again in a moment")
+		}
+		if !a.preflightPassed() {
+			return fmt.Errorf("missing permissions: %s", strings.Join(missingPermissions(a.preflight.checks), ", "))
+		}
+		a.historyPanel.visible = false
+		a.launchRecording(params)
+		return nil
+	}
+
+	a.historyPanel.rerunPending = true
+	a.historyPanel.message = "Checking permissions for " + entry.target() + "..."
+	checks := preflightChecks(target.pod)
+	timeout := a.timeouts.List
+	go func() {
+		runPreflightChecks(context.Background(), target, checks, timeout)
+		a.post(rerunPreflightEvent{params: params, checks: checks})
+	}()
+	return nil
+}
+
+// rerunPreflightEvent проверка прав для повтора записи с другим подом завершена
+type rerunPreflightEvent struct {
+	params recordingParams
+	checks []preflightCheck
+}
+
+func (e rerunPreflightEvent) apply(a *Application) {
+	p := a.historyPanel
+	p.rerunPending = false
+	p.message = ""
+	if a.isRecording {
+		p.message = "Error: a recording is already running"
+		return
+	}
+	if missing := missingPermissions(e.checks); len(missing) > 0 {
+		var details []string
+		for _, check := range e.checks {
+			if check.required && check.status != preflightAllowed && check.detail != "" {
+				details = append(details, check.label+": "+check.detail)
+			}
+		}
+		if len(details) == 0 {
+			details = missing
+		}
+		p.message = "Error: missing permissions: " + strings.Join(details, "; ")
+		return
+	}
+	p.visible = false
+	a.launchRecording(e.params)
+}
+
+// target цель записи для списка: "kubeconfig / namespace / pod"
+func (e HistoryEntry) target() string {
+	return fmt.Sprintf("%s / %s / %s", e.Kubeconfig, e.Namespace, e.Pod)
+}
+
+// summary вторая строка записи: время, аргументы, форматы и размер
+func (e HistoryEntry) summary() string {
+	parts := []string{e.Time.Local().Format("2006-01-02 15:04:05"), e.Args}
+	if len(e.Formats) > 0 {
+		parts = append(parts, strings.Join(e.Formats, ", "))
+	}
+	if e.Outcome != historyOutcomeFailed {
+		parts = append(parts, formatSize(e.Size))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// drawHistoryPanel отрисовывает окно истории поверх основного UI
+func (a *Application) drawHistoryPanel(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	p := a.historyPanel
+	if p == nil || !p.visible {
+		return layout.Dimensions{}
+	}
+
+	// Обработка кликов до отрисовки: действия могут закрыть окно или изменить список
+	for p.closeButton.Clicked(gtx) {
+		p.visible = false
+	}
+	a.handleRetentionClicks(gtx)
+	for p.mergeButton.Clicked(gtx) {
+		if !p.merging {
+			a.mergeHistoryEntries()
+		}
+	}
+	for i := range p.filterButtons {
+		for p.filterButtons[i].Clicked(gtx) {
+			p.filter = i
+		}
+	}
+	for _, e := range p.entries {
+		entry := e
+		r := p.row(entry.ID)
+		for r.openButton.Clicked(gtx) {
+			go a.openInBrowser(entry.htmlPath())
+		}
+		for r.folderButton.Clicked(gtx) {
+			go a.openFolder(filepath.Dir(entry.JFRPath))
+		}
+		for r.convertButton.Clicked(gtx) {
+			a.reconvertHistoryEntry(entry)
+		}
+		for r.viewButton.Clicked(gtx) {
+			a.openFlameViewer(entry.JFRPath, entry.ConvertOptions)
+		}
+		for r.diffButton.Clicked(gtx) {
+			a.diffHistoryEntry(entry)
+		}
+		for r.rerunButton.Clicked(gtx) {
+			if err := a.rerunHistoryEntry(entry); err != nil {
+				p.message = "Error: " + err.Error()
+			}
+		}
+		for r.deleteButton.Clicked(gtx) {
+			if p.confirmDelete == entry.ID {
+				p.confirmDelete = ""
+				a.deleteHistoryEntry(entry)
+			} else {
+				p.confirmDelete = entry.ID
+			}
+		}
+	}
+	if !p.visible {
+		return layout.Dimensions{}
+	}
+
+	button := func(gtx layout.Context, click *widget.Clickable, label string, background color.NRGBA, fg color.NRGBA) layout.Dimensions {
+		if click.Hovered() {
+			pointer.CursorPointer.Add(gtx.Ops)
+		}
+		btn := material.Button(th, click, label)
+		btn.Background = background
+		btn.Color = fg
+		btn.TextSize = unit.Sp(12)
+		btn.Inset = layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}
+		return btn.Layout(gtx)
+	}
+	light := color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+	dark := color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	entries := p.visibleEntries(a)
+	mergeCount := len(p.mergeSelection())
+	rows := []layout.Widget{
+		// Заголовок
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return material.Label(th, unit.Sp(20), "Recording History").Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if mergeCount < 2 {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return material.CheckBox(th, &p.mergeNormalize, "Normalize").Layout(gtx)
+					})
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if mergeCount < 2 {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						if p.merging {
+							return button(gtx, &p.mergeButton, "Merging...", color.NRGBA{R: 200, G: 200, B: 200, A: 255}, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+						}
+						return button(gtx, &p.mergeButton, fmt.Sprintf("Merge Selected (%d)", mergeCount), color.NRGBA{R: 76, G: 175, B: 80, A: 255}, white)
+					})
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						if p.retention.expanded {
+							return button(gtx, &p.retention.toggleButton, "Retention", color.NRGBA{R: 33, G: 150, B: 243, A: 255}, white)
+						}
+						return button(gtx, &p.retention.toggleButton, "Retention", light, dark)
+					})
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return button(gtx, &p.closeButton, "Close", light, dark)
+				}),
+			)
+		},
+		// Поиск и фильтры
+		func(gtx layout.Context) layout.Dimensions {
+			children := []layout.FlexChild{
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return layout.Background{}.Layout(gtx,
+						func(gtx layout.Context) layout.Dimensions {
+							defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+							paint.Fill(gtx.Ops, color.NRGBA{R: 248, G: 248, B: 248, A: 255})
+							return layout.Dimensions{Size: gtx.Constraints.Min}
+						},
+						func(gtx layout.Context) layout.Dimensions {
+							return layout.UniformInset(unit.Dp(6)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+								e := material.Editor(th, &p.searchEditor, "Search by pod, namespace, arguments or file...")
+								e.TextSize = unit.Sp(14)
+								e.HintColor = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+								return e.Layout(gtx)
+							})
+						},
+					)
+				}),
+			}
+			for i := range historyFilters {
+				i := i
+				children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						if p.filter == i {
+							return button(gtx, &p.filterButtons[i], historyFilters[i].label, color.NRGBA{R: 33, G: 150, B: 243, A: 255}, white)
+						}
+						return button(gtx, &p.filterButtons[i], historyFilters[i].label, light, dark)
+					})
+				}))
+			}
+			children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return material.CheckBox(th, &p.currentNamespace, "Current namespace").Layout(gtx)
+				})
+			}))
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx, children...)
+		},
+		// Сообщение о последнем действии
+		func(gtx layout.Context) layout.Dimensions {
+			if p.message == "" {
+				return layout.Dimensions{}
+			}
+			label := material.Label(th, unit.Sp(12), p.message)
+			if strings.Contains(p.message, "Error") {
+				label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+			} else {
+				label.Color = color.NRGBA{R: 50, G: 150, B: 50, A: 255}
+			}
+			return label.Layout(gtx)
+		},
+	}
+	rows = append(rows, a.retentionRows(th)...)
+	if len(entries) == 0 {
+		rows = append(rows, func(gtx layout.Context) layout.Dimensions {
+			label := material.Label(th, unit.Sp(14), "No recordings")
+			label.Color = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+			label.Alignment = text.Middle
+			return label.Layout(gtx)
+		})
+	}
+	for _, e := range entries {
+		entry := e
+		rows = append(rows, func(gtx layout.Context) layout.Dimensions {
+			return a.drawHistoryEntry(gtx, th, entry, button)
+		})
+	}
+
+	// Создаем кликабельную область на весь экран для блокировки
+	clickable := &widget.Clickable{}
+	return material.Clickable(gtx, clickable, func(gtx layout.Context) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+		paint.Fill(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		return layout.UniformInset(unit.Dp(20)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return material.List(th, &p.list).Layout(gtx, len(rows), func(gtx layout.Context, index int) layout.Dimensions {
+				return layout.Inset{Top: unit.Dp(6), Bottom: unit.Dp(6)}.Layout(gtx, rows[index])
+			})
+		})
+	})
+}
+
+// drawHistoryEntry отрисовывает одну запись: цель, параметры, итог и действия
+func (a *Application) drawHistoryEntry(gtx layout.Context, th *material.Theme, entry HistoryEntry,
+	button func(gtx layout.Context, click *widget.Clickable, label string, background color.NRGBA, fg color.NRGBA) layout.Dimensions) layout.Dimensions {
+	p := a.historyPanel
+	r := p.row(entry.ID)
+	missing := p.missing[entry.ID]
+	light := color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+	dark := color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	status := "Saved " + filepath.Base(entry.JFRPath)
+	statusColor := color.NRGBA{R: 50, G: 150, B: 50, A: 255}
+	switch {
+	case entry.Outcome == historyOutcomeFailed:
+		status = "Error: " + entry.Error
+		statusColor = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+	case missing:
+		status = "Files were moved or deleted: " + entry.JFRPath
+		statusColor = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+	case entry.Outcome == historyOutcomePartial:
+		status += " | Error: " + entry.Error
+		statusColor = color.NRGBA{R: 200, G: 120, B: 0, A: 255}
+	}
+
+	var actions []layout.FlexChild
+	if !missing && entry.Outcome != historyOutcomeFailed {
+		actions = append(actions, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, material.CheckBox(th, &r.mergeCheck, "Select").Layout)
+		}))
+	}
+	action := func(click *widget.Clickable, label string, background, fg color.NRGBA) {
+		actions = append(actions, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return button(gtx, click, label, background, fg)
+			})
+		}))
+	}
+	if !missing && entry.htmlPath() != "" {
+		action(&r.openButton, "Open in Browser", color.NRGBA{R: 33, G: 150, B: 243, A: 255}, white)
+	}
+	if !missing && entry.Outcome != historyOutcomeFailed {
+		action(&r.viewButton, "View", light, dark)
+		action(&r.folderButton, "Show Folder", light, dark)
+		action(&r.convertButton, "Convert…", light, dark)
+		switch p.diffBaseline {
+		case entry.ID:
+			action(&r.diffButton, "Baseline ✓", color.NRGBA{R: 255, G: 193, B: 7, A: 255}, dark)
+		case "":
+			action(&r.diffButton, "Set Baseline", light, dark)
+		default:
+			action(&r.diffButton, "Diff vs Baseline", light, dark)
+		}
+	}
+	action(&r.rerunButton, "Re-run", color.NRGBA{R: 76, G: 175, B: 80, A: 255}, white)
+	if p.confirmDelete == entry.ID {
+		action(&r.deleteButton, "Confirm Delete", color.NRGBA{R: 200, G: 50, B: 50, A: 255}, white)
+	} else {
+		action(&r.deleteButton, "Delete", light, dark)
+	}
+
+	return layout.Background{}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+			paint.Fill(gtx.Ops, color.NRGBA{R: 248, G: 248, B: 248, A: 255})
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return material.Label(th, unit.Sp(14), entry.target()).Layout(gtx)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						label := material.Label(th, unit.Sp(12), entry.summary())
+						label.Color = color.NRGBA{R: 80, G: 80, B: 80, A: 255}
+						return label.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						label := material.Label(th, unit.Sp(12), status)
+						label.Color = statusColor
+						return label.Layout(gtx)
+					}),
+					layout.Rigid(layout.Spacer{Height: unit.Dp(6)}.Layout),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx, actions...)
+					}),
+				)
+			})
+		},
+	)
+}
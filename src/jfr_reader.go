@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// Чтение JFR (формат 2.x), который пишет async-profiler, без Java.
+// Файл состоит из чанков; каждый чанк содержит метаданные (описание типов),
+// пулы констант (потоки, стеки, методы, классы, символы) и события.
+
+const (
+	jfrChunkHeaderSize = 68
+	jfrMetadataEventID = 0
+	jfrConstantPoolID  = 1
+)
+
+var errJFRTruncated = errors.New("unexpected end of JFR data")
+
+// jfrField поле типа из метаданных
+type jfrField struct {
+	name         string
+	typeID       int64
+	constantPool bool // значение - ссылка в пул констант
+	array        bool
+}
+
+// jfrType тип из метаданных: событие, структура или тип пула констант
+type jfrType struct {
+	id     int64
+	name   string
+	fields []jfrField
+	index  map[string]int // номер поля по имени
+}
+
+func (t *jfrType) fieldIndex(name string) int {
+	if i, ok := t.index[name]; ok {
+		return i
+	}
+	return -1
+}
+
+// jfrStruct значения полей в порядке t.fields. Значения: int64, float64, bool, string,
+// jfrStringRef, []interface{} (массив) и вложенные jfrStruct
+type jfrStruct []interface{}
+
+// jfrStringRef строка, записанная ссылкой в пул java.lang.String
+type jfrStringRef int64
+
+// jfrEvent событие чанка с разобранными полями
+type jfrEvent struct {
+	typ    *jfrType
+	fields jfrStruct
+}
+
+// jfrChunk разобранные метаданные и пулы констант одного чанка
+type jfrChunk struct {
+	startNanos     int64
+	startTicks     int64
+	ticksPerSecond int64
+
+	types  map[int64]*jfrType
+	byName map[string]*jfrType
+	pools  map[int64]map[int64]interface{} // id типа -> id константы -> значение
+}
+
+// jfrDecoder последовательное чтение сжатых целых и строк
+type jfrDecoder struct {
+	data  []byte
+	pos   int
+	err   error
+	depth int // вложенность структур: защита от рекурсивных типов в поврежденных метаданных
+}
+
+func (d *jfrDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *jfrDecoder) byte() byte {
+	if d.pos >= len(d.data) {
+		d.fail(errJFRTruncated)
+		return 0
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *jfrDecoder) bytes(n int) []byte {
+	if n < 0 || d.pos+n > len(d.data) {
+		d.fail(errJFRTruncated)
+		d.pos = len(d.data)
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+// varlong целое в формате LEB128: по 7 бит в байте, девятый байт целиком
+func (d *jfrDecoder) varlong() int64 {
+	var result uint64
+	for shift := uint(0); shift < 56; shift += 7 {
+		b := d.byte()
+		result |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return int64(result)
+		}
+	}
+	return int64(result | uint64(d.byte())<<56)
+}
+
+func (d *jfrDecoder) varint() int {
+	return int(int32(d.varlong()))
+}
+
+// count длина массива или строки с проверкой на мусорные значения
+func (d *jfrDecoder) count() int {
+	n := d.varlong()
+	if n < 0 || n > int64(len(d.data)-d.pos) {
+		d.fail(fmt.Errorf("invalid length %d at offset %d", n, d.pos))
+		return 0
+	}
+	return int(n)
+}
+
+// string строка JFR: 0 - null, 1 - пустая, 2 - ссылка в пул, 3 - UTF-8, 4 - массив char, 5 - Latin-1
+func (d *jfrDecoder) string() interface{} {
+	switch encoding := d.byte(); encoding {
+	case 0, 1:
+		return ""
+	case 2:
+		return jfrStringRef(d.varlong())
+	case 3:
+		return string(d.bytes(d.count()))
+	case 4:
+		n := d.count()
+		runes := make([]rune, n)
+		for i := range runes {
+			runes[i] = rune(d.varint())
+		}
+		return string(runes)
+	case 5:
+		raw := d.bytes(d.count())
+		runes := make([]rune, len(raw))
+		for i, b := range raw {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		d.fail(fmt.Errorf("unknown string encoding %d at offset %d", encoding, d.pos-1))
+		return ""
+	}
+}
+
+// plainString строка из метаданных (ссылки в пул там не используются)
+func (d *jfrDecoder) plainString() string {
+	s, _ := d.string().(string)
+	return s
+}
+
+// readJFRFile читает все чанки файла и передает события в handle
+func readJFRFile(path string, handle func(chunk *jfrChunk, event jfrEvent) error) error {
+	data, err := readJFRData(path)
+	if err != nil {
+		return err
+	}
+	return readJFR(data, handle)
+}
+
+// readJFRData содержимое JFR; сжатые при хранении записи (.jfr.gz) распаковываются
+func readJFRData(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// readJFR разбирает JFR из памяти. Для каждого чанка сначала читаются метаданные и пулы констант,
+// затем события в порядке записи передаются в handle
+func readJFR(data []byte, handle func(chunk *jfrChunk, event jfrEvent) error) error {
+	if len(data) < jfrChunkHeaderSize || string(data[:4]) != "FLR\x00" {
+		return fmt.Errorf("not a JFR file")
+	}
+
+	for offset := 0; offset+jfrChunkHeaderSize <= len(data); {
+		chunkData := data[offset:]
+		if string(chunkData[:4]) != "FLR\x00" {
+			return fmt.Errorf("invalid chunk header at offset %d", offset)
+		}
+		if major := binary.BigEndian.Uint16(chunkData[4:]); major < 2 {
+			return fmt.Errorf("unsupported JFR version %d.%d", major, binary.BigEndian.Uint16(chunkData[6:]))
+		}
+
+		size := int64(binary.BigEndian.Uint64(chunkData[8:]))
+		if size > 0 && size < jfrChunkHeaderSize {
+			return fmt.Errorf("chunk at offset %d: size %d is smaller than the chunk header", offset, size)
+		}
+		if size <= 0 || size > int64(len(chunkData)) {
+			// Незавершенный чанк (запись прервана) - читаем до конца файла
+			size = int64(len(chunkData))
+		}
+		chunkData = chunkData[:size]
+
+		if err := readJFRChunk(chunkData, handle); err != nil {
+			return fmt.Errorf("chunk at offset %d: %w", offset, err)
+		}
+		offset += int(size)
+	}
+	return nil
+}
+
+func readJFRChunk(data []byte, handle func(chunk *jfrChunk, event jfrEvent) error) error {
+	chunk := &jfrChunk{
+		startNanos:     int64(binary.BigEndian.Uint64(data[32:])),
+		startTicks:     int64(binary.BigEndian.Uint64(data[48:])),
+		ticksPerSecond: int64(binary.BigEndian.Uint64(data[56:])),
+		pools:          map[int64]map[int64]interface{}{},
+	}
+	cpOffset := int64(binary.BigEndian.Uint64(data[16:]))
+	metaOffset := int64(binary.BigEndian.Uint64(data[24:]))
+	if metaOffset <= 0 || metaOffset >= int64(len(data)) {
+		return fmt.Errorf("invalid metadata offset %d", metaOffset)
+	}
+
+	if err := chunk.readMetadata(data, int(metaOffset)); err != nil {
+		return fmt.Errorf("reading metadata: %w", err)
+	}
+	if err := chunk.readConstantPools(data, cpOffset); err != nil {
+		return fmt.Errorf("reading constant pools: %w", err)
+	}
+
+	// События: [размер][тип][поля...] подряд после заголовка
+	d := &jfrDecoder{data: data, pos: jfrChunkHeaderSize}
+	for d.pos < len(data) {
+		start := d.pos
+		size := d.varint()
+		typeID := d.varlong()
+		if d.err != nil {
+			return d.err
+		}
+		if size <= 0 || start+size > len(data) {
+			return fmt.Errorf("invalid event size %d at offset %d", size, start)
+		}
+		end := start + size
+
+		if typeID != jfrMetadataEventID && typeID != jfrConstantPoolID {
+			if typ, ok := chunk.types[typeID]; ok && handle != nil {
+				ed := &jfrDecoder{data: data[:end], pos: d.pos}
+				fields := chunk.readStruct(ed, typ)
+				if ed.err == nil {
+					if err := handle(chunk, jfrEvent{typ: typ, fields: fields}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		d.pos = end
+	}
+	return nil
+}
+
+// jfrElement узел дерева метаданных
+type jfrElement struct {
+	name       string
+	attributes map[string]string
+	children   []*jfrElement
+}
+
+func (c *jfrChunk) readMetadata(data []byte, offset int) error {
+	d := &jfrDecoder{data: data, pos: offset}
+	d.varint()  // размер
+	d.varlong() // тип (0)
+	d.varlong() // начало
+	d.varlong() // длительность
+	d.varlong() // id метаданных
+
+	strings := make([]string, d.count())
+	for i := range strings {
+		strings[i] = d.plainString()
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	root := readJFRElement(d, strings, 0)
+	if d.err != nil {
+		return d.err
+	}
+
+	c.types = map[int64]*jfrType{}
+	c.byName = map[string]*jfrType{}
+	var collect func(e *jfrElement)
+	collect = func(e *jfrElement) {
+		if e.name == "class" {
+			id, _ := strconv.ParseInt(e.attributes["id"], 10, 64)
+			t := &jfrType{id: id, name: e.attributes["name"], index: map[string]int{}}
+			for _, child := range e.children {
+				if child.name != "field" {
+					continue
+				}
+				fieldType, _ := strconv.ParseInt(child.attributes["class"], 10, 64)
+				t.index[child.attributes["name"]] = len(t.fields)
+				t.fields = append(t.fields, jfrField{
+					name:         child.attributes["name"],
+					typeID:       fieldType,
+					constantPool: child.attributes["constantPool"] == "true",
+					array:        child.attributes["dimension"] == "1",
+				})
+			}
+			c.types[id] = t
+			c.byName[t.name] = t
+			return
+		}
+		for _, child := range e.children {
+			collect(child)
+		}
+	}
+	collect(root)
+	return nil
+}
+
+func readJFRElement(d *jfrDecoder, strings []string, depth int) *jfrElement {
+	lookup := func() string {
+		i := d.varint()
+		if i < 0 || i >= len(strings) {
+			d.fail(fmt.Errorf("invalid string index %d", i))
+			return ""
+		}
+		return strings[i]
+	}
+
+	if depth > 64 {
+		d.fail(fmt.Errorf("metadata is nested too deeply"))
+		return &jfrElement{}
+	}
+	e := &jfrElement{name: lookup(), attributes: map[string]string{}}
+	attributes := d.count()
+	for i := 0; i < attributes && d.err == nil; i++ {
+		key := lookup()
+		e.attributes[key] = lookup()
+	}
+	children := d.count()
+	for i := 0; i < children && d.err == nil; i++ {
+		e.children = append(e.children, readJFRElement(d, strings, depth+1))
+	}
+	return e
+}
+
+// readConstantPools читает цепочку пулов констант: каждый пул хранит смещение до предыдущего
+func (c *jfrChunk) readConstantPools(data []byte, offset int64) error {
+	for seen := 0; offset > 0; seen++ {
+		if offset >= int64(len(data)) || seen > 100000 {
+			return fmt.Errorf("invalid constant pool offset %d", offset)
+		}
+		d := &jfrDecoder{data: data, pos: int(offset)}
+		d.varint()  // размер
+		d.varlong() // тип (1)
+		d.varlong() // начало
+		d.varlong() // длительность
+		delta := d.varlong()
+		d.byte() // flush
+		poolCount := d.varint()
+
+		for i := 0; i < poolCount && d.err == nil; i++ {
+			typeID := d.varlong()
+			typ, ok := c.types[typeID]
+			if !ok {
+				return fmt.Errorf("unknown constant pool type %d", typeID)
+			}
+			pool := c.pools[typeID]
+			if pool == nil {
+				pool = map[int64]interface{}{}
+				c.pools[typeID] = pool
+			}
+			count := d.count()
+			for j := 0; j < count && d.err == nil; j++ {
+				id := d.varlong()
+				if len(typ.fields) == 0 {
+					// Пул простого типа, например java.lang.String
+					pool[id] = c.readValue(d, jfrField{typeID: typeID})
+				} else {
+					pool[id] = c.readStruct(d, typ)
+				}
+			}
+		}
+		if d.err != nil {
+			return d.err
+		}
+		if delta == 0 {
+			break
+		}
+		offset += delta
+	}
+	return nil
+}
+
+// readStruct читает значения всех полей типа
+func (c *jfrChunk) readStruct(d *jfrDecoder, t *jfrType) jfrStruct {
+	if d.depth >= 32 {
+		d.fail(fmt.Errorf("type %s is nested too deeply", t.name))
+		return nil
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	values := make(jfrStruct, len(t.fields))
+	for i, f := range t.fields {
+		if d.err != nil {
+			break
+		}
+		if f.array {
+			n := d.count()
+			items := make([]interface{}, 0, n)
+			for j := 0; j < n && d.err == nil; j++ {
+				items = append(items, c.readValue(d, f))
+			}
+			values[i] = items
+		} else {
+			values[i] = c.readValue(d, f)
+		}
+	}
+	return values
+}
+
+func (c *jfrChunk) readValue(d *jfrDecoder, f jfrField) interface{} {
+	if f.constantPool {
+		return d.varlong()
+	}
+	t, ok := c.types[f.typeID]
+	if !ok {
+		d.fail(fmt.Errorf("unknown field type %d", f.typeID))
+		return nil
+	}
+	switch t.name {
+	case "boolean":
+		return d.byte() != 0
+	case "byte":
+		return int64(int8(d.byte()))
+	case "char", "short", "int", "long":
+		return d.varlong()
+	case "float":
+		b := d.bytes(4)
+		if b == nil {
+			return float64(0)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case "double":
+		b := d.bytes(8)
+		if b == nil {
+			return float64(0)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	case "java.lang.String":
+		return d.string()
+	}
+	if len(t.fields) == 0 {
+		d.fail(fmt.Errorf("cannot read value of type %s", t.name))
+		return nil
+	}
+	return c.readStruct(d, t)
+}
+
+// constant значение из пула констант типа typeName
+func (c *jfrChunk) constant(typeName string, id int64) (jfrStruct, *jfrType) {
+	t, ok := c.byName[typeName]
+	if !ok {
+		return nil, nil
+	}
+	value, _ := c.pools[t.id][id].(jfrStruct)
+	return value, t
+}
+
+// field значение поля структуры по имени
+func (c *jfrChunk) field(t *jfrType, s jfrStruct, name string) interface{} {
+	if t == nil {
+		return nil
+	}
+	i := t.fieldIndex(name)
+	if i < 0 || i >= len(s) {
+		return nil
+	}
+	return s[i]
+}
+
+func (c *jfrChunk) intField(t *jfrType, s jfrStruct, name string) int64 {
+	switch v := c.field(t, s, name).(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// stringField строка, в том числе записанная ссылкой в пул строк
+func (c *jfrChunk) stringField(t *jfrType, s jfrStruct, name string) string {
+	return c.resolveString(c.field(t, s, name))
+}
+
+func (c *jfrChunk) resolveString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case jfrStringRef:
+		if t, ok := c.byName["java.lang.String"]; ok {
+			str, _ := c.pools[t.id][int64(v)].(string)
+			return str
+		}
+	}
+	return ""
+}
+
+// ticksToNanos переводит отметку времени события в наносекунды Unix
+func (c *jfrChunk) ticksToNanos(ticks int64) int64 {
+	return c.startNanos + c.durationToNanos(ticks-c.startTicks)
+}
+
+// durationToNanos переводит длительность в тиках в наносекунды
+func (c *jfrChunk) durationToNanos(ticks int64) int64 {
+	if c.ticksPerSecond <= 0 {
+		return ticks
+	}
+	return int64(float64(ticks) * 1e9 / float64(c.ticksPerSecond))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// sampleJFR два одинаковых чанка: в каждом 4 jdk.ExecutionSample (3 в Work.compute, 1 в malloc)
+// и 1 jdk.ObjectAllocationInNewTLAB (tlabSize 4096) в потоке "main"
+var sampleJFR = filepath.Join("testdata", "sample.jfr")
+
+// countJFREvents число событий каждого типа и число чанков
+func countJFREvents(t *testing.T, path string) (map[string]int, int) {
+	t.Helper()
+	counts := map[string]int{}
+	chunks := map[*jfrChunk]bool{}
+	if err := readJFRFile(path, func(chunk *jfrChunk, e jfrEvent) error {
+		chunks[chunk] = true
+		counts[e.typ.name]++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return counts, len(chunks)
+}
+
+func TestReadJFRFileEventCounts(t *testing.T) {
+	counts, chunks := countJFREvents(t, sampleJFR)
+	want := map[string]int{"jdk.ExecutionSample": 8, "jdk.ObjectAllocationInNewTLAB": 2}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("event counts = %v, want %v", counts, want)
+	}
+	if chunks != 2 {
+		t.Errorf("chunks = %d, want 2", chunks)
+	}
+}
+
+func TestReadJFRFileGzip(t *testing.T) {
+	data, err := os.ReadFile(sampleJFR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(data)
+	w.Close()
+	path := filepath.Join(t.TempDir(), "sample.jfr.gz")
+	if err := os.WriteFile(path, compressed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := countJFREvents(t, sampleJFR)
+	got, _ := countJFREvents(t, path)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("event counts from .jfr.gz = %v, want %v", got, want)
+	}
+}
+
+func TestReadJFRInvalid(t *testing.T) {
+	data, err := os.ReadFile(sampleJFR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]byte{
+		"empty":                     nil,
+		"not a JFR":                 []byte("main;a;b 10\n"),
+		"old version":               append([]byte("FLR\x00\x00\x01\x00\x00"), data[8:]...),
+		"short header":              data[:20],
+		"chunk smaller than header": append(append([]byte("FLR\x00\x00\x02\x00\x00"), 0, 0, 0, 0, 0, 0, 0, 20), make([]byte, 100)...),
+	}
+	for name, input := range tests {
+		if err := readJFR(input, func(*jfrChunk, jfrEvent) error { return nil }); err == nil {
+			t.Errorf("%s: readJFR succeeded, want error", name)
+		}
+	}
+}
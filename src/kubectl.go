@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	// DNS-1123 label: имена namespace
+	kubeLabelPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	// DNS-1123 subdomain: имена подов
+	kubeSubdomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// validateNamespaceName проверяет, что namespace является корректным DNS-1123 label
+func validateNamespaceName(namespace string) error {
+	if len(namespace) > 63 || !kubeLabelPattern.MatchString(namespace) {
+		return fmt.Errorf("invalid namespace name %q", namespace)
+	}
+	return nil
+}
+
+// validatePodName проверяет, что имя пода является корректным DNS-1123 subdomain
+func validatePodName(pod string) error {
+	if len(pod) > 253 || !kubeSubdomainPattern.MatchString(pod) {
+		return fmt.Errorf("invalid pod name %q", pod)
+	}
+	return nil
+}
+
+// kubectlExecArgs собирает аргументы "kubectl exec" с командой в виде argv.
+// Команда выполняется в поде напрямую, без shell.
+func kubectlExecArgs(namespace, pod string, command ...string) []string {
+	args := []string{}
+	if namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	args = append(args, "exec", pod, "--")
+	return append(args, command...)
+}
+
+// Функция для выполнения kubectl команд с указанным kubeconfig
+func runKubectlWithConfig(ctx context.Context, kubeconfigPath string, args ...string) error {
+	return runKubectlInDir(ctx, "", kubeconfigPath, args...)
+}
+
+// runKubectlInDir выполняет kubectl в указанной рабочей папке.
+// Kubeconfig передается флагом только этой команде, окружение процесса не меняется.
+// Ошибки классифицируются (*ClusterError без Op - операцию называет вызывающий код).
+func runKubectlInDir(ctx context.Context, dir, kubeconfigPath string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "kubectl", append([]string{"--kubeconfig", kubeconfigPath}, args...)...)
+	cmd.Dir = dir
+	// Временно захватываем stderr для диагностики
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
+	setSysProcAttr(cmd)
+
+	if err := cmd.Run(); err != nil {
+		return classifyKubectlError(ctx, "", err, stderr.String())
+	}
+	return nil
+}
+
+// contextDefaultNamespace возвращает namespace текущего контекста kubeconfig ("default", если он не задан)
+func contextDefaultNamespace(ctx context.Context, kubeconfigPath string) string {
+	output, err := runKubectlOutput(ctx, "reading kubeconfig context", kubeconfigPath, "config", "view", "--minify", "-o", "jsonpath={..namespace}")
+	if err == nil {
+		if fields := strings.Fields(string(output)); len(fields) > 0 && validateNamespaceName(fields[0]) == nil {
+			return fields[0]
+		}
+	}
+	return "default"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateNamespaceName(t *testing.T) {
+	tests := []struct {
+		namespace string
+		valid     bool
+	}{
+		{"default", true},
+		{"kube-system", true},
+		{"a", true},
+		{"team1-prod", true},
+		{"a" + strings.Repeat("b", 61) + "c", true}, // 63 символа
+		{"a" + strings.Repeat("b", 62) + "c", false},
+		{"", false},
+		{"Default", false},
+		{"PROD", false},
+		{"-ns", false},
+		{"ns-", false},
+		{"ns.sub", false},
+		{"ns;rm -rf /", false},
+		{"$(id)", false},
+		{"`id`", false},
+		{"ns name", false},
+		{"ns\nother", false},
+		{"ns\x00", false},
+		{"--kubeconfig=/tmp/x", false},
+	}
+	for _, tt := range tests {
+		err := validateNamespaceName(tt.namespace)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateNamespaceName(%q) = %v, want valid=%v", tt.namespace, err, tt.valid)
+		}
+	}
+}
+
+func TestValidatePodName(t *testing.T) {
+	tests := []struct {
+		pod   string
+		valid bool
+	}{
+		{"web-0", true},
+		{"web-7d9f8b6c5-x2kqp", true},
+		{"web.example-1", true},
+		{strings.Repeat("a", 253), true},
+		{strings.Repeat("a", 254), false},
+		{"", false},
+		{"Web-0", false},
+		{"WEB", false},
+		{"-web", false},
+		{"web-", false},
+		{"web..0", false},
+		{".web", false},
+		{"web;id", false},
+		{"$(reboot)", false},
+		{"`id`", false},
+		{"web 0", false},
+		{"web\n0", false},
+		{"web\x000", false},
+		{"--namespace=kube-system", false},
+	}
+	for _, tt := range tests {
+		err := validatePodName(tt.pod)
+		if (err == nil) != tt.valid {
+			t.Errorf("validatePodName(%q) = %v, want valid=%v", tt.pod, err, tt.valid)
+		}
+	}
+}
+
+func TestKubectlExecArgs(t *testing.T) {
+	tests := []struct {
+		namespace, pod string
+		command        []string
+		want           []string
+	}{
+		{
+			namespace: "prod", pod: "web-0", command: []string{"/tmp/asprof", "-e", "cpu", "-d", "30"},
+			want: []string{"-n", "prod", "exec", "web-0", "--", "/tmp/asprof", "-e", "cpu", "-d", "30"},
+		},
+		{
+			pod: "web-0", command: []string{"ls"},
+			want: []string{"exec", "web-0", "--", "ls"},
+		},
+		{
+			// Значения с метасимволами shell передаются отдельными аргументами как есть
+			namespace: "prod", pod: "web-0", command: []string{"/tmp/asprof", "-I", "a;b $(id) `id`"},
+			want: []string{"-n", "prod", "exec", "web-0", "--", "/tmp/asprof", "-I", "a;b $(id) `id`"},
+		},
+	}
+	for _, tt := range tests {
+		got := kubectlExecArgs(tt.namespace, tt.pod, tt.command...)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("kubectlExecArgs(%q, %q, %q) = %q, want %q", tt.namespace, tt.pod, tt.command, got, tt.want)
+		}
+	}
+}
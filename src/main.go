@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image/color"
 	"image/png"
@@ -42,6 +42,24 @@ func chooseFolderDialog() (string, error) {
 	return folder, nil
 }
 
+// Function to open file selection dialog
+func chooseFileDialog(title, ext string) (string, error) {
+	path, ok := openModalFileDialog(title, ext)
+	if !ok {
+		return "", fmt.Errorf("user cancelled selection")
+	}
+	return path, nil
+}
+
+// Function to open save file dialog
+func chooseSaveFileDialog(title, defaultName, ext string) (string, error) {
+	path, ok := saveModalFileDialog(title, defaultName, ext)
+	if !ok {
+		return "", fmt.Errorf("user cancelled selection")
+	}
+	return path, nil
+}
+
 type KubeconfigSelector struct {
 	configs         []string
 	filteredConfigs []string
@@ -57,7 +75,7 @@ type KubeconfigSelector struct {
 type FormatSelector struct {
 	formats         []string
 	filteredFormats []string
-	selectedFormat  string
+	selectedFormats []string // выбранные форматы в порядке списка formats
 	expanded        bool
 	button          widget.Clickable
 	list            widget.List
@@ -284,7 +302,9 @@ func (ks *KubeconfigSelector) Layout(gtx layout.Context, th *material.Theme, app
 											ks.expanded = false
 											ks.saveSelection()
 											
-											// Сбрасываем namespace и pod
+											// Сбрасываем namespace и pod, отменяя их загрузку
+											app.namespaceSelector.CancelLoad()
+											app.podSelector.CancelLoad()
 											app.namespaceSelector.selectedNamespace = ""
 											app.namespaceSelector.namespaces = []string{}
 											app.podSelector.selectedPod = ""
@@ -309,11 +329,12 @@ func (ks *KubeconfigSelector) Layout(gtx layout.Context, th *material.Theme, app
 										ks.expanded = false
 										ks.saveSelection()
 
-										// Сбрасываем namespace и pod при смене конфига
-										app.namespaceSelector.selectedNamespace = ""
+										// При смене конфига восстанавливаем namespace, pod и настройки, запомненные для него
+										app.namespaceSelector.selectedNamespace = restoreScopedNamespace(newConfig)
 										app.namespaceSelector.namespaces = []string{}
-										app.podSelector.selectedPod = ""
+										app.podSelector.selectedPod = restoreScopedPod(newConfig, app.namespaceSelector.selectedNamespace)
 										app.podSelector.pods = []string{}
+										app.restoreScopedSettings()
 
 										// Очищаем статус записи и кнопку браузера
 										app.recordingResult = ""
@@ -321,16 +342,9 @@ func (ks *KubeconfigSelector) Layout(gtx layout.Context, th *material.Theme, app
 										app.htmlOutputPath = ""
 										app.hasCompletedRecording = false
 
-										// Show loading screen when changing kubeconfig
-										app.isLoading = true
-										app.loadingStartTime = time.Now()
-
 										// Загружаем namespaces для нового конфига
-										go func() {
-											// Небольшая задержка для UI
-											time.Sleep(200 * time.Millisecond)
-											app.namespaceSelector.LoadNamespaces(ks.selectedConfig, app)
-										}()
+										// (поды для запомненного namespace загрузятся после получения списка)
+										app.namespaceSelector.LoadNamespaces(newConfig, app)
 									}
 
 									// Стиль элемента
@@ -396,6 +410,10 @@ type NamespaceSelector struct {
 	searchEditor       widget.Editor
 	searchText         string
 	loading            bool
+	loadGeneration     uint64             // номер последнего запроса; ответы старых запросов отбрасываются
+	cancelLoad         context.CancelFunc // отмена запроса, который еще выполняется
+	restricted         bool               // список namespaces недоступен - показаны namespace контекста и использованные
+	manualNamespace    string             // введенное в поиске имя, которого нет в списке
 }
 
 func NewNamespaceSelector() *NamespaceSelector {
@@ -414,6 +432,10 @@ func NewNamespaceSelector() *NamespaceSelector {
 }
 
 func (ns *NamespaceSelector) LoadNamespaces(kubeconfigPath string, app *Application) {
+	// Новый запрос namespaces делает устаревшими и прежний запрос, и загрузку подов старого namespace
+	ns.CancelLoad()
+	app.podSelector.CancelLoad()
+
 	if kubeconfigPath == "" {
 		ns.namespaces = []string{}
 		ns.filteredNamespaces = []string{}
@@ -421,103 +443,99 @@ func (ns *NamespaceSelector) LoadNamespaces(kubeconfigPath string, app *Applicat
 		return
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	ns.cancelLoad = cancel
 	ns.loading = true
-	app.isLoading = true
-	app.loadingStartTime = time.Now()
+	generation := ns.loadGeneration
+	timeouts := app.timeouts
 
-	// Asynchronous namespace loading
+	// Asynchronous namespace loading: результат применяется в UI-горутине событием
 	go func() {
-		namespaces := ns.getNamespacesFromKubeconfig(kubeconfigPath)
-
-		// Проверяем на ошибки сети (если не удалось получить namespaces)
-		if len(namespaces) == 0 {
-			// Проверяем, что это действительно ошибка сети, а не пустой результат
-			// Попробуем простую команду kubectl version для проверки доступности кластера
-			ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
-			defer cancel()
-			
-			cmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", filepath.Join(getKubeDir(), kubeconfigPath), "version", "--short")
-			setSysProcAttr(cmd)
-			if err := cmd.Run(); err != nil {
-				// Если kubectl version не работает, значит проблема с сетью/кластером
-				if ctx.Err() == context.DeadlineExceeded {
-					log.Print("Таймаут при проверке kubectl version (15 сек)")
-				}
-				app.hasNetworkError = true
-				app.lastFailedAction = "loadNamespaces"
-				ns.loading = false
-				app.isLoading = false
-				if app.invalidate != nil {
-					app.invalidate()
-				}
-				return
-			}
+		defer cancel()
+		namespaces, err := ns.getNamespacesFromKubeconfig(ctx, kubeconfigPath, timeouts)
+		if ctx.Err() == context.Canceled {
+			// Запрос заменен более новым - результат никому не нужен
+			return
 		}
 
-		// Обновляем в UI потоке
-		ns.namespaces = namespaces
-		ns.filteredNamespaces = make([]string, len(namespaces))
-		copy(ns.filteredNamespaces, namespaces)
-		ns.clickables = make([]widget.Clickable, len(namespaces))
-
-		// Проверяем, что сохраненный namespace все еще существует
-		saved := ns.selectedNamespace
-		ns.selectedNamespace = ""
-		for _, ns_name := range namespaces {
-			if ns_name == saved {
-				ns.selectedNamespace = saved
-				break
+		// Пользователь без права list namespaces (или с пустым списком) работает с namespace
+		// контекста, ранее использованными namespaces и ручным вводом
+		var clusterErr *ClusterError
+		if (err == nil && len(namespaces) == 0) || (errors.As(err, &clusterErr) && clusterErr.Kind == clusterErrorForbidden) {
+			if err != nil {
+				log.Printf("Список namespaces недоступен, используем namespace контекста: %v", err)
+			}
+			fallback := fallbackNamespaces(ctx, kubeconfigPath, timeouts.List)
+			if ctx.Err() == context.Canceled {
+				return
 			}
+			app.post(namespacesLoadedEvent{kubeconfig: kubeconfigPath, generation: generation, namespaces: fallback, restricted: true})
+			return
 		}
-
-		ns.loading = false
-		app.isLoading = false
-		if app.invalidate != nil {
-			app.invalidate()
+		if err != nil {
+			log.Printf("Ошибка получения namespaces: %v", err)
+			app.post(loadFailedEvent{action: "loadNamespaces", generation: generation, err: err})
+			return
 		}
+
+		app.post(namespacesLoadedEvent{kubeconfig: kubeconfigPath, generation: generation, namespaces: namespaces})
 	}()
 }
 
-func (ns *NamespaceSelector) getNamespacesFromKubeconfig(kubeconfigPath string) []string {
-	if kubeconfigPath == "" {
-		return []string{}
-	}
-
-	fullPath := filepath.Join(getKubeDir(), kubeconfigPath)
-
-	// Создаем контекст с таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
+// fallbackNamespaces namespace контекста kubeconfig (первым) и успешно использованные ранее
+func fallbackNamespaces(ctx context.Context, kubeconfigPath string, timeout time.Duration) []string {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", fullPath, "get", "namespaces", "-o", "jsonpath={.items[*].metadata.name}")
+	namespaces := []string{contextDefaultNamespace(probeCtx, filepath.Join(getKubeDir(), kubeconfigPath))}
+	for _, name := range loadUsedNamespaces(kubeconfigPath) {
+		if name != namespaces[0] {
+			namespaces = append(namespaces, name)
+		}
+	}
+	return namespaces
+}
 
-	// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
-	setSysProcAttr(cmd)
+// CancelLoad отменяет выполняющийся запрос namespaces; его результат будет проигнорирован
+func (ns *NamespaceSelector) CancelLoad() {
+	if ns.cancelLoad != nil {
+		ns.cancelLoad()
+		ns.cancelLoad = nil
+	}
+	ns.loadGeneration++
+	ns.loading = false
+}
+
+// getNamespacesFromKubeconfig получает список namespaces. Пустой список - не ошибка
+func (ns *NamespaceSelector) getNamespacesFromKubeconfig(ctx context.Context, kubeconfigPath string, timeouts OperationTimeouts) ([]string, error) {
+	if kubeconfigPath == "" {
+		return []string{}, nil
+	}
 
-	// Захватываем stderr для диагностики
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	fullPath := filepath.Join(getKubeDir(), kubeconfigPath)
 
-	output, err := cmd.Output()
+	// Таймаут на каждую попытку и повторы при сетевых сбоях; отмена ctx завершает kubectl сразу
+	var output []byte
+	err := withRetry(ctx, timeouts.Retries, timeouts.List, func(ctx context.Context) (err error) {
+		output, err = runKubectlOutput(ctx, opListNamespaces, fullPath, "get", "namespaces", "-o", "jsonpath={.items[*].metadata.name}")
+		return err
+	})
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Print("Таймаут при получении namespaces (15 сек)")
-		} else {
-			errMsg := fmt.Sprintf("Ошибка получения namespaces: %v", err)
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | Details: %s", stderr.String())
-			}
-			log.Print(errMsg)
-		}
-		return []string{}
+		return nil, err
 	}
 
 	namespaces := strings.Fields(string(output))
 	sort.Strings(namespaces)
-	return namespaces
+	return namespaces, nil
 }
 
 func (ns *NamespaceSelector) filterNamespaces() {
+	// Имя, которого нет в списке, можно выбрать вручную (нужно без права list namespaces)
+	ns.manualNamespace = ""
+	if typed := strings.TrimSpace(ns.searchText); typed != "" && validateNamespaceName(typed) == nil && !ns.hasNamespace(typed) {
+		ns.manualNamespace = typed
+	}
+
 	if ns.searchText == "" {
 		ns.filteredNamespaces = make([]string, len(ns.namespaces))
 		copy(ns.filteredNamespaces, ns.namespaces)
@@ -529,6 +547,9 @@ func (ns *NamespaceSelector) filterNamespaces() {
 				ns.filteredNamespaces = append(ns.filteredNamespaces, namespace)
 			}
 		}
+		if ns.manualNamespace != "" {
+			ns.filteredNamespaces = append([]string{ns.manualNamespace}, ns.filteredNamespaces...)
+		}
 		// Если ничего не найдено, добавляем "(нет)"
 		if len(ns.filteredNamespaces) == 0 {
 			ns.filteredNamespaces = []string{"(нет)"}
@@ -537,6 +558,15 @@ func (ns *NamespaceSelector) filterNamespaces() {
 	ns.clickables = make([]widget.Clickable, len(ns.filteredNamespaces))
 }
 
+func (ns *NamespaceSelector) hasNamespace(name string) bool {
+	for _, namespace := range ns.namespaces {
+		if namespace == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (ns *NamespaceSelector) loadSelection() {
 	configFile := getNamespaceConfigFilePath()
 	data, err := os.ReadFile(configFile)
@@ -648,7 +678,7 @@ func (ns *NamespaceSelector) Layout(gtx layout.Context, th *material.Theme, app
 						paint.Fill(gtx.Ops, color.NRGBA{R: 248, G: 248, B: 248, A: 255})
 
 						return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-							editor := material.Editor(th, &ns.searchEditor, "Search namespace...")
+							editor := material.Editor(th, &ns.searchEditor, "Search or type namespace...")
 							editor.Editor.SingleLine = true
 							editor.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
 							editor.HintColor = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
@@ -657,6 +687,17 @@ func (ns *NamespaceSelector) Layout(gtx layout.Context, th *material.Theme, app
 						})
 					})
 				}),
+				// Пояснение, если список namespaces недоступен
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if !ns.restricted {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Top: unit.Dp(2), Bottom: unit.Dp(4), Left: unit.Dp(12), Right: unit.Dp(12)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						label := material.Label(th, unit.Sp(12), "Listing namespaces is not permitted: showing the context namespace and recently used ones. Type a name to use another.")
+						label.Color = color.NRGBA{R: 200, G: 120, B: 0, A: 255}
+						return label.Layout(gtx)
+					})
+				}),
 				// Список namespaces - занимает все доступное место
 				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 					if len(ns.filteredNamespaces) == 0 {
@@ -679,6 +720,7 @@ func (ns *NamespaceSelector) Layout(gtx layout.Context, th *material.Theme, app
 								ns.saveSelection()
 								
 								// Сбрасываем pod при очистке namespace
+								app.podSelector.CancelLoad()
 								app.podSelector.selectedPod = ""
 								app.podSelector.pods = []string{}
 								
@@ -696,14 +738,21 @@ func (ns *NamespaceSelector) Layout(gtx layout.Context, th *material.Theme, app
 								break
 							}
 							
-							// Меняем namespace - сбрасываем pod
+							// Введенный вручную namespace добавляем в список, чтобы он оставался доступен
+							if !ns.hasNamespace(newNamespace) {
+								ns.namespaces = append(ns.namespaces, newNamespace)
+							}
+
+							// Меняем namespace - восстанавливаем запомненный для него pod
 							ns.selectedNamespace = newNamespace
 							ns.expanded = false
 							ns.saveSelection()
+							saveScopedNamespace(app.kubeconfigSelector.GetSelectedConfig(), newNamespace)
 
-							// Сбрасываем pod при смене namespace
-							app.podSelector.selectedPod = ""
+							// Восстанавливаем pod и настройки, запомненные для нового namespace
+							app.podSelector.selectedPod = restoreScopedPod(app.kubeconfigSelector.GetSelectedConfig(), newNamespace)
 							app.podSelector.pods = []string{}
+							app.restoreScopedSettings()
 
 							// Очищаем статус записи и кнопку браузера
 							app.recordingResult = ""
@@ -737,7 +786,11 @@ func (ns *NamespaceSelector) Layout(gtx layout.Context, th *material.Theme, app
 							return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 								layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 									return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(12), Right: unit.Dp(12)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-										label := material.Label(th, unit.Sp(14), ns.filteredNamespaces[index])
+										text := ns.filteredNamespaces[index]
+										if text == ns.manualNamespace {
+											text = fmt.Sprintf("Use namespace \"%s\"", text)
+										}
+										label := material.Label(th, unit.Sp(14), text)
 										if isSelected {
 											label.Color = color.NRGBA{R: 30, G: 30, B: 30, A: 255}
 										} else {
@@ -767,6 +820,8 @@ func (ns *NamespaceSelector) IsNamespaceSelected() bool {
 }
 
 func (ns *NamespaceSelector) Reset() {
+	ns.CancelLoad()
+	ns.restricted = false
 	ns.namespaces = []string{}
 	ns.filteredNamespaces = []string{}
 	ns.selectedNamespace = ""
@@ -781,7 +836,7 @@ func (ns *NamespaceSelector) IsExpanded() bool {
 
 func NewFormatSelector() *FormatSelector {
 	fs := &FormatSelector{
-		formats: []string{"(none)", "html", "collapsed", "pprof", "pb.gz", "heatmap", "otlp"},
+		formats: append([]string{"(none)"}, conversionFormats...),
 		list: widget.List{
 			List: layout.List{
 				Axis: layout.Vertical,
@@ -819,18 +874,11 @@ func (fs *FormatSelector) filterFormats() {
 func (fs *FormatSelector) loadSelection() {
 	configFile := getFormatConfigFilePath()
 	data, err := os.ReadFile(configFile)
-	if err == nil {
-		saved := strings.TrimSpace(string(data))
-		// Check if format is available
-		for _, format := range fs.formats {
-			if format == saved {
-				fs.selectedFormat = saved
-				return
-			}
-		}
+	if err == nil && fs.SetSelection(string(data)) {
+		return
 	}
 	// Default select "heatmap" и сохраняем это значение
-	fs.selectedFormat = "heatmap"
+	fs.selectedFormats = []string{"heatmap"}
 	os.MkdirAll(filepath.Dir(configFile), 0755)
 	os.WriteFile(configFile, []byte("heatmap"), 0644)
 }
@@ -838,18 +886,69 @@ func (fs *FormatSelector) loadSelection() {
 func (fs *FormatSelector) saveSelection() {
 	configFile := getFormatConfigFilePath()
 	os.MkdirAll(filepath.Dir(configFile), 0755)
-	
-	// Если выбран "(нет)" (из поиска) - сохраняем как "(none)"
-	valueToSave := fs.selectedFormat
-	if valueToSave == "" {
-		valueToSave = "(none)"
+	os.WriteFile(configFile, []byte(fs.Selection()), 0644)
+}
+
+// Selection выбранные форматы через запятую, как они хранятся в convert_format.mem; "(none)" - без конвертации
+func (fs *FormatSelector) Selection() string {
+	if len(fs.selectedFormats) == 0 {
+		return "(none)"
 	}
-	
-	os.WriteFile(configFile, []byte(valueToSave), 0644)
+	return strings.Join(fs.selectedFormats, ",")
+}
+
+// SetSelection применяет сохраненный список форматов (один формат - старый формат файла).
+// Неизвестные форматы пропускаются; false, если в значении нет ни одного известного формата
+func (fs *FormatSelector) SetSelection(value string) bool {
+	wanted := map[string]bool{}
+	for _, f := range strings.Split(value, ",") {
+		wanted[strings.TrimSpace(f)] = true
+	}
+	if wanted["(none)"] {
+		fs.selectedFormats = nil
+		return true
+	}
+
+	var selected []string
+	for _, format := range fs.formats {
+		if format != "(none)" && wanted[format] {
+			selected = append(selected, format)
+		}
+	}
+	if len(selected) == 0 {
+		return false
+	}
+	fs.selectedFormats = selected
+	return true
 }
 
-func (fs *FormatSelector) GetSelectedFormat() string {
-	return fs.selectedFormat
+// GetSelectedFormats выбранные форматы конвертации
+func (fs *FormatSelector) GetSelectedFormats() []string {
+	return append([]string(nil), fs.selectedFormats...)
+}
+
+func (fs *FormatSelector) isSelected(format string) bool {
+	for _, f := range fs.selectedFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// toggle включает или выключает формат, сохраняя порядок списка formats
+func (fs *FormatSelector) toggle(format string) {
+	selected := !fs.isSelected(format)
+	var formats []string
+	for _, f := range fs.formats {
+		if f == "(none)" {
+			continue
+		}
+		if (f == format && selected) || (f != format && fs.isSelected(f)) {
+			formats = append(formats, f)
+		}
+	}
+	fs.selectedFormats = formats
 }
 
 func (fs *FormatSelector) IsExpanded() bool {
@@ -896,15 +995,15 @@ func (fs *FormatSelector) Layout(gtx layout.Context, th *material.Theme, app *Ap
 					}
 
 					// Button text
-					buttonText := "Select format"
-					if fs.selectedFormat != "" {
-						buttonText = fs.selectedFormat
+					buttonText := "(none)"
+					if len(fs.selectedFormats) > 0 {
+						buttonText = strings.Join(fs.selectedFormats, ", ")
 					} else if len(fs.formats) == 0 {
 						buttonText = "No formats available"
 					}
 
 					btn := material.Button(th, &fs.button, buttonText)
-					if fs.selectedFormat == "" && len(fs.formats) > 0 {
+					if len(fs.selectedFormats) == 0 && len(fs.formats) > 0 {
 						// Gray color for inactive button
 						btn.Background = color.NRGBA{R: 200, G: 200, B: 200, A: 255}
 						btn.Color = color.NRGBA{R: 80, G: 80, B: 80, A: 255}
@@ -915,8 +1014,26 @@ func (fs *FormatSelector) Layout(gtx layout.Context, th *material.Theme, app *Ap
 					}
 					return btn.Layout(gtx)
 				}),
+				// Опции конвертации (только если выбран формат)
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if len(fs.selectedFormats) == 0 || app.convertOptionsForm == nil {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return app.convertOptionsForm.LayoutToggle(gtx, th, app)
+					})
+				}),
 			)
 		}),
+		// Раскрытая форма опций конвертации
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if fs.expanded || app.convertOptionsForm == nil || !app.convertOptionsForm.IsExpanded() {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return app.convertOptionsForm.LayoutForm(gtx, th, app)
+			})
+		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			if !fs.expanded || len(fs.filteredFormats) == 0 {
 				return layout.Dimensions{}
@@ -973,34 +1090,24 @@ func (fs *FormatSelector) Layout(gtx layout.Context, th *material.Theme, app *Ap
 									for fs.clickables[index].Clicked(gtx) {
 										newFormat := fs.filteredFormats[index]
 										
-										// Если кликнули по "(нет)" - очищаем выбор
-										if newFormat == "(нет)" {
-											fs.selectedFormat = ""
+										// "(none)" и "(нет)" из поиска - без конвертации, закрываем селект
+										if newFormat == "(нет)" || newFormat == "(none)" {
+											fs.selectedFormats = nil
 											fs.expanded = false
-											fs.saveSelection()
-											if app.invalidate != nil {
-												app.invalidate()
-											}
-											break
-										}
-										
-										// Если выбираем тот же формат - просто закрываем селект
-										if newFormat == fs.selectedFormat {
-											fs.expanded = false
-											break
+										} else {
+											// Остальные форматы переключаются, селект остается открытым для выбора нескольких
+											fs.toggle(newFormat)
 										}
-										
-										// Меняем формат
-										fs.selectedFormat = newFormat
-										fs.expanded = false
 										fs.saveSelection()
+										app.rememberSetting(formatSettingName, fs.Selection())
 										if app.invalidate != nil {
 											app.invalidate()
 										}
 									}
 
 									// Item style
-									isSelected := fs.filteredFormats[index] == fs.selectedFormat
+									itemFormat := fs.filteredFormats[index]
+									isSelected := fs.isSelected(itemFormat) || (itemFormat == "(none)" && len(fs.selectedFormats) == 0)
 
 									return material.Clickable(gtx, &fs.clickables[index], func(gtx layout.Context) layout.Dimensions {
 										// Background for selected item
@@ -1017,7 +1124,15 @@ func (fs *FormatSelector) Layout(gtx layout.Context, th *material.Theme, app *Ap
 										return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 											layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 												return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(12), Right: unit.Dp(12)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-													label := material.Label(th, unit.Sp(14), fs.filteredFormats[index])
+													text := itemFormat
+													if itemFormat != "(none)" && itemFormat != "(нет)" {
+														mark := "[ ] "
+														if isSelected {
+															mark = "[x] "
+														}
+														text = mark + itemFormat
+													}
+													label := material.Label(th, unit.Sp(14), text)
 													if isSelected {
 														label.Color = color.NRGBA{R: 30, G: 30, B: 30, A: 255}
 													} else {
@@ -1040,16 +1155,18 @@ func (fs *FormatSelector) Layout(gtx layout.Context, th *material.Theme, app *Ap
 
 // PodSelector управляет выбором подов
 type PodSelector struct {
-	pods         []string
-	filteredPods []string
-	selectedPod  string
-	expanded     bool
-	button       widget.Clickable
-	list         widget.List
-	clickables   []widget.Clickable
-	searchEditor widget.Editor
-	searchText   string
-	loading      bool
+	pods           []string
+	filteredPods   []string
+	selectedPod    string
+	expanded       bool
+	button         widget.Clickable
+	list           widget.List
+	clickables     []widget.Clickable
+	searchEditor   widget.Editor
+	searchText     string
+	loading        bool
+	loadGeneration uint64             // номер последнего запроса; ответы старых запросов отбрасываются
+	cancelLoad     context.CancelFunc // отмена запроса, который еще выполняется
 }
 
 func NewPodSelector() *PodSelector {
@@ -1068,6 +1185,9 @@ func NewPodSelector() *PodSelector {
 }
 
 func (ps *PodSelector) LoadPods(kubeconfigPath, namespace string, app *Application) {
+	// Новый запрос подов делает устаревшим прежний (например, для другого namespace)
+	ps.CancelLoad()
+
 	if kubeconfigPath == "" || namespace == "" {
 		ps.pods = []string{}
 		ps.filteredPods = []string{}
@@ -1075,102 +1195,57 @@ func (ps *PodSelector) LoadPods(kubeconfigPath, namespace string, app *Applicati
 		return
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	ps.cancelLoad = cancel
 	ps.loading = true
-	app.isLoading = true
-	app.loadingStartTime = time.Now()
+	generation := ps.loadGeneration
+	timeouts := app.timeouts
 
-	// Asynchronous pod loading
+	// Asynchronous pod loading: результат применяется в UI-горутине событием
 	go func() {
-		pods := ps.getPodsFromKubectl(kubeconfigPath, namespace)
-
-		// Проверяем на ошибки сети (если не удалось получить pods)
-		if len(pods) == 0 {
-			// Проверяем, что это действительно ошибка сети, а не пустой namespace
-			// Попробуем простую команду kubectl version для проверки доступности кластера
-			homeDir, _ := os.UserHomeDir()
-			configPath := filepath.Join(homeDir, ".kube", kubeconfigPath)
-			
-			ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
-			defer cancel()
-			
-			cmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", configPath, "version", "--short")
-			setSysProcAttr(cmd)
-			if err := cmd.Run(); err != nil {
-				// Если kubectl version не работает, значит проблема с сетью/кластером
-				if ctx.Err() == context.DeadlineExceeded {
-					log.Print("Таймаут при проверке kubectl version для подов (15 сек)")
-				}
-				app.hasNetworkError = true
-				app.lastFailedAction = "loadPods"
-				ps.loading = false
-				app.isLoading = false
-				if app.invalidate != nil {
-					app.invalidate()
-				}
-				return
-			}
+		defer cancel()
+		pods, err := ps.getPodsFromKubectl(ctx, kubeconfigPath, namespace, timeouts)
+		if ctx.Err() == context.Canceled {
+			// Запрос заменен более новым - результат никому не нужен
+			return
 		}
-
-		// Обновляем в UI потоке
-		ps.pods = pods
-		ps.filteredPods = make([]string, len(pods))
-		copy(ps.filteredPods, pods)
-		ps.clickables = make([]widget.Clickable, len(pods))
-
-		// Проверяем, что сохраненный pod все еще существует
-		saved := ps.selectedPod
-		ps.selectedPod = ""
-		for _, podName := range pods {
-			if podName == saved {
-				ps.selectedPod = saved
-				break
-			}
+		if err != nil {
+			log.Printf("Error getting pods for namespace %s: %v", namespace, err)
+			app.post(loadFailedEvent{action: "loadPods", generation: generation, err: err})
+			return
 		}
 
-		ps.loading = false
-		app.isLoading = false
-		if app.invalidate != nil {
-			app.invalidate()
-		}
+		app.post(podsLoadedEvent{kubeconfig: kubeconfigPath, namespace: namespace, generation: generation, pods: pods})
 	}()
 }
 
-func (ps *PodSelector) getPodsFromKubectl(kubeconfigPath, namespace string) []string {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return []string{}
+// CancelLoad отменяет выполняющийся запрос подов; его результат будет проигнорирован
+func (ps *PodSelector) CancelLoad() {
+	if ps.cancelLoad != nil {
+		ps.cancelLoad()
+		ps.cancelLoad = nil
 	}
+	ps.loadGeneration++
+	ps.loading = false
+}
 
-	configPath := filepath.Join(homeDir, ".kube", kubeconfigPath)
-
-	// Создаем контекст с таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "kubectl", "get", "pods", "-n", namespace, "--kubeconfig", configPath, "-o", "jsonpath={.items[*].metadata.name}")
-	
-	// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
-	setSysProcAttr(cmd)
-
-	// Захватываем stderr для диагностики
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+// getPodsFromKubectl получает список подов namespace. Пустой namespace - не ошибка
+func (ps *PodSelector) getPodsFromKubectl(ctx context.Context, kubeconfigPath, namespace string, timeouts OperationTimeouts) ([]string, error) {
+	configPath := filepath.Join(getKubeDir(), kubeconfigPath)
 
-	output, err := cmd.Output()
+	// Таймаут на каждую попытку и повторы при сетевых сбоях; отмена ctx завершает kubectl сразу
+	var output []byte
+	err := withRetry(ctx, timeouts.Retries, timeouts.List, func(ctx context.Context) (err error) {
+		output, err = runKubectlOutput(ctx, opListPods, configPath, "get", "pods", "-n", namespace, "-o", "jsonpath={.items[*].metadata.name}")
+		return err
+	})
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Таймаут при получении подов (15 сек) для namespace %s", namespace)
-		} else if stderr.Len() > 0 {
-			log.Printf("Error getting pods: %v | Details: %s", err, stderr.String())
-		} else {
-			log.Printf("Error getting pods: %v", err)
-		}
-		return []string{}
+		return nil, err
 	}
 
 	podNames := strings.Fields(string(output))
 	sort.Strings(podNames)
-	return podNames
+	return podNames, nil
 }
 
 func (ps *PodSelector) filterPods() {
@@ -1347,6 +1422,9 @@ func (ps *PodSelector) Layout(gtx layout.Context, th *material.Theme, app *Appli
 							ps.selectedPod = newPod
 							ps.expanded = false
 							ps.saveSelection()
+							if app != nil {
+								saveScopedPod(app.kubeconfigSelector.GetSelectedConfig(), app.namespaceSelector.GetSelectedNamespace(), newPod)
+							}
 							
 							// Очищаем статус записи и кнопку браузера при смене pod
 							if app != nil {
@@ -1410,6 +1488,7 @@ func (ps *PodSelector) Close() {
 }
 
 func (ps *PodSelector) Reset() {
+	ps.CancelLoad()
 	ps.pods = []string{}
 	ps.filteredPods = []string{}
 	ps.selectedPod = ""
@@ -1467,15 +1546,28 @@ func shortenFileName(filePath string) string {
 	return nameWithoutExt[:5] + "***" + nameWithoutExt[len(nameWithoutExt)-5:] + ext
 }
 
+// clearAllSavedData сбрасывает запомненный выбор: *.mem файлы и настройки кластеров/namespaces в scopes/.
+// История, пресеты, таймауты, шаблоны имен и правила хранения остаются
 func clearAllSavedData() error {
 	configDir := getConfigDir()
 	
-	// Удаляем всю директорию конфигурации
-	if err := os.RemoveAll(configDir); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove config directory: %v", err)
+	entries, err := os.ReadDir(configDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config directory: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".mem" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(configDir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", entry.Name(), err)
+		}
+	}
+	if err := os.RemoveAll(getScopesDir()); err != nil {
+		return fmt.Errorf("failed to remove saved scopes: %v", err)
 	}
 	
-	// Создаем директорию заново
+	// Создаем директорию, если ее еще не было
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %v", err)
 	}
@@ -1485,17 +1577,25 @@ func clearAllSavedData() error {
 
 type Application struct {
 	versionBadge       widget.Clickable
+	openJFRButton      widget.Clickable // "Open JFR…" в заголовке
+	historyButton      widget.Clickable // "History" в заголовке
+	convertPanel       *ConvertPanel    // Окно конвертации сохраненного JFR
+	historyPanel       *HistoryPanel    // Окно истории записей
+	flameViewer        *FlameViewer     // Просмотр flame graph внутри приложения
 	version            string
 	kubeconfigSelector *KubeconfigSelector
 	namespaceSelector  *NamespaceSelector
 	podSelector        *PodSelector
 	formatSelector     *FormatSelector
+	convertOptions     ConvertOptions      // Опции конвертации, сохраняются вместе с форматом
+	convertOptionsForm *ConvertOptionsForm // Форма опций конвертации у выбора формата
+	presetSelector     *PresetSelector
+	optionsForm        *AsprofOptionsForm
 	lastSelectedConfig string
-	isLoading          bool
-	loadingStartTime   time.Time
 	asprofArgsEditor   widget.Editor
 	asprofArgs         string
 	invalidate         func() // function for forced UI refresh
+	events             eventQueue // события фоновых горутин, применяемые в UI-горутине
 	folderButton       widget.Clickable
 	selectedFolder     string
 	startRecordingButton widget.Clickable
@@ -1505,6 +1605,9 @@ type Application struct {
 	openBrowserButton  widget.Clickable
 	showBrowserButton  bool // Показывать ли кнопку открытия в браузере
 	htmlOutputPath     string // Путь к HTML файлу для открытия
+	viewFlameButton    widget.Clickable
+	lastJFRPath        string // JFR последней записи для просмотра в приложении
+	recordingSummary   string // Сводка по последней записи: топ методов, горячий путь, доли GC/JIT/native
 	hasCompletedRecording bool // Была ли завершена запись для текущей конфигурации
 	isInitializing     bool   // Идет ли первоначальная инициализация
 	initializationMessage string // Сообщение инициализации
@@ -1513,14 +1616,22 @@ type Application struct {
 	
 	// Состояние выбора папки
 	isChoosingFolder   bool   // Открыто ли окно выбора папки
+	choosingMessage    string // Надпись занавеса, если выбирается не папка JFR
 	errorMessage       string // Сообщение об ошибке
 	statusClickable    widget.Clickable // Кликабельность статуса
 	outputPath         string // Путь к папке с результатами
 	
-	// Состояние сетевых ошибок
-	hasNetworkError    bool   // Есть ли ошибка сети
-	retryButton        widget.Clickable // Кнопка Retry
-	resetButton        widget.Clickable // Кнопка Reset
+	// Состояние ошибок обращения к кластеру
+	clusterError       *ClusterError       // Классифицированная ошибка; nil - ошибки нет
+	preflight          Preflight           // Проверка прав для выбранного пода
+	timeouts           OperationTimeouts   // Таймауты операций с кластером
+	converterBackend   string              // Бэкенд конвертации JFR: native или java
+	naming             NamingSettings      // Шаблоны имени файла записи и подпапок
+	retention          RetentionSettings   // Правила хранения записей из истории
+	remedyButtons      [4]widget.Clickable // Кнопки действий overlay ошибки
+	detailsButton      widget.Clickable    // Раскрытие вывода kubectl
+	showErrorDetails   bool                // Показан ли вывод kubectl
+	errorDetailsList   widget.List         // Прокрутка вывода kubectl
 	lastFailedAction   string // Последнее неудачное действие для повтора
 	
 	// Логотип приложения
@@ -1535,6 +1646,21 @@ func (a *Application) closeAllSelectors() {
 	a.namespaceSelector.expanded = false
 	a.podSelector.expanded = false
 	a.formatSelector.expanded = false
+	if a.presetSelector != nil {
+		a.presetSelector.expanded = false
+	}
+	if a.optionsForm != nil {
+		a.optionsForm.expanded = false
+	}
+	if a.convertOptionsForm != nil {
+		a.convertOptionsForm.expanded = false
+	}
+}
+
+// isArgsSectionExpanded раскрыт ли под полем аргументов список пресетов или форма опций
+func (a *Application) isArgsSectionExpanded() bool {
+	return (a.presetSelector != nil && a.presetSelector.IsExpanded()) ||
+		(a.optionsForm != nil && a.optionsForm.IsExpanded())
 }
 
 func (a *Application) loadLogo() {
@@ -1557,7 +1683,8 @@ func (a *Application) loadLogo() {
 }
 
 func (a *Application) drawLoadingOverlay(gtx layout.Context, th *material.Theme) layout.Dimensions {
-	if !a.isLoading {
+	message := a.loadingMessage()
+	if message == "" {
 		return layout.Dimensions{}
 	}
 
@@ -1574,7 +1701,7 @@ func (a *Application) drawLoadingOverlay(gtx layout.Context, th *material.Theme)
 				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 						return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-							label := material.Label(th, unit.Sp(24), "Loading...")
+							label := material.Label(th, unit.Sp(24), message)
 							label.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
 							return label.Layout(gtx)
 						})
@@ -1585,6 +1712,17 @@ func (a *Application) drawLoadingOverlay(gtx layout.Context, th *material.Theme)
 	})
 }
 
+// loadingMessage описывает запрос к кластеру, который сейчас выполняется ("" - ничего не загружается)
+func (a *Application) loadingMessage() string {
+	switch {
+	case a.namespaceSelector != nil && a.namespaceSelector.loading:
+		return "Loading namespaces..."
+	case a.podSelector != nil && a.podSelector.loading:
+		return "Loading pods..."
+	}
+	return ""
+}
+
 // Функция для отрисовки блокирующего overlay во время записи
 func (a *Application) drawRecordingOverlay(gtx layout.Context, th *material.Theme) layout.Dimensions {
 	if !a.isRecording {
@@ -1618,7 +1756,11 @@ func (a *Application) drawFolderChoosingOverlay(gtx layout.Context, th *material
 				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 						return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-							label := material.Label(th, unit.Sp(24), "Choosing JFR folder...")
+							message := "Choosing JFR folder..."
+							if a.choosingMessage != "" {
+								message = a.choosingMessage
+							}
+							label := material.Label(th, unit.Sp(24), message)
 							label.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
 							return label.Layout(gtx)
 						})
@@ -1629,10 +1771,22 @@ func (a *Application) drawFolderChoosingOverlay(gtx layout.Context, th *material
 	})
 }
 
-func (a *Application) drawNetworkErrorOverlay(gtx layout.Context, th *material.Theme) layout.Dimensions {
-	if !a.hasNetworkError {
+func (a *Application) drawClusterErrorOverlay(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	clusterErr := a.clusterError
+	if clusterErr == nil {
 		return layout.Dimensions{}
 	}
+	remedies := clusterErr.Remedies()
+
+	// Обработка кликов до отрисовки: действие может закрыть overlay
+	for i, remedy := range remedies {
+		for a.remedyButtons[i].Clicked(gtx) {
+			a.applyRemedy(remedy)
+		}
+	}
+	for a.detailsButton.Clicked(gtx) {
+		a.showErrorDetails = !a.showErrorDetails
+	}
 
 	// Создаем кликабельную область на весь экран для блокировки
 	clickable := &widget.Clickable{}
@@ -1641,73 +1795,133 @@ func (a *Application) drawNetworkErrorOverlay(gtx layout.Context, th *material.T
 		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
 		paint.Fill(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
 
-		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
-			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
-					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-						return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-							return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
-								// Сообщение об ошибке
-								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-									label := material.Label(th, unit.Sp(20), "Network error. Check connection or enable VPN.")
-									label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255} // Красный цвет
-									label.Alignment = text.Middle
-									return label.Layout(gtx)
-								}),
-								// Отступ между надписью и кнопками
-								layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
-								// Кнопки Retry и Reset
-								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-									return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
-										// Кнопка Retry
-										layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-											// Обработка клика на кнопку Retry
-											for a.retryButton.Clicked(gtx) {
-												a.retryLastAction()
-											}
-
-											// Pointer cursor при наведении
-											if a.retryButton.Hovered() {
-												pointer.CursorPointer.Add(gtx.Ops)
-											}
-
-											btn := material.Button(th, &a.retryButton, "Retry")
-											btn.Background = color.NRGBA{R: 76, G: 175, B: 80, A: 255} // Зеленая кнопка
-											btn.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
-											return btn.Layout(gtx)
-										}),
-										// Отступ между кнопками
-										layout.Rigid(layout.Spacer{Width: unit.Dp(20)}.Layout),
-										// Кнопка Reset
-										layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-											// Обработка клика на кнопку Reset
-											for a.resetButton.Clicked(gtx) {
-												a.resetAll()
-											}
-
-											// Pointer cursor при наведении
-											if a.resetButton.Hovered() {
-												pointer.CursorPointer.Add(gtx.Ops)
-											}
+		return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Max.X = gtx.Dp(unit.Dp(640))
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+				// Заголовок ошибки
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					label := material.Label(th, unit.Sp(20), clusterErr.Title())
+					label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255} // Красный цвет
+					label.Alignment = text.Middle
+					return label.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+				// Подсказка
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					label := material.Label(th, unit.Sp(14), clusterErr.Hint())
+					label.Color = color.NRGBA{R: 80, G: 80, B: 80, A: 255}
+					label.Alignment = text.Middle
+					return label.Layout(gtx)
+				}),
+				// Отступ между надписью и кнопками
+				layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+				// Кнопки действий: первая - основная
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					children := make([]layout.FlexChild, 0, len(remedies)*2)
+					for i, remedy := range remedies {
+						if i > 0 {
+							children = append(children, layout.Rigid(layout.Spacer{Width: unit.Dp(20)}.Layout))
+						}
+						button := &a.remedyButtons[i]
+						label := remedyLabel(remedy)
+						primary := i == 0
+						children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							// Pointer cursor при наведении
+							if button.Hovered() {
+								pointer.CursorPointer.Add(gtx.Ops)
+							}
 
-											btn := material.Button(th, &a.resetButton, "Reset")
-											btn.Background = color.NRGBA{R: 244, G: 67, B: 54, A: 255} // Красная кнопка
-											btn.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
-											return btn.Layout(gtx)
-										}),
-									)
-								}),
-							)
-						})
-					}),
-				)
-			}),
-		)
+							btn := material.Button(th, button, label)
+							switch {
+							case primary:
+								btn.Background = color.NRGBA{R: 76, G: 175, B: 80, A: 255} // Зеленая кнопка
+								btn.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+							case remedy == remedyReset:
+								btn.Background = color.NRGBA{R: 244, G: 67, B: 54, A: 255} // Красная кнопка
+								btn.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+							default:
+								btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+								btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+							}
+							return btn.Layout(gtx)
+						}))
+					}
+					return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx, children...)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(20)}.Layout),
+				// Details: исходный вывод kubectl
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if a.detailsButton.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+					title := "▸ Details"
+					if a.showErrorDetails {
+						title = "▾ Details"
+					}
+					return material.Clickable(gtx, &a.detailsButton, func(gtx layout.Context) layout.Dimensions {
+						label := material.Label(th, unit.Sp(14), title)
+						label.Color = color.NRGBA{R: 0, G: 0, B: 255, A: 255}
+						return label.Layout(gtx)
+					})
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if !a.showErrorDetails {
+						return layout.Dimensions{}
+					}
+					details := clusterErr.Stderr
+					if details == "" {
+						details = clusterErr.Error()
+					}
+					return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						gtx.Constraints.Max.Y = gtx.Dp(unit.Dp(200))
+						return layout.Background{}.Layout(gtx,
+							func(gtx layout.Context) layout.Dimensions {
+								defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+								paint.Fill(gtx.Ops, color.NRGBA{R: 245, G: 245, B: 245, A: 255})
+								return layout.Dimensions{Size: gtx.Constraints.Min}
+							},
+							func(gtx layout.Context) layout.Dimensions {
+								return material.List(th, &a.errorDetailsList).Layout(gtx, 1, func(gtx layout.Context, _ int) layout.Dimensions {
+									return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+										label := material.Label(th, unit.Sp(12), details)
+										label.Color = color.NRGBA{R: 60, G: 60, B: 60, A: 255}
+										return label.Layout(gtx)
+									})
+								})
+							},
+						)
+					})
+				}),
+			)
+		})
 	})
 }
 
+// applyRemedy выполняет действие, выбранное на overlay ошибки кластера
+func (a *Application) applyRemedy(remedy string) {
+	switch remedy {
+	case remedyRetry:
+		a.retryLastAction()
+	case remedyReset:
+		a.resetAll()
+	case remedyOpenKubeDir:
+		go a.openFolder(getKubeDir())
+	case remedyInstallKubectl:
+		go a.openURL(kubectlInstallURL)
+	case remedyChooseKubeconfig:
+		a.clusterError = nil
+		a.closeAllSelectors()
+		a.kubeconfigSelector.expanded = true
+	case remedyChooseNamespace:
+		a.clusterError = nil
+		a.closeAllSelectors()
+		a.namespaceSelector.expanded = true
+	}
+}
+
 func (a *Application) retryLastAction() {
-	a.hasNetworkError = false
+	a.clusterError = nil
 	
 	switch a.lastFailedAction {
 	case "loadNamespaces":
@@ -1729,8 +1943,8 @@ func (a *Application) retryLastAction() {
 }
 
 func (a *Application) resetAll() {
-	// Скрываем сетевую ошибку
-	a.hasNetworkError = false
+	// Скрываем ошибку кластера
+	a.clusterError = nil
 	
 	// Очищаем все сохраненные конфигурации
 	if err := clearAllSavedData(); err != nil {
@@ -1874,12 +2088,25 @@ func NewApplication() *Application {
 		asprofArgsEditor: widget.Editor{
 			SingleLine: true,
 		},
+		errorDetailsList: widget.List{
+			List: layout.List{
+				Axis: layout.Vertical,
+			},
+		},
 	}
+	cleanupStaleTempFiles() // Удаляем временные файлы, оставшиеся после аварийного завершения
+	app.timeouts = loadOperationTimeouts()
+	app.converterBackend = loadConverterBackend()
+	app.naming = loadNamingSettings()
+	app.retention = loadRetentionSettings()
+	app.convertPanel = NewConvertPanel()
+	app.historyPanel = NewHistoryPanel()
+	app.flameViewer = NewFlameViewer()
 	app.detectVersion()
 	app.loadLogo() // Загружаем логотип
 	
 	// Создаем компонент заголовка
-	app.headerComponent = NewHeaderComponent(app.logoImage, &app.versionBadge, app.version)
+	app.headerComponent = NewHeaderComponent(app.logoImage, &app.versionBadge, &app.openJFRButton, &app.historyButton, app.version)
 
 	// Проверяем существование data директории
 	if _, err := os.Stat("./data"); os.IsNotExist(err) {
@@ -1891,35 +2118,39 @@ func NewApplication() *Application {
 		go app.performClearing()
 	} else {
 		// Если data есть, инициализируем селекторы и загружаем как обычно
-		app.initializeSelectors()
-		app.loadAsprofArgs()     // Load saved arguments
-		app.loadSelectedFolder() // Load saved folder
-		app.profilerPath = app.findProfilerPath() // Find profiler automatically
-
-		selectedConfig := app.kubeconfigSelector.GetSelectedConfig()
-		if selectedConfig != "" {
-			go func() {
-				app.namespaceSelector.LoadNamespaces(selectedConfig, app)
-
-				// Загружаем поды если есть сохраненный namespace
-				selectedNamespace := app.namespaceSelector.GetSelectedNamespace()
-				if selectedNamespace != "" {
-					app.podSelector.LoadPods(selectedConfig, selectedNamespace, app)
-				}
-			}()
-		}
+		app.loadSelectorsAndSettings()
 	}
 
 	return app
 }
 
+// loadSelectorsAndSettings создает селекторы, загружает сохраненные настройки и запускает загрузку namespaces.
+// Поды для сохраненного namespace загружаются после того, как придет список namespaces.
+func (a *Application) loadSelectorsAndSettings() {
+	a.initializeSelectors()
+	a.loadAsprofArgs()                    // Load saved arguments
+	a.loadSelectedFolder()                // Load saved folder
+	a.restoreStartupScope()               // Settings remembered for the selected kubeconfig
+	a.profilerPath = a.findProfilerPath() // Find profiler automatically
+
+	selectedConfig := a.kubeconfigSelector.GetSelectedConfig()
+	if selectedConfig != "" {
+		a.namespaceSelector.LoadNamespaces(selectedConfig, a)
+	}
+}
+
 func (a *Application) initializeSelectors() {
 	a.kubeconfigSelector = NewKubeconfigSelector()
 	a.namespaceSelector = NewNamespaceSelector()
 	a.podSelector = NewPodSelector()
 	a.formatSelector = NewFormatSelector()
+	a.convertOptions = loadConvertOptions()
+	a.convertOptionsForm = NewConvertOptionsForm()
+	a.presetSelector = NewPresetSelector()
+	a.optionsForm = NewAsprofOptionsForm()
 }
 
+// performClearing выполняется в фоновой горутине и сообщает о ходе инициализации событиями
 func (a *Application) performClearing() {
 	// Очищаем все сохраненные данные
 	if err := clearAllSavedData(); err != nil {
@@ -1927,37 +2158,22 @@ func (a *Application) performClearing() {
 	}
 	
 	// После очистки переходим к инициализации
-	a.isClearing = false
-	a.isInitializing = true
-	a.initializationMessage = "Loading async-profiler '4.1'..."
-	
-	if a.invalidate != nil {
-		a.invalidate()
-	}
+	a.post(initStageEvent{message: "Loading async-profiler '4.1'..."})
 	
 	// Запускаем обычную инициализацию
 	a.performInitialization()
 }
 
+// performInitialization выполняется в фоновой горутине: проверки и загрузки без доступа к состоянию UI
 func (a *Application) performInitialization() {
 	// Проверяем kubectl и .kube перед загрузкой зависимостей
 	if err := checkKubectl(); err != nil {
-		a.hasError = true
-		a.errorMessage = "kubectl not found"
-		a.isInitializing = false
-		if a.invalidate != nil {
-			a.invalidate()
-		}
+		a.post(initFailedEvent{message: "kubectl not found"})
 		return
 	}
 	
 	if err := checkKubeDirectory(); err != nil {
-		a.hasError = true
-		a.errorMessage = ".kube directory not found or empty"
-		a.isInitializing = false
-		if a.invalidate != nil {
-			a.invalidate()
-		}
+		a.post(initFailedEvent{message: ".kube directory not found or empty"})
 		return
 	}
 
@@ -1966,27 +2182,8 @@ func (a *Application) performInitialization() {
 		log.Printf("Warning: Failed to check dependencies: %v", err)
 	}
 	
-	// После загрузки инициализируем селекторы и загружаем данные
-	a.initializeSelectors()
-	a.loadAsprofArgs()
-	a.loadSelectedFolder()
-	a.profilerPath = a.findProfilerPath()
-
-	selectedConfig := a.kubeconfigSelector.GetSelectedConfig()
-	if selectedConfig != "" {
-		a.namespaceSelector.LoadNamespaces(selectedConfig, a)
-
-		selectedNamespace := a.namespaceSelector.GetSelectedNamespace()
-		if selectedNamespace != "" {
-			a.podSelector.LoadPods(selectedConfig, selectedNamespace, a)
-		}
-	}
-	
-	// Завершаем инициализацию
-	a.isInitializing = false
-	if a.invalidate != nil {
-		a.invalidate()
-	}
+	// После загрузки селекторы создаются и данные загружаются в UI-горутине
+	a.post(initCompletedEvent{})
 }
 
 func (a *Application) loadAsprofArgs() {
@@ -2072,15 +2269,11 @@ func (a *Application) drawFolderSelector(gtx layout.Context, th *material.Theme)
 						}
 						
 						go func() {
-							if folder, err := chooseFolderDialog(); err == nil {
-								a.selectedFolder = folder
-								a.saveSelectedFolder()
-							}
-							// Убираем занавес в любом случае
-							a.isChoosingFolder = false
-							if a.invalidate != nil {
-								a.invalidate()
+							folder, err := chooseFolderDialog()
+							if err != nil {
+								folder = ""
 							}
+							a.post(folderChosenEvent{folder: folder})
 						}()
 					}
 
@@ -2119,9 +2312,15 @@ func (a *Application) drawFolderSelector(gtx layout.Context, th *material.Theme)
 
 // Функция для отрисовки кнопки записи и результата
 func (a *Application) drawRecordingControls(gtx layout.Context, th *material.Theme) layout.Dimensions {
-	return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
-		// Пустое пространство слева
-		layout.Flexed(1, layout.Spacer{}.Layout),
+	// Права проверяются для каждого нового выбранного пода
+	a.ensurePreflight()
+	canStart := !a.isRecording && a.preflightPassed()
+
+	return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Start}.Layout(gtx,
+		// Слева - результаты проверки прав
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return a.drawPreflightChecklist(gtx, th)
+		}),
 		// Правая панель с кнопками и статусом (вертикально)
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			return layout.Flex{Axis: layout.Vertical, Alignment: layout.End}.Layout(gtx,
@@ -2133,13 +2332,13 @@ func (a *Application) drawRecordingControls(gtx layout.Context, th *material.The
 
 					// Обработка клика по кнопке
 					for a.startRecordingButton.Clicked(gtx) {
-						if !a.isRecording {
+						if canStart {
 							a.startRecording()
 						}
 					}
 
-					// Pointer cursor при наведении (только если не записываем)
-					if !a.isRecording && a.startRecordingButton.Hovered() {
+					// Pointer cursor при наведении (только если запись можно начать)
+					if canStart && a.startRecordingButton.Hovered() {
 						pointer.CursorPointer.Add(gtx.Ops)
 					}
 
@@ -2151,8 +2350,8 @@ func (a *Application) drawRecordingControls(gtx layout.Context, th *material.The
 					}
 
 					btn := material.Button(th, &a.startRecordingButton, buttonText)
-					if a.isRecording {
-						// Серая кнопка во время записи
+					if !canStart {
+						// Серая кнопка во время записи и пока нет нужных прав
 						btn.Background = color.NRGBA{R: 200, G: 200, B: 200, A: 255}
 						btn.Color = color.NRGBA{R: 100, G: 100, B: 100, A: 255}
 					} else {
@@ -2201,6 +2400,17 @@ func (a *Application) drawRecordingControls(gtx layout.Context, th *material.The
 						return label.Layout(gtx)
 					}
 				}),
+				// Сводка по записи
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if !a.hasCompletedRecording || a.recordingSummary == "" {
+						return layout.Dimensions{}
+					}
+					return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						label := material.Label(th, unit.Sp(12), a.recordingSummary)
+						label.Color = color.NRGBA{R: 80, G: 80, B: 80, A: 255}
+						return label.Layout(gtx)
+					})
+				}),
 				// Отступ между статусом и кнопкой браузера
 				layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
 				// Кнопка "Open in Browser" для HTML/heatmap файлов
@@ -2227,6 +2437,27 @@ func (a *Application) drawRecordingControls(gtx layout.Context, th *material.The
 					btn.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
 					return btn.Layout(gtx)
 				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+				// Кнопка просмотра flame graph в приложении
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if !a.hasCompletedRecording || a.lastJFRPath == "" {
+						return layout.Dimensions{}
+					}
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(150))
+					gtx.Constraints.Max.X = gtx.Dp(unit.Dp(150))
+
+					for a.viewFlameButton.Clicked(gtx) {
+						a.openFlameViewer(a.lastJFRPath, a.convertOptions)
+					}
+					if a.viewFlameButton.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+
+					btn := material.Button(th, &a.viewFlameButton, "View Flame Graph")
+					btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+					btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+					return btn.Layout(gtx)
+				}),
 			)
 		}),
 	)
@@ -2237,14 +2468,43 @@ func (a *Application) drawAsprofArgsInput(gtx layout.Context, th *material.Theme
 	if a.asprofArgsEditor.Text() != a.asprofArgs {
 		a.asprofArgs = a.asprofArgsEditor.Text()
 		a.saveAsprofArgs() // Сохраняем при каждом изменении
+		if a.asprofArgs != "" {
+			a.rememberSetting(asprofArgsSettingName, a.asprofArgs)
+		}
 	}
 
-	// Ограничиваем высоту поля ввода (как у кнопок селекторов)
-	maxHeight := gtx.Dp(unit.Dp(48)) // примерно высота кнопки селектора
-	if gtx.Constraints.Max.Y > maxHeight {
-		gtx.Constraints.Max.Y = maxHeight
+	row := layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		// Ограничиваем высоту поля ввода (как у кнопок селекторов)
+		maxHeight := gtx.Dp(unit.Dp(48)) // примерно высота кнопки селектора
+		if gtx.Constraints.Max.Y > maxHeight {
+			gtx.Constraints.Max.Y = maxHeight
+		}
+		return a.drawAsprofArgsRow(gtx, th)
+	})
+
+	validation := layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		return a.drawArgsValidation(gtx, th)
+	})
+
+	if !a.isArgsSectionExpanded() {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, row, validation)
 	}
 
+	// Раскрытый список пресетов или форма опций под полем аргументов
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		row,
+		validation,
+		layout.Rigid(layout.Spacer{Height: unit.Dp(6)}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if a.optionsForm != nil && a.optionsForm.IsExpanded() {
+				return a.optionsForm.LayoutForm(gtx, th, a)
+			}
+			return a.presetSelector.LayoutList(gtx, th, a)
+		}),
+	)
+}
+
+func (a *Application) drawAsprofArgsRow(gtx layout.Context, th *material.Theme) layout.Dimensions {
 	return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			// Фиксированная ширина для метки - 120dp (как у других селекторов)
@@ -2275,6 +2535,24 @@ func (a *Application) drawAsprofArgsInput(gtx layout.Context, th *material.Theme
 				},
 			)
 		}),
+		// Кнопка структурированной формы опций
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if a.optionsForm == nil {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return a.optionsForm.LayoutToggle(gtx, th, a)
+			})
+		}),
+		// Выбор пресета
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if a.presetSelector == nil {
+				return layout.Dimensions{}
+			}
+			return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return a.presetSelector.LayoutButton(gtx, th, a)
+			})
+		}),
 	)
 }
 
@@ -2299,8 +2577,12 @@ func (a *Application) detectVersion() {
 }
 
 func (a *Application) onVersionBadgeClicked() {
-	url := "https://github.com/async-profiler/async-profiler"
-	log.Printf("Открываем GitHub: %s", url)
+	a.openURL("https://github.com/async-profiler/async-profiler")
+}
+
+// openURL открывает ссылку в браузере по умолчанию
+func (a *Application) openURL(url string) {
+	log.Printf("Открываем ссылку: %s", url)
 
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
@@ -2364,324 +2646,12 @@ func (a *Application) openFolder(folderPath string) {
 	}
 }
 
-// Функция для выполнения kubectl команд с правильным KUBECONFIG
-func runKubectlWithConfig(kubeconfigPath string, args ...string) error {
-	cmd := exec.Command("kubectl", args...)
-	// Временно захватываем stderr для диагностики
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfigPath)
-	
-	// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
-	setSysProcAttr(cmd)
-	
-	err := cmd.Run()
-	if err != nil && stderr.Len() > 0 {
-		return fmt.Errorf("%v: %s", err, stderr.String())
-	}
-	return err
-}
-
-// Функция для копирования файла
-func copyFile(src, dst string) error {
-	input, err := os.ReadFile(src)
-	if err != nil {
-		return err
+func main() {
+	// Подкоманды командной строки работают без окна
+	if code, handled := runCLI(os.Args[1:]); handled {
+		os.Exit(code)
 	}
-	return os.WriteFile(dst, input, 0644)
-}
 
-// Функция для запуска профилирования в отдельной горутине
-func (a *Application) startRecording() {
-	go func() {
-		// Устанавливаем состояние записи
-		a.isRecording = true
-		a.recordingResult = ""
-		a.showBrowserButton = false // Скрываем кнопку браузера при новой записи
-		a.htmlOutputPath = ""       // Очищаем путь к HTML файлу
-		a.invalidate()
-
-		// Получаем параметры
-		selectedConfig := a.kubeconfigSelector.GetSelectedConfig()
-		selectedNamespace := a.namespaceSelector.GetSelectedNamespace()
-		selectedPod := a.podSelector.GetSelectedPod()
-		asprofArgs := a.asprofArgs
-		outputFolder := a.selectedFolder
-
-		// Создаем временный kubeconfig
-		home, err := os.UserHomeDir()
-		if err != nil {
-			a.recordingResult = fmt.Sprintf("Error getting home directory: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-
-		kubeconfigPath := filepath.Join(home, ".kube", selectedConfig)
-		data, err := os.ReadFile(kubeconfigPath)
-		if err != nil {
-			a.recordingResult = fmt.Sprintf("Error reading kubeconfig: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-
-		// Создаем временный файл kubeconfig
-		homeDir, _ := os.UserHomeDir()
-		tmpDir := filepath.Join(homeDir, ".k8s-jprof", "tmp")
-		if err := os.MkdirAll(tmpDir, 0755); err != nil {
-			a.recordingResult = fmt.Sprintf("Error creating temp directory: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-		defer os.RemoveAll(tmpDir)
-
-		tempKubeconfigPath := filepath.Join(tmpDir, "kubeconfig.yaml")
-		if err := os.WriteFile(tempKubeconfigPath, data, 0644); err != nil {
-			a.recordingResult = fmt.Sprintf("Error writing temp kubeconfig: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-
-		// Устанавливаем KUBECONFIG для этого процесса
-		originalKubeconfig := os.Getenv("KUBECONFIG")
-		os.Setenv("KUBECONFIG", tempKubeconfigPath)
-		defer os.Setenv("KUBECONFIG", originalKubeconfig)
-
-		// Пути для профайлера
-		profilerTar := a.profilerPath
-		remoteDir := "/tmp/async-profiler-4.1-linux-x64"
-		remoteTar := "/tmp/async-profiler-4.1-linux-x64.tar.gz"
-		remoteJfr := "/tmp/recording.jfr"
-
-		// Аргументы для namespace
-		nsArgs := []string{}
-		if selectedNamespace != "" {
-			nsArgs = append(nsArgs, "-n", selectedNamespace)
-		}
-
-		// Проверяем наличие профайлера в поде
-		checkArgs := append(nsArgs, "exec", selectedPod, "--", "bash", "-c", fmt.Sprintf("[ -d %s ]", remoteDir))
-		checkCmd := exec.Command("kubectl", checkArgs...)
-		checkCmd.Env = append(os.Environ(), "KUBECONFIG="+tempKubeconfigPath)
-		
-		// Захватываем stderr для подробной информации
-		var checkStderr bytes.Buffer
-		checkCmd.Stderr = &checkStderr
-		
-		// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
-		setSysProcAttr(checkCmd)
-
-		if err := checkCmd.Run(); err != nil {
-			// Показываем статус копирования
-			a.recordingResult = "Copying profiler..."
-			a.invalidate()
-			
-			// Копируем профайлер в под
-			copyArgs := append(nsArgs, "cp", profilerTar, fmt.Sprintf("%s:%s", selectedPod, remoteTar))
-			if err := runKubectlWithConfig(tempKubeconfigPath, copyArgs...); err != nil {
-				a.recordingResult = fmt.Sprintf("Error copying profiler: %v", err)
-				a.isRecording = false
-				a.invalidate()
-				return
-			}
-
-			// Показываем статус извлечения
-			a.recordingResult = "Extracting profiler..."
-			a.invalidate()
-
-			// Извлекаем профайлер
-			extractArgs := append(nsArgs, "exec", selectedPod, "--", "bash", "-c", fmt.Sprintf("tar xzf %s -C /tmp", remoteTar))
-			if err := runKubectlWithConfig(tempKubeconfigPath, extractArgs...); err != nil {
-				a.recordingResult = fmt.Sprintf("Error extracting profiler: %v", err)
-				a.isRecording = false
-				a.invalidate()
-				return
-			}
-		}
-
-		// Показываем статус запуска профайлера
-		a.recordingResult = "Starting profiler..."
-		a.invalidate()
-
-		// Запускаем профайлер
-		profilerCmd := fmt.Sprintf("%s/bin/asprof -f %s %s 1", remoteDir, remoteJfr, asprofArgs)
-		execArgs := append(nsArgs, "exec", selectedPod, "--", "bash", "-c", profilerCmd)
-		if err := runKubectlWithConfig(tempKubeconfigPath, execArgs...); err != nil {
-			a.recordingResult = fmt.Sprintf("Error running profiler: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-
-		// Показываем статус копирования результата
-		a.recordingResult = "Copying result..."
-		a.invalidate()
-
-		// Создаем имя файла с timestamp
-		timestamp := time.Now().Format("20060102_150405")
-		filename := fmt.Sprintf("%s__%s__%s.jfr", selectedNamespace, selectedPod, timestamp)
-		
-		// Создаем целевую папку если она не существует
-		outputPath := filepath.Join(outputFolder, filename)
-		if err := os.MkdirAll(outputFolder, 0755); err != nil {
-			a.recordingResult = fmt.Sprintf("Error creating output folder: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-
-		// Сначала копируем из пода в текущую папку (рядом с исполняемым файлом)
-		localTempFile := "./" + filename
-		
-		var sourceSpec string
-		if selectedNamespace != "" {
-			sourceSpec = fmt.Sprintf("%s/%s:%s", selectedNamespace, selectedPod, remoteJfr)
-		} else {
-			sourceSpec = fmt.Sprintf("%s:%s", selectedPod, remoteJfr)
-		}
-		
-		copyResultArgs := []string{"cp", sourceSpec, localTempFile}
-		if err := runKubectlWithConfig(tempKubeconfigPath, copyResultArgs...); err != nil {
-			a.recordingResult = fmt.Sprintf("Error copying result: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-
-		// Перемещаем из текущей папки в целевую папку
-		if err := os.Rename(localTempFile, outputPath); err != nil {
-			a.recordingResult = fmt.Sprintf("Error moving file: %v", err)
-			a.isRecording = false
-			a.invalidate()
-			return
-		}
-
-		// Конвертируем JFR если выбран формат
-		selectedFormat := a.formatSelector.GetSelectedFormat()
-		if selectedFormat != "" && selectedFormat != "(none)" {
-			a.recordingResult = "Converting JFR..."
-			a.invalidate()
-
-			// Копируем JFR файл обратно в текущую папку для конвертации
-			if err := copyFile(outputPath, localTempFile); err != nil {
-				a.recordingResult = fmt.Sprintf("Error preparing for conversion: %v", err)
-				a.isRecording = false
-				a.invalidate()
-				return
-			}
-
-			// Запускаем конвертер
-			converterPath := "./data/jfr-converter.jar"
-			convertCmd := exec.Command("java", "-jar", converterPath, "-o", selectedFormat, localTempFile)
-			
-			// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
-			setSysProcAttr(convertCmd)
-			
-			// Захватываем stderr для подробной информации об ошибках
-			var stderr bytes.Buffer
-			convertCmd.Stderr = &stderr
-			
-			if err := convertCmd.Run(); err != nil {
-				errMsg := fmt.Sprintf("Error converting JFR: %v", err)
-				if stderr.Len() > 0 {
-					errMsg += fmt.Sprintf(" | Details: %s", stderr.String())
-				}
-				a.recordingResult = errMsg
-				a.isRecording = false
-				os.Remove(localTempFile) // Удаляем временный файл
-				a.invalidate()
-				return
-			}
-
-			// jfr-converter создает файл с тем же именем как входной, но с другим расширением
-			// Ищем любой файл с базовым именем JFR файла
-			baseNameWithoutExt := strings.TrimSuffix(localTempFile, ".jfr")
-			pattern := baseNameWithoutExt + ".*"
-			
-			files, err := filepath.Glob(pattern)
-			if err != nil || len(files) == 0 {
-				a.recordingResult = "Error: converted file not found"
-				a.isRecording = false
-				os.Remove(localTempFile)
-				a.invalidate()
-				return
-			}
-			
-			// Находим файл который НЕ JFR (созданный конвертером)
-			var convertedFile string
-			for _, file := range files {
-				if !strings.HasSuffix(file, ".jfr") {
-					convertedFile = file
-					break
-				}
-			}
-			
-			if convertedFile == "" {
-				a.recordingResult = "Error: no converted file found (only JFR)"
-				a.isRecording = false
-				os.Remove(localTempFile)
-				a.invalidate()
-				return
-			}
-			
-			// Получаем расширение созданного файла
-			actualExt := filepath.Ext(convertedFile)
-			
-			// Создаем правильное имя для выходного файла
-			baseFilename := fmt.Sprintf("%s__%s__%s", selectedNamespace, selectedPod, timestamp)
-			finalOutputPath := filepath.Join(outputFolder, baseFilename+actualExt)
-			if err := os.Rename(convertedFile, finalOutputPath); err != nil {
-				a.recordingResult = fmt.Sprintf("Error moving converted file: %v", err)
-				a.isRecording = false
-				os.Remove(localTempFile) // Удаляем временный файл
-				a.invalidate()
-				return
-			}
-
-			// Сохраняем путь к HTML файлу для кнопки браузера и определяем showBrowserButton
-			if actualExt == ".html" || selectedFormat == "heatmap" || selectedFormat == "html" {
-				a.htmlOutputPath = finalOutputPath
-			}
-
-			// Удаляем временный JFR файл
-			os.Remove(localTempFile)
-
-			a.outputPath = outputFolder // Сохраняем путь для кликабельности
-		} else {
-			a.outputPath = filepath.Dir(outputPath) // Сохраняем папку с файлом
-		}
-
-		// Очищаем временные файлы в поде
-		a.recordingResult = "Cleaning up..."
-		a.invalidate()
-		
-		// Удаляем файлы и папку профилировщика
-		cleanupArgs := append(nsArgs, "exec", selectedPod, "--", "rm", "-rf", remoteJfr, remoteTar, remoteDir)
-		runKubectlWithConfig(tempKubeconfigPath, cleanupArgs...) // Игнорируем ошибки очистки
-
-		// Завершение
-		if selectedFormat != "" && selectedFormat != "(none)" {
-			a.recordingResult = fmt.Sprintf("Saved JFR and %s files to %s", selectedFormat, outputFolder)
-			a.outputPath = outputFolder // Сохраняем путь для кликабельности
-		} else {
-			a.recordingResult = fmt.Sprintf("Saved JFR to %s", outputFolder)
-			a.outputPath = filepath.Dir(outputPath) // Сохраняем папку с файлом
-		}
-		a.isRecording = false
-		a.hasCompletedRecording = true // Помечаем что запись завершена
-
-		if selectedFormat == "heatmap" || selectedFormat == "html" {
-			a.showBrowserButton = true
-		}
-		
-		a.invalidate()
-	}()
-}
-
-func main() {
 	go func() {
 		w := new(app.Window)
 		w.Option(app.Title("k8s-jprof 1.0.beta"))
@@ -2704,7 +2674,7 @@ func run(w *app.Window) error {
 	var ops op.Ops
 
 	appInstance := NewApplication()
-	appInstance.invalidate = w.Invalidate
+	appInstance.setInvalidate(w.Invalidate)
 
 	// Функция закрытия окна - больше не нужна, окно закрывается стандартными способами
 	// closeWindow := func() {
@@ -2721,6 +2691,9 @@ func run(w *app.Window) error {
 			// Принудительное завершение программы при закрытии окна
 			os.Exit(0)
 		case app.FrameEvent:
+			// Применяем изменения от фоновых горутин до отрисовки кадра
+			appInstance.applyPendingEvents()
+
 			gtx := app.NewContext(&ops, e)
 
 			// Основной layout
@@ -2784,8 +2757,14 @@ func run(w *app.Window) error {
 							for appInstance.versionBadge.Clicked(gtx) {
 								appInstance.onVersionBadgeClicked()
 							}
+							for appInstance.openJFRButton.Clicked(gtx) {
+								appInstance.openJFR()
+							}
+							for appInstance.historyButton.Clicked(gtx) {
+								appInstance.openHistory()
+							}
 							// Устанавливаем pointer cursor при наведении
-							if appInstance.versionBadge.Hovered() {
+							if appInstance.versionBadge.Hovered() || appInstance.openJFRButton.Hovered() || appInstance.historyButton.Hovered() {
 								pointer.CursorPointer.Add(gtx.Ops)
 							}
 							
@@ -2874,19 +2853,26 @@ func run(w *app.Window) error {
 									}(),
 									layout.Rigid(layout.Spacer{Height: unit.Dp(16)}.Layout),
 									// Поле ввода аргументов async-profiler
-									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-										var selectedNamespace, selectedPod string
-										if appInstance.namespaceSelector != nil {
-											selectedNamespace = appInstance.namespaceSelector.GetSelectedNamespace()
-										}
-										if appInstance.podSelector != nil {
-											selectedPod = appInstance.podSelector.GetSelectedPod()
+									func() layout.FlexChild {
+										argsInput := func(gtx layout.Context) layout.Dimensions {
+											var selectedNamespace, selectedPod string
+											if appInstance.namespaceSelector != nil {
+												selectedNamespace = appInstance.namespaceSelector.GetSelectedNamespace()
+											}
+											if appInstance.podSelector != nil {
+												selectedPod = appInstance.podSelector.GetSelectedPod()
+											}
+											if selectedConfig == "" || selectedNamespace == "" || selectedPod == "" {
+												return layout.Dimensions{}
+											}
+											return appInstance.drawAsprofArgsInput(gtx, th)
 										}
-										if selectedConfig == "" || selectedNamespace == "" || selectedPod == "" {
-											return layout.Dimensions{}
+										if appInstance.isArgsSectionExpanded() {
+											// Если список пресетов или форма раскрыты - они занимают максимум места
+											return layout.Flexed(1, argsInput)
 										}
-										return appInstance.drawAsprofArgsInput(gtx, th)
-									}),
+										return layout.Rigid(argsInput)
+									}(),
 									layout.Rigid(layout.Spacer{Height: unit.Dp(16)}.Layout),
 									// Селектор папки для JFR
 									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -2899,19 +2885,26 @@ func run(w *app.Window) error {
 									}),
 									layout.Rigid(layout.Spacer{Height: unit.Dp(16)}.Layout),
 									// Селектор формата JFR
-									layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-										var selectedNamespace, selectedPod string
-										if appInstance.namespaceSelector != nil {
-											selectedNamespace = appInstance.namespaceSelector.GetSelectedNamespace()
-										}
-										if appInstance.podSelector != nil {
-											selectedPod = appInstance.podSelector.GetSelectedPod()
+									func() layout.FlexChild {
+										formatSelector := func(gtx layout.Context) layout.Dimensions {
+											var selectedNamespace, selectedPod string
+											if appInstance.namespaceSelector != nil {
+												selectedNamespace = appInstance.namespaceSelector.GetSelectedNamespace()
+											}
+											if appInstance.podSelector != nil {
+												selectedPod = appInstance.podSelector.GetSelectedPod()
+											}
+											if selectedConfig == "" || selectedNamespace == "" || selectedPod == "" || appInstance.formatSelector == nil {
+												return layout.Dimensions{}
+											}
+											return appInstance.formatSelector.Layout(gtx, th, appInstance)
 										}
-										if selectedConfig == "" || selectedNamespace == "" || selectedPod == "" || appInstance.formatSelector == nil {
-											return layout.Dimensions{}
+										if appInstance.convertOptionsForm != nil && appInstance.convertOptionsForm.IsExpanded() {
+											// Раскрытая форма опций конвертации занимает оставшееся место
+											return layout.Flexed(1, formatSelector)
 										}
-										return appInstance.formatSelector.Layout(gtx, th, appInstance)
-									}),
+										return layout.Rigid(formatSelector)
+									}(),
 								)
 							})
 						}),
@@ -2946,13 +2939,25 @@ func run(w *app.Window) error {
 				layout.Stacked(func(gtx layout.Context) layout.Dimensions {
 					return appInstance.drawRecordingOverlay(gtx, th)
 				}),
+				// Окно истории записей
+				layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+					return appInstance.drawHistoryPanel(gtx, th)
+				}),
+				// Окно конвертации сохраненного JFR (открывается и из истории, поэтому поверх нее)
+				layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+					return appInstance.drawConvertPanel(gtx, th)
+				}),
+				// Просмотр flame graph (открывается из истории и окна конвертации)
+				layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+					return appInstance.drawFlameViewer(gtx, th)
+				}),
 				// Занавес во время выбора папки
 				layout.Stacked(func(gtx layout.Context) layout.Dimensions {
 					return appInstance.drawFolderChoosingOverlay(gtx, th)
 				}),
 				// Overlay для ошибок сети
 				layout.Stacked(func(gtx layout.Context) layout.Dimensions {
-					return appInstance.drawNetworkErrorOverlay(gtx, th)
+					return appInstance.drawClusterErrorOverlay(gtx, th)
 				}),
 			)
 
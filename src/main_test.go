@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatSelectorSetSelection(t *testing.T) {
+	tempHome(t)
+	tests := []struct {
+		value  string
+		wantOK bool
+		want   string
+	}{
+		{"html", true, "html"},
+		{"pprof,html", true, "html,pprof"}, // порядок списка форматов, а не сохраненный
+		{" html , collapsed ", true, "html,collapsed"},
+		{"html,html", true, "html"},
+		{"html,svg", true, "html"},
+		{"(none)", true, "(none)"},
+		{"html,(none)", true, "(none)"},
+		{"svg", false, "heatmap"},
+		{"", false, "heatmap"},
+	}
+	for _, tt := range tests {
+		fs := NewFormatSelector()
+		if ok := fs.SetSelection(tt.value); ok != tt.wantOK {
+			t.Errorf("SetSelection(%q) = %v, want %v", tt.value, ok, tt.wantOK)
+		}
+		if got := fs.Selection(); got != tt.want {
+			t.Errorf("SetSelection(%q): selection = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSelectorToggle(t *testing.T) {
+	tempHome(t)
+	fs := NewFormatSelector()
+	steps := []struct {
+		toggle string
+		want   string
+	}{
+		{"html", "html,heatmap"},
+		{"pprof", "html,pprof,heatmap"},
+		{"heatmap", "html,pprof"},
+		{"html", "pprof"},
+		{"pprof", "(none)"},
+		{"(none)", "(none)"},
+		{"otlp", "otlp"},
+	}
+	for _, step := range steps {
+		fs.toggle(step.toggle)
+		if got := fs.Selection(); got != step.want {
+			t.Fatalf("toggle(%q): selection = %q, want %q", step.toggle, got, step.want)
+		}
+	}
+	if got := fs.GetSelectedFormats(); len(got) != 1 || got[0] != "otlp" {
+		t.Errorf("GetSelectedFormats = %q, want [otlp]", got)
+	}
+}
+
+func TestFormatSelectorLoadSelection(t *testing.T) {
+	home := tempHome(t)
+	configFile := filepath.Join(home, ".k8s-jprof", "convert_format.mem")
+
+	// Без сохраненного выбора - heatmap, и он записывается в файл
+	if got := NewFormatSelector().Selection(); got != "heatmap" {
+		t.Errorf("default selection = %q, want heatmap", got)
+	}
+	if data, err := os.ReadFile(configFile); err != nil || string(data) != "heatmap" {
+		t.Errorf("saved default = %q, %v; want heatmap", data, err)
+	}
+
+	fs := NewFormatSelector()
+	fs.toggle("collapsed")
+	fs.saveSelection()
+	if got := NewFormatSelector().Selection(); got != "collapsed,heatmap" {
+		t.Errorf("reloaded selection = %q, want collapsed,heatmap", got)
+	}
+
+	// Поврежденный файл заменяется значением по умолчанию
+	if err := os.WriteFile(configFile, []byte("svg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewFormatSelector().Selection(); got != "heatmap" {
+		t.Errorf("selection from invalid file = %q, want heatmap", got)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+)
+
+// MergeSource вход объединенного профиля: JFR (в том числе .jfr.gz) или collapsed-файл
+type MergeSource struct {
+	Path      string  `json:"path"`
+	Weight    float64 `json:"weight"`              // множитель сэмплов источника
+	Samples   int64   `json:"samples"`             // сэмплов в источнике до взвешивания
+	Namespace string  `json:"namespace,omitempty"` // из sidecar источника, если он есть
+	Pod       string  `json:"pod,omitempty"`
+}
+
+// MergeProfiles объединяет профили в один, складывая одинаковые стеки. Сэмплы каждого источника
+// умножаются на его вес; с normalize источник сначала приводится к среднему числу сэмплов,
+// чтобы длинная запись или нагруженная реплика не перевешивала остальные.
+// Профили с разными видами событий или единицами значений не складываются: сумма CPU-сэмплов
+// и байтов аллокаций ничего не значит. Пустой вид или единица совместимы с любыми
+func MergeProfiles(profiles []*Profile, weights []float64, normalize bool) (*Profile, error) {
+	merged := &Profile{}
+	for _, p := range profiles {
+		if merged.Event == "" {
+			merged.Event = p.Event
+		} else if p.Event != "" && p.Event != merged.Event {
+			return nil, fmt.Errorf("cannot merge %s and %s profiles", merged.Event, p.Event)
+		}
+		if merged.Unit == "" {
+			merged.Unit = p.Unit
+		} else if p.Unit != "" && p.Unit != merged.Unit {
+			return nil, fmt.Errorf("cannot merge %s profiles measured in %s and %s (use recordings of the same kind, e.g. all JFR or all collapsed)", merged.Event, merged.Unit, p.Unit)
+		}
+	}
+	if len(profiles) == 0 {
+		return merged, nil
+	}
+
+	totals := make([]int64, len(profiles))
+	var sum int64
+	for i, p := range profiles {
+		for _, s := range p.Stacks {
+			totals[i] += s.Samples
+		}
+		sum += totals[i]
+	}
+	mean := float64(sum) / float64(len(profiles))
+
+	// Дробные значения копятся до конца, чтобы не терять мелкие стеки на округлении каждого источника
+	type stackValues struct {
+		frames          []Frame
+		samples, values float64
+	}
+	index := map[string]int{}
+	var stacks []stackValues
+	for i, p := range profiles {
+		factor := 1.0
+		if i < len(weights) {
+			factor = weights[i]
+		}
+		if normalize && totals[i] > 0 {
+			factor *= mean / float64(totals[i])
+		}
+		for _, s := range p.Stacks {
+			// Имена лямбд и скрытых классов различаются между JVM, как и в сравнении профилей
+			frames := make([]Frame, len(s.Frames))
+			for k, f := range s.Frames {
+				frames[k] = f
+				frames[k].Name = normalizeFrameName(f.Name)
+			}
+			key := stackKey(frames)
+			j, ok := index[key]
+			if !ok {
+				j = len(stacks)
+				index[key] = j
+				stacks = append(stacks, stackValues{frames: frames})
+			}
+			stacks[j].samples += float64(s.Samples) * factor
+			stacks[j].values += float64(s.Value) * factor
+		}
+		if p.StartNanos != 0 && (merged.StartNanos == 0 || p.StartNanos < merged.StartNanos) {
+			merged.StartNanos = p.StartNanos
+		}
+		if p.EndNanos > merged.EndNanos {
+			merged.EndNanos = p.EndNanos
+		}
+	}
+	for _, s := range stacks {
+		samples := int64(math.Round(s.samples))
+		if samples <= 0 {
+			continue
+		}
+		merged.Stacks = append(merged.Stacks, ProfileStack{Frames: s.frames, Samples: samples, Value: int64(math.Round(s.values))})
+	}
+	return merged, nil
+}
+
+// mergeOutputBase путь результата без расширения: merged__<время> в папке первого источника
+func mergeOutputBase(sources []MergeSource, now time.Time) string {
+	dir := filepath.Dir(sources[0].Path)
+	return filepath.Join(dir, uniqueOutputBase(dir, "merged__"+now.Format("20060102_150405")))
+}
+
+// mergeFiles объединяет источники и пишет результат в выбранные форматы рядом с outputBase,
+// плюс sidecar со списком источников. jfr-converter работает только с одним JFR, поэтому otlp недоступен,
+// а вместо heatmap пишется flame graph
+func mergeFiles(ctx context.Context, sources []MergeSource, outputBase string, formats []string, opts ConvertOptions, normalize bool) ([]conversionResult, string, error) {
+	if len(sources) < 2 {
+		return nil, "", fmt.Errorf("select at least two recordings to merge")
+	}
+	weights := make([]float64, len(sources))
+	for i, s := range sources {
+		if s.Weight <= 0 {
+			return nil, "", fmt.Errorf("invalid weight %v for %s", s.Weight, filepath.Base(s.Path))
+		}
+		weights[i] = s.Weight
+		if abs, err := filepath.Abs(s.Path); err == nil {
+			sources[i].Path = abs
+		}
+		if meta, err := readRecordingMetadata(metadataPath(s.Path)); err == nil {
+			sources[i].Namespace = meta.Namespace
+			sources[i].Pod = meta.Pod
+		}
+	}
+
+	selected := map[string]bool{}
+	for _, format := range formats {
+		selected[format] = true
+	}
+	var results []conversionResult
+	var start, end int64
+	for n, event := range opts.events() {
+		readOpts, err := opts.readOptions(event)
+		if err != nil {
+			return nil, "", err
+		}
+		profiles := make([]*Profile, len(sources))
+		for i, s := range sources {
+			p, err := readProfileFile(ctx, s.Path, readOpts)
+			if err != nil {
+				return nil, "", fmt.Errorf("reading %s: %v", filepath.Base(s.Path), err)
+			}
+			// Вид событий первого источника используется для остальных
+			if readOpts.Event == "" && p.Event != "" {
+				readOpts.Event = p.Event
+			}
+			if p.Event == "" {
+				p.Event = readOpts.Event
+			}
+			profiles[i] = p
+			if n == 0 {
+				sources[i].Samples = 0
+				for _, stack := range p.Stacks {
+					sources[i].Samples += stack.Samples
+				}
+			}
+		}
+		merged, err := MergeProfiles(profiles, weights, normalize)
+		if err != nil {
+			return nil, "", err
+		}
+		if merged.Event == "" {
+			merged.Event = readOpts.Event
+		}
+		if start == 0 || (merged.StartNanos != 0 && merged.StartNanos < start) {
+			start = merged.StartNanos
+		}
+		if merged.EndNanos > end {
+			end = merged.EndNanos
+		}
+
+		title := opts.title(merged.Event)
+		if opts.Title == "" {
+			title = fmt.Sprintf("%s (%d recordings merged)", title, len(sources))
+		}
+		for _, format := range formats {
+			result := conversionResult{format: format, event: event}
+			base := outputBase
+			if event != "" {
+				base += "-" + event
+			}
+			if format == "heatmap" && selected["html"] {
+				base += "-heatmap"
+			}
+			switch {
+			case format == "heatmap":
+				result.path = base + ".html"
+				result.err = writeProfileFile(merged, "html", result.path, title)
+			case nativeFormats[format] == "":
+				result.err = fmt.Errorf("format %s needs a single JFR recording and is not available for merged profiles", format)
+			default:
+				result.path = base + nativeFormats[format]
+				result.err = writeProfileFile(merged, format, result.path, title)
+			}
+			results = append(results, result)
+		}
+	}
+
+	meta := RecordingMetadata{Formats: formats, ConvertOptions: opts, Sources: sources}
+	// Цель заполняется, только если все источники сняты с одного пода или namespace
+	meta.Namespace, meta.Pod = sources[0].Namespace, sources[0].Pod
+	for _, s := range sources[1:] {
+		if s.Namespace != meta.Namespace {
+			meta.Namespace = ""
+		}
+		if s.Pod != meta.Pod {
+			meta.Pod = ""
+		}
+	}
+	if start != 0 {
+		meta.Start = time.Unix(0, start)
+	}
+	if end != 0 {
+		meta.End = time.Unix(0, end)
+	}
+	for _, r := range results {
+		if r.err == nil {
+			meta.Files = append(meta.Files, filepath.Base(r.path))
+		}
+	}
+	if len(meta.Files) == 0 {
+		return results, "", nil
+	}
+	metaPath := outputBase + metadataSuffix
+	if err := writeRecordingMetadata(metaPath, meta); err != nil {
+		return results, "", fmt.Errorf("writing metadata: %v", err)
+	}
+	return results, metaPath, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeProfilesWeights(t *testing.T) {
+	a := collapsedProfile("cpu", "samples",
+		"main;a;b 30",
+		"main;a;Foo$$Lambda$57/0x0000000800ff1234.run 10",
+	)
+	b := collapsedProfile("cpu", "samples",
+		"main;a;b 5",
+		"main;c 5",
+		"main;a;Foo$$Lambda$12/0x000000080012abcd.run 10",
+	)
+
+	tests := []struct {
+		name      string
+		weights   []float64
+		normalize bool
+		want      []string
+	}{
+		{
+			name: "plain sum",
+			want: []string{"main;a;Foo$$Lambda.run 20", "main;a;b 35", "main;c 5"},
+		},
+		{
+			name:    "weights",
+			weights: []float64{1, 3},
+			want:    []string{"main;a;Foo$$Lambda.run 40", "main;a;b 45", "main;c 15"},
+		},
+		{
+			// Среднее 30 сэмплов: a (40) умножается на 0.75, b (20) на 1.5
+			name:      "normalize",
+			normalize: true,
+			want:      []string{"main;a;Foo$$Lambda.run 23", "main;a;b 30", "main;c 8"},
+		},
+		{
+			name:      "weights after normalize",
+			weights:   []float64{2, 1},
+			normalize: true,
+			want:      []string{"main;a;Foo$$Lambda.run 30", "main;a;b 53", "main;c 8"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeProfiles([]*Profile{a, b}, tt.weights, tt.normalize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := collapsedLines(merged); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merged stacks = %q, want %q", got, tt.want)
+			}
+			if merged.Event != "cpu" || merged.Unit != "samples" {
+				t.Errorf("merged event/unit = %s/%s, want cpu/samples", merged.Event, merged.Unit)
+			}
+		})
+	}
+}
+
+func TestMergeProfilesMismatch(t *testing.T) {
+	cpu := collapsedProfile("cpu", "samples", "main;a 1")
+	wall := collapsedProfile("wall", "samples", "main;a 1")
+	allocBytes := collapsedProfile("alloc", "bytes", "main;a 1")
+	allocCounts := collapsedProfile("alloc", "samples", "main;a 1")
+	unknown := collapsedProfile("", "", "main;a 1")
+
+	tests := []struct {
+		name     string
+		profiles []*Profile
+		wantErr  string
+	}{
+		{name: "different events", profiles: []*Profile{cpu, wall}, wantErr: "cannot merge cpu and wall profiles"},
+		{name: "different events later", profiles: []*Profile{cpu, cpu, allocBytes}, wantErr: "cannot merge cpu and alloc profiles"},
+		{name: "different units", profiles: []*Profile{allocBytes, allocCounts}, wantErr: "measured in bytes and samples"},
+		{name: "same kind", profiles: []*Profile{allocBytes, allocBytes}},
+		{name: "unknown event is compatible", profiles: []*Profile{unknown, cpu}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, normalize := range []bool{false, true} {
+				_, err := MergeProfiles(tt.profiles, nil, normalize)
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Errorf("normalize=%v: unexpected error %v", normalize, err)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("normalize=%v: error = %v, want %q", normalize, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// metadataSuffix sidecar с описанием записи: ns__pod__timestamp.meta.json рядом с ns__pod__timestamp.jfr
+const metadataSuffix = ".meta.json"
+
+// RecordingMetadata содержимое sidecar: откуда, чем и с какими параметрами снята запись.
+// Все сведения о цели собираются по возможности: недоступные поля остаются пустыми
+type RecordingMetadata struct {
+	Version int `json:"version"`
+
+	Kubeconfig string `json:"kubeconfig"`
+	Context    string `json:"context,omitempty"`
+	Cluster    string `json:"cluster,omitempty"`
+	Server     string `json:"server,omitempty"`
+
+	Namespace  string              `json:"namespace"`
+	Pod        string              `json:"pod"`
+	Container  string              `json:"container,omitempty"` // контейнер, в котором запускался asprof
+	Node       string              `json:"node,omitempty"`
+	Containers []ContainerMetadata `json:"containers,omitempty"`
+
+	JVM *JVMMetadata `json:"jvm,omitempty"`
+
+	AsprofArgs      string         `json:"asprof_args"`
+	ProfilerVersion string         `json:"profiler_version"`
+	Formats         []string       `json:"formats,omitempty"`
+	ConvertOptions  ConvertOptions `json:"convert_options"`
+
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	Files []string `json:"files"` // имена файлов записи в той же папке
+
+	Sources []MergeSource `json:"sources,omitempty"` // входы объединенного профиля
+}
+
+// ContainerMetadata контейнер пода: образ из спецификации и фактический digest
+type ContainerMetadata struct {
+	Name    string `json:"name"`
+	Image   string `json:"image"`
+	ImageID string `json:"image_id,omitempty"`
+}
+
+// JVMMetadata сведения о JVM из события jdk.JVMInformation в самом JFR
+type JVMMetadata struct {
+	Name          string `json:"name,omitempty"`
+	Version       string `json:"version,omitempty"`
+	JVMArguments  string `json:"jvm_arguments,omitempty"`
+	JavaArguments string `json:"java_arguments,omitempty"`
+}
+
+// metadataPath путь sidecar для файла записи
+func metadataPath(path string) string {
+	return recordingBase(path) + metadataSuffix
+}
+
+// collectTargetMetadata заполняет контекст kubeconfig, узел и контейнеры пода.
+// Ошибки kubectl только логируются: без метаданных запись все равно полезна
+func collectTargetMetadata(ctx context.Context, meta *RecordingMetadata, kubeconfig string, timeout time.Duration) {
+	configCtx, cancel := context.WithTimeout(ctx, timeout)
+	output, err := runKubectlOutput(configCtx, "reading kubeconfig context", kubeconfig, "config", "view", "--minify", "-o", "json")
+	cancel()
+	if err != nil {
+		log.Printf("Метаданные: не удалось прочитать контекст kubeconfig: %v", err)
+	} else {
+		parseKubeconfigMetadata(meta, output)
+	}
+
+	args := []string{"get", "pod", meta.Pod, "-o", "json"}
+	if meta.Namespace != "" {
+		args = append([]string{"-n", meta.Namespace}, args...)
+	}
+	podCtx, cancel := context.WithTimeout(ctx, timeout)
+	output, err = runKubectlOutput(podCtx, "reading pod", kubeconfig, args...)
+	cancel()
+	if err != nil {
+		log.Printf("Метаданные: не удалось прочитать описание пода: %v", err)
+		return
+	}
+	parsePodMetadata(meta, output)
+}
+
+// parseKubeconfigMetadata разбирает "kubectl config view --minify -o json"
+func parseKubeconfigMetadata(meta *RecordingMetadata, data []byte) {
+	var config struct {
+		CurrentContext string `json:"current-context"`
+		Contexts       []struct {
+			Context struct {
+				Cluster string `json:"cluster"`
+			} `json:"context"`
+		} `json:"contexts"`
+		Clusters []struct {
+			Cluster struct {
+				Server string `json:"server"`
+			} `json:"cluster"`
+		} `json:"clusters"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Метаданные: некорректный вывод kubectl config view: %v", err)
+		return
+	}
+	meta.Context = config.CurrentContext
+	if len(config.Contexts) > 0 {
+		meta.Cluster = config.Contexts[0].Context.Cluster
+	}
+	if len(config.Clusters) > 0 {
+		meta.Server = config.Clusters[0].Cluster.Server
+	}
+}
+
+// parsePodMetadata разбирает "kubectl get pod -o json": узел, контейнеры и digest образов
+func parsePodMetadata(meta *RecordingMetadata, data []byte) {
+	var pod struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Spec struct {
+			NodeName   string `json:"nodeName"`
+			Containers []struct {
+				Name  string `json:"name"`
+				Image string `json:"image"`
+			} `json:"containers"`
+		} `json:"spec"`
+		Status struct {
+			ContainerStatuses []struct {
+				Name    string `json:"name"`
+				ImageID string `json:"imageID"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(data, &pod); err != nil {
+		log.Printf("Метаданные: некорректный вывод kubectl get pod: %v", err)
+		return
+	}
+
+	meta.Node = pod.Spec.NodeName
+	imageIDs := map[string]string{}
+	for _, status := range pod.Status.ContainerStatuses {
+		imageIDs[status.Name] = status.ImageID
+	}
+	meta.Containers = nil
+	for _, c := range pod.Spec.Containers {
+		meta.Containers = append(meta.Containers, ContainerMetadata{Name: c.Name, Image: c.Image, ImageID: imageIDs[c.Name]})
+	}
+
+	// kubectl exec без -c выполняется в контейнере по умолчанию: из аннотации или первом
+	meta.Container = pod.Metadata.Annotations["kubectl.kubernetes.io/default-container"]
+	if meta.Container == "" && len(pod.Spec.Containers) > 0 {
+		meta.Container = pod.Spec.Containers[0].Name
+	}
+}
+
+// readJVMMetadata сведения о JVM из первого события jdk.JVMInformation; nil, если его нет
+func readJVMMetadata(jfrPath string) *JVMMetadata {
+	var jvm *JVMMetadata
+	err := readJFRFile(jfrPath, func(chunk *jfrChunk, e jfrEvent) error {
+		if jvm != nil || e.typ.name != "jdk.JVMInformation" {
+			return nil
+		}
+		jvm = &JVMMetadata{
+			Name:          chunk.stringField(e.typ, e.fields, "jvmName"),
+			Version:       chunk.stringField(e.typ, e.fields, "jvmVersion"),
+			JVMArguments:  chunk.stringField(e.typ, e.fields, "jvmArguments"),
+			JavaArguments: chunk.stringField(e.typ, e.fields, "javaArguments"),
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Метаданные: не удалось прочитать JFR: %v", err)
+	}
+	return jvm
+}
+
+// writeRecordingMetadata пишет sidecar рядом с записью
+func writeRecordingMetadata(path string, meta RecordingMetadata) error {
+	meta.Version = 1
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path, func(file *os.File) error {
+		_, err := file.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// readRecordingMetadata читает sidecar записи
+func readRecordingMetadata(path string) (RecordingMetadata, error) {
+	var meta RecordingMetadata
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// addMetadataFiles дописывает в sidecar файлы, полученные повторной конвертацией. Нет sidecar - ничего не делает
+func addMetadataFiles(jfrPath string, files []string) error {
+	path := metadataPath(jfrPath)
+	meta, err := readRecordingMetadata(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, name := range meta.Files {
+		known[name] = true
+	}
+	changed := false
+	for _, file := range files {
+		if name := filepath.Base(file); !known[name] {
+			known[name] = true
+			meta.Files = append(meta.Files, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return writeRecordingMetadata(path, meta)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// minifiedKubeconfig вывод "kubectl config view --minify -o json": только текущий контекст и его кластер
+const minifiedKubeconfig = `{
+    "kind": "Config",
+    "apiVersion": "v1",
+    "preferences": {},
+    "clusters": [
+        {
+            "name": "prod-eu",
+            "cluster": {
+                "server": "https://10.0.0.1:6443",
+                "certificate-authority-data": "DATA+OMITTED"
+            }
+        }
+    ],
+    "users": [
+        {
+            "name": "dev",
+            "user": {
+                "token": "REDACTED"
+            }
+        }
+    ],
+    "contexts": [
+        {
+            "name": "dev@prod-eu",
+            "context": {
+                "cluster": "prod-eu",
+                "user": "dev",
+                "namespace": "shop"
+            }
+        }
+    ],
+    "current-context": "dev@prod-eu"
+}`
+
+func TestParseKubeconfigMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want RecordingMetadata
+	}{
+		{
+			name: "minified config",
+			data: minifiedKubeconfig,
+			want: RecordingMetadata{Context: "dev@prod-eu", Cluster: "prod-eu", Server: "https://10.0.0.1:6443"},
+		},
+		{
+			// Без current-context kubectl выводит пустой конфиг
+			name: "no current context",
+			data: `{"kind": "Config", "apiVersion": "v1", "preferences": {}, "clusters": null, "users": null, "contexts": null, "current-context": ""}`,
+		},
+		{
+			name: "invalid output",
+			data: `error: current-context must exist in order to minify`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta RecordingMetadata
+			parseKubeconfigMetadata(&meta, []byte(tt.data))
+			if !reflect.DeepEqual(meta, tt.want) {
+				t.Errorf("metadata = %+v, want %+v", meta, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePodMetadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		wantNode      string
+		wantContainer string
+		want          []ContainerMetadata
+	}{
+		{
+			name: "default container annotation",
+			data: `{
+				"metadata": {"name": "api-1", "annotations": {"kubectl.kubernetes.io/default-container": "app"}},
+				"spec": {
+					"nodeName": "node-a",
+					"containers": [
+						{"name": "istio-proxy", "image": "istio/proxyv2:1.20.0"},
+						{"name": "app", "image": "registry.example.com/shop/api:1.4.2"}
+					]
+				},
+				"status": {
+					"containerStatuses": [
+						{"name": "app", "imageID": "registry.example.com/shop/api@sha256:0123abcd"},
+						{"name": "istio-proxy", "imageID": "docker.io/istio/proxyv2@sha256:4567ef01"}
+					]
+				}
+			}`,
+			wantNode:      "node-a",
+			wantContainer: "app",
+			want: []ContainerMetadata{
+				{Name: "istio-proxy", Image: "istio/proxyv2:1.20.0", ImageID: "docker.io/istio/proxyv2@sha256:4567ef01"},
+				{Name: "app", Image: "registry.example.com/shop/api:1.4.2", ImageID: "registry.example.com/shop/api@sha256:0123abcd"},
+			},
+		},
+		{
+			// Под еще не запущен: статусов контейнеров нет, exec идет в первый контейнер
+			name: "pending pod",
+			data: `{
+				"metadata": {"name": "api-2"},
+				"spec": {"containers": [{"name": "api", "image": "api:latest"}, {"name": "sidecar", "image": "busybox"}]},
+				"status": {"phase": "Pending"}
+			}`,
+			wantContainer: "api",
+			want:          []ContainerMetadata{{Name: "api", Image: "api:latest"}, {Name: "sidecar", Image: "busybox"}},
+		},
+		{
+			name:          "invalid output",
+			data:          `Error from server (NotFound): pods "api-3" not found`,
+			wantNode:      "old-node",
+			wantContainer: "old",
+			want:          []ContainerMetadata{{Name: "old"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := RecordingMetadata{Node: "old-node", Container: "old", Containers: []ContainerMetadata{{Name: "old"}}}
+			parsePodMetadata(&meta, []byte(tt.data))
+			if meta.Node != tt.wantNode || meta.Container != tt.wantContainer {
+				t.Errorf("node/container = %q/%q, want %q/%q", meta.Node, meta.Container, tt.wantNode, tt.wantContainer)
+			}
+			if !reflect.DeepEqual(meta.Containers, tt.want) {
+				t.Errorf("containers = %+v, want %+v", meta.Containers, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddMetadataFiles(t *testing.T) {
+	dir := t.TempDir()
+	jfrPath := filepath.Join(dir, "shop__api-1__20240601-100000.jfr")
+	if got, want := metadataPath(jfrPath), filepath.Join(dir, "shop__api-1__20240601-100000.meta.json"); got != want {
+		t.Fatalf("metadataPath = %q, want %q", got, want)
+	}
+
+	// Без sidecar ничего не создается
+	if err := addMetadataFiles(jfrPath, []string{"x.html"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(metadataPath(jfrPath)); !os.IsNotExist(err) {
+		t.Fatalf("sidecar created for a recording without metadata: %v", err)
+	}
+
+	if err := writeRecordingMetadata(metadataPath(jfrPath), RecordingMetadata{Pod: "api-1", Files: []string{"shop__api-1__20240601-100000.jfr"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMetadataFiles(jfrPath, []string{filepath.Join(dir, "shop__api-1__20240601-100000.html"), filepath.Join(dir, "shop__api-1__20240601-100000.jfr")}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(metadataPath(jfrPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta RecordingMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"shop__api-1__20240601-100000.jfr", "shop__api-1__20240601-100000.html"}
+	if meta.Version != 1 || meta.Pod != "api-1" || !reflect.DeepEqual(meta.Files, want) {
+		t.Errorf("sidecar = %+v, want version 1, pod api-1 and files %q", meta, want)
+	}
+}
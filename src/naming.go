@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NamingSettings шаблоны имени файла записи и подпапок в выбранной папке.
+// Хранятся в ~/.k8s-jprof/naming.json; файл со значениями по умолчанию создается при первом запуске.
+type NamingSettings struct {
+	Template string `json:"template"` // имя файла без расширения
+	Folders  string `json:"folders"`  // подпапки через "/", например "{cluster}/{namespace}/{date}"; пусто - без подпапок
+}
+
+// namingPlaceholders плейсхолдеры, доступные в шаблонах
+var namingPlaceholders = []string{"cluster", "context", "kubeconfig", "namespace", "pod", "container", "event", "preset", "timestamp", "date"}
+
+var namingPlaceholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+func defaultNamingSettings() NamingSettings {
+	return NamingSettings{Template: "{namespace}__{pod}__{timestamp}"}
+}
+
+func getNamingFilePath() string {
+	return filepath.Join(getConfigDir(), "naming.json")
+}
+
+// loadNamingSettings читает naming.json. Некорректные шаблоны заменяются значениями по умолчанию
+func loadNamingSettings() NamingSettings {
+	n := defaultNamingSettings()
+	path := getNamingFilePath()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Создаем файл, чтобы шаблоны было видно и можно было поправить
+		if err := writeNamingSettings(path, n); err != nil {
+			log.Printf("Warning: failed to write %s: %v", path, err)
+		}
+		return n
+	}
+	if err != nil {
+		log.Printf("Warning: failed to read %s: %v", path, err)
+		return n
+	}
+
+	var file NamingSettings
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("Warning: invalid %s: %v", path, err)
+		return n
+	}
+	if err := validateNamingTemplate(file.Template, false); err != nil {
+		log.Printf("Warning: invalid template %q in %s: %v", file.Template, path, err)
+	} else {
+		n.Template = file.Template
+	}
+	if err := validateNamingTemplate(file.Folders, true); err != nil {
+		log.Printf("Warning: invalid folders %q in %s: %v", file.Folders, path, err)
+	} else {
+		n.Folders = file.Folders
+	}
+	return n
+}
+
+func writeNamingSettings(path string, n NamingSettings) error {
+	data, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// validateNamingTemplate проверяет плейсхолдеры; "/" допустим только в шаблоне подпапок
+func validateNamingTemplate(template string, folders bool) error {
+	if strings.TrimSpace(template) == "" {
+		if folders {
+			return nil
+		}
+		return fmt.Errorf("template is empty")
+	}
+	if !folders && strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("file name template must not contain path separators, use folders instead")
+	}
+	if folders {
+		if strings.HasPrefix(template, "/") || strings.HasPrefix(template, `\`) || namingVolumePattern.MatchString(template) {
+			return fmt.Errorf("folders must be relative to the selected folder")
+		}
+		for _, segment := range splitNamingFolders(template) {
+			if strings.TrimSpace(segment) == ".." {
+				return fmt.Errorf("folders must not contain \"..\"")
+			}
+		}
+	}
+	for _, match := range namingPlaceholderPattern.FindAllStringSubmatch(template, -1) {
+		known := false
+		for _, name := range namingPlaceholders {
+			known = known || name == match[1]
+		}
+		if !known {
+			return fmt.Errorf("unknown placeholder {%s}, available: {%s}", match[1], strings.Join(namingPlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+// namingValues значения плейсхолдеров для записи. Кластер берется из kubeconfig,
+// если его не удалось прочитать - имя контекста или файла kubeconfig
+func namingValues(meta RecordingMetadata, event, preset string, start time.Time) map[string]string {
+	cluster := meta.Cluster
+	if cluster == "" {
+		cluster = meta.Context
+	}
+	if cluster == "" {
+		cluster = meta.Kubeconfig
+	}
+	if event == "" {
+		event = "cpu" // asprof по умолчанию профилирует CPU
+	}
+	if preset == "" {
+		preset = "custom"
+	}
+	return map[string]string{
+		"cluster":    cluster,
+		"context":    meta.Context,
+		"kubeconfig": meta.Kubeconfig,
+		"namespace":  meta.Namespace,
+		"pod":        meta.Pod,
+		"container":  meta.Container,
+		"event":      event,
+		"preset":     preset,
+		"timestamp":  start.Format("20060102_150405"),
+		"date":       start.Format("2006-01-02"),
+	}
+}
+
+// expandNamingTemplate подставляет значения; каждое значение очищается отдельно, чтобы не создать лишних папок
+func expandNamingTemplate(template string, values map[string]string) string {
+	return namingPlaceholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return sanitizeFileName(values[strings.Trim(placeholder, "{}")])
+	})
+}
+
+// windowsReservedNames имена устройств, которые нельзя использовать как имя файла в Windows
+var windowsReservedNames = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])(\..*)?$`)
+
+// maxFileNameLength запас до ограничения 255 символов с учетом суффиксов и расширений
+const maxFileNameLength = 180
+
+// sanitizeFileName заменяет символы, недопустимые в именах файлов Windows, и обходит зарезервированные имена
+func sanitizeFileName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			b.WriteRune('_')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	result := b.String()
+	if len(result) > maxFileNameLength {
+		result = strings.ToValidUTF8(result[:maxFileNameLength], "")
+	}
+	// Windows отбрасывает точки и пробелы в конце имени
+	result = strings.TrimRight(result, ". ")
+	if windowsReservedNames.MatchString(result) {
+		result = "_" + result
+	}
+	return result
+}
+
+// namingVolumePattern имя диска Windows в начале пути: "C:", "c:\tmp"
+var namingVolumePattern = regexp.MustCompile(`^[A-Za-z]:`)
+
+// splitNamingFolders сегменты шаблона подпапок; разделитель "/" или "\" на любой ОС
+func splitNamingFolders(folders string) []string {
+	return strings.FieldsFunc(folders, func(r rune) bool { return r == '/' || r == '\\' })
+}
+
+// recordingOutputBase папка и имя файла записи без расширения по шаблонам.
+// Каждый сегмент подпапок очищается как имя файла, поэтому результат всегда остается внутри folder:
+// "..", абсолютные пути и диски ("C:\tmp") не выводят за ее пределы
+func (n NamingSettings) recordingOutputBase(folder string, values map[string]string) (dir, base string) {
+	dir = folder
+	for _, segment := range splitNamingFolders(n.Folders) {
+		segment = sanitizeFileName(strings.TrimSpace(expandNamingTemplate(segment, values)))
+		if segment != "" && segment != "." && segment != ".." {
+			dir = filepath.Join(dir, segment)
+		}
+	}
+	base = sanitizeFileName(expandNamingTemplate(n.Template, values))
+	if base == "" {
+		base = sanitizeFileName(expandNamingTemplate(defaultNamingSettings().Template, values))
+	}
+	return dir, base
+}
+
+// uniqueOutputBase добавляет "-2", "-3"... если запись с таким именем уже есть в папке
+func uniqueOutputBase(dir, base string) string {
+	taken := func(candidate string) bool {
+		for _, ext := range []string{".jfr", metadataSuffix} {
+			if _, err := os.Stat(filepath.Join(dir, candidate+ext)); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	candidate := base
+	for i := 2; taken(candidate); i++ {
+		candidate = base + "-" + strconv.Itoa(i)
+	}
+	return candidate
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := map[string]string{
+		"api-7d9f":                     "api-7d9f",
+		`a<b>c:d"e/f\g|h?i*j`:          "a_b_c_d_e_f_g_h_i_j",
+		"tab\there":                    "tab_here",
+		"trailing. . ":                 "trailing",
+		"..":                           "",
+		"CON":                          "_CON",
+		"nul.txt":                      "_nul.txt",
+		"com1":                         "_com1",
+		"console":                      "console",
+		"../../etc/passwd":             ".._.._etc_passwd",
+		strings.Repeat("x", 300):       strings.Repeat("x", maxFileNameLength),
+		strings.Repeat("я", 100):       strings.Repeat("я", maxFileNameLength/2),
+		strings.Repeat("x", 179) + "я": strings.Repeat("x", 179),
+	}
+	for input, want := range tests {
+		if got := sanitizeFileName(input); got != want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestValidateNamingTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		folders  bool
+		wantErr  string
+	}{
+		{template: "{namespace}__{pod}__{timestamp}"},
+		{template: "{cluster}/{namespace}/{date}", folders: true},
+		{template: `{cluster}\{date}`, folders: true},
+		{template: "", folders: true},
+		{template: " ", wantErr: "template is empty"},
+		{template: "{pod}/{timestamp}", wantErr: "must not contain path separators"},
+		{template: "{pod}_{node}", wantErr: "unknown placeholder {node}"},
+		{template: "{cluster}/{unknown}", folders: true, wantErr: "unknown placeholder {unknown}"},
+		{template: "/var/{pod}", folders: true, wantErr: "relative to the selected folder"},
+		{template: `\\server\{pod}`, folders: true, wantErr: "relative to the selected folder"},
+		{template: `C:\tmp`, folders: true, wantErr: "relative to the selected folder"},
+		{template: "d:{pod}", folders: true, wantErr: "relative to the selected folder"},
+		{template: "{cluster}/../{pod}", folders: true, wantErr: `must not contain ".."`},
+		{template: `{cluster}\ ..`, folders: true, wantErr: `must not contain ".."`},
+	}
+	for _, tt := range tests {
+		err := validateNamingTemplate(tt.template, tt.folders)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateNamingTemplate(%q, %v): unexpected error %v", tt.template, tt.folders, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("validateNamingTemplate(%q, %v) = %v, want %q", tt.template, tt.folders, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRecordingOutputBase(t *testing.T) {
+	start := time.Date(2024, 6, 1, 9, 5, 7, 0, time.UTC)
+	values := namingValues(RecordingMetadata{
+		Kubeconfig: "config",
+		Context:    "prod-eu",
+		Namespace:  "shop",
+		Pod:        "api-7d9f",
+	}, "", "", start)
+	folder := filepath.Join("out", "recordings")
+
+	tests := []struct {
+		name     string
+		settings NamingSettings
+		wantDir  string
+		wantBase string
+	}{
+		{
+			name:     "default",
+			settings: defaultNamingSettings(),
+			wantDir:  folder,
+			wantBase: "shop__api-7d9f__20240601_090507",
+		},
+		{
+			// Кластер не прочитан из kubeconfig - используется контекст
+			name:     "folders",
+			settings: NamingSettings{Template: "{pod}-{event}-{preset}", Folders: "{cluster}/{namespace}/{date}"},
+			wantDir:  filepath.Join(folder, "prod-eu", "shop", "2024-06-01"),
+			wantBase: "api-7d9f-cpu-custom",
+		},
+		{
+			name:     "backslash separators and empty segments",
+			settings: NamingSettings{Template: "{pod}", Folders: `{container}\\{namespace}/`},
+			wantDir:  filepath.Join(folder, "shop"),
+			wantBase: "api-7d9f",
+		},
+		{
+			name:     "parent folders stay inside",
+			settings: NamingSettings{Template: "{pod}", Folders: `..\..\x`},
+			wantDir:  filepath.Join(folder, "x"),
+			wantBase: "api-7d9f",
+		},
+		{
+			name:     "volume stays inside",
+			settings: NamingSettings{Template: "{pod}", Folders: `C:\tmp`},
+			wantDir:  filepath.Join(folder, "C_", "tmp"),
+			wantBase: "api-7d9f",
+		},
+		{
+			name:     "absolute path stays inside",
+			settings: NamingSettings{Template: "{pod}", Folders: "/etc"},
+			wantDir:  filepath.Join(folder, "etc"),
+			wantBase: "api-7d9f",
+		},
+		{
+			name:     "empty name falls back to default",
+			settings: NamingSettings{Template: "{container}"},
+			wantDir:  folder,
+			wantBase: "shop__api-7d9f__20240601_090507",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, base := tt.settings.recordingOutputBase(folder, values)
+			if dir != tt.wantDir || base != tt.wantBase {
+				t.Errorf("recordingOutputBase = %q, %q, want %q, %q", dir, base, tt.wantDir, tt.wantBase)
+			}
+		})
+	}
+}
+
+// Значения плейсхолдеров не создают лишних папок; точки в конце имени отбрасываются, как в Windows
+func TestRecordingOutputBaseSanitizesValues(t *testing.T) {
+	values := map[string]string{"namespace": "../..", "pod": `a/b\c:d`, "cluster": "CON"}
+	settings := NamingSettings{Template: "{pod}", Folders: "{cluster}/{namespace}"}
+	dir, base := settings.recordingOutputBase("out", values)
+	if want := filepath.Join("out", "_CON", ".._"); dir != want {
+		t.Errorf("dir = %q, want %q", dir, want)
+	}
+	if base != "a_b_c_d" {
+		t.Errorf("base = %q, want %q", base, "a_b_c_d")
+	}
+}
+
+func TestUniqueOutputBase(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(name string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := uniqueOutputBase(dir, "rec"); got != "rec" {
+		t.Errorf("empty folder: %q, want rec", got)
+	}
+	// Другие файлы с тем же именем не мешают
+	touch("rec.html")
+	if got := uniqueOutputBase(dir, "rec"); got != "rec" {
+		t.Errorf("only rec.html exists: %q, want rec", got)
+	}
+	touch("rec.jfr")
+	if got := uniqueOutputBase(dir, "rec"); got != "rec-2" {
+		t.Errorf("rec.jfr exists: %q, want rec-2", got)
+	}
+	// JFR мог быть сжат или удален, но sidecar с метаданными остался
+	touch("rec-2" + metadataSuffix)
+	if got := uniqueOutputBase(dir, "rec"); got != "rec-3" {
+		t.Errorf("rec-2 metadata exists: %q, want rec-3", got)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// AsprofOptionsForm структурированный редактор аргументов asprof.
+// Форма и текстовое поле "Arguments" синхронизируются в обе стороны.
+type AsprofOptionsForm struct {
+	expanded     bool
+	toggleButton widget.Clickable
+	list         widget.List
+
+	eventEditor    widget.Editor
+	durationEditor widget.Editor
+	intervalEditor widget.Editor
+	includeEditor  widget.Editor
+	excludeEditor  widget.Editor
+	cstackEditor   widget.Editor
+	allocEditor    widget.Editor
+	lockEditor     widget.Editor
+	threads        widget.Bool
+
+	syncedArgs string   // строка аргументов, с которой форма синхронизирована
+	formArgs   string   // строка, собранная из формы при последней синхронизации
+	extra      []string // токены, которые форма не отображает
+	parseError string
+}
+
+func NewAsprofOptionsForm() *AsprofOptionsForm {
+	f := &AsprofOptionsForm{
+		list: widget.List{
+			List: layout.List{
+				Axis: layout.Vertical,
+			},
+		},
+	}
+	for _, editor := range f.editors() {
+		editor.SingleLine = true
+	}
+	return f
+}
+
+func (f *AsprofOptionsForm) editors() []*widget.Editor {
+	return []*widget.Editor{
+		&f.eventEditor, &f.durationEditor, &f.intervalEditor, &f.includeEditor,
+		&f.excludeEditor, &f.cstackEditor, &f.allocEditor, &f.lockEditor,
+	}
+}
+
+func (f *AsprofOptionsForm) IsExpanded() bool {
+	return f.expanded
+}
+
+// syncFromArgs заполняет форму из строки аргументов
+func (f *AsprofOptionsForm) syncFromArgs(args string) {
+	f.syncedArgs = args
+	opts, err := ParseAsprofArgs(args)
+	if err != nil {
+		f.parseError = err.Error()
+		return
+	}
+	f.parseError = ""
+
+	f.eventEditor.SetText(opts.Event)
+	f.durationEditor.SetText(opts.Duration)
+	f.intervalEditor.SetText(opts.Interval)
+	f.includeEditor.SetText(strings.Join(opts.Include, ", "))
+	f.excludeEditor.SetText(strings.Join(opts.Exclude, ", "))
+	f.cstackEditor.SetText(opts.Cstack)
+	f.allocEditor.SetText(opts.Alloc)
+	f.lockEditor.SetText(opts.Lock)
+	f.threads.Value = opts.Threads
+	f.extra = opts.Extra
+	f.formArgs = f.options().String()
+}
+
+// options собирает структуру из полей формы
+func (f *AsprofOptionsForm) options() AsprofOptions {
+	splitPatterns := func(text string) []string {
+		var patterns []string
+		for _, p := range strings.Split(text, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				patterns = append(patterns, p)
+			}
+		}
+		return patterns
+	}
+
+	return AsprofOptions{
+		Event:    strings.TrimSpace(f.eventEditor.Text()),
+		Duration: strings.TrimSpace(f.durationEditor.Text()),
+		Interval: strings.TrimSpace(f.intervalEditor.Text()),
+		Include:  splitPatterns(f.includeEditor.Text()),
+		Exclude:  splitPatterns(f.excludeEditor.Text()),
+		Threads:  f.threads.Value,
+		Cstack:   strings.TrimSpace(f.cstackEditor.Text()),
+		Alloc:    strings.TrimSpace(f.allocEditor.Text()),
+		Lock:     strings.TrimSpace(f.lockEditor.Text()),
+		Extra:    f.extra,
+	}
+}
+
+// sync переносит изменения между текстовым полем и формой
+func (f *AsprofOptionsForm) sync(a *Application) {
+	if a.asprofArgs != f.syncedArgs {
+		f.syncFromArgs(a.asprofArgs)
+		return
+	}
+	if f.parseError != "" {
+		// Пока строку нельзя разобрать, форма не перезаписывает ее
+		return
+	}
+
+	formArgs := f.options().String()
+	if formArgs != f.formArgs {
+		f.formArgs = formArgs
+		f.syncedArgs = formArgs
+		a.asprofArgs = formArgs
+		a.asprofArgsEditor.SetText(formArgs)
+		a.saveAsprofArgs()
+		a.rememberSetting(asprofArgsSettingName, formArgs)
+	}
+}
+
+// LayoutToggle отрисовывает кнопку открытия формы рядом с полем аргументов
+func (f *AsprofOptionsForm) LayoutToggle(gtx layout.Context, th *material.Theme, app *Application) layout.Dimensions {
+	for f.toggleButton.Clicked(gtx) {
+		if f.expanded {
+			f.expanded = false
+		} else {
+			app.closeAllSelectors()
+			f.syncFromArgs(app.asprofArgs)
+			f.expanded = true
+		}
+	}
+
+	if f.toggleButton.Hovered() {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+
+	btn := material.Button(th, &f.toggleButton, "Options")
+	if f.expanded {
+		btn.Background = color.NRGBA{R: 220, G: 220, B: 220, A: 255}
+	} else {
+		btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+	}
+	btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+	return btn.Layout(gtx)
+}
+
+// LayoutForm отрисовывает поля формы
+func (f *AsprofOptionsForm) LayoutForm(gtx layout.Context, th *material.Theme, app *Application) layout.Dimensions {
+	if !f.expanded {
+		return layout.Dimensions{}
+	}
+	f.sync(app)
+
+	type formRow struct {
+		label  string
+		editor *widget.Editor
+		hint   string
+	}
+	rows := []formRow{
+		{"Event", &f.eventEditor, "cpu, alloc, lock, wall, itimer or Class.method"},
+		{"Duration", &f.durationEditor, "seconds, e.g. 30"},
+		{"Interval", &f.intervalEditor, "e.g. 10ms, 1000000 (ns)"},
+		{"Include", &f.includeEditor, "stack patterns, comma separated"},
+		{"Exclude", &f.excludeEditor, "stack patterns, comma separated"},
+		{"C stack", &f.cstackEditor, "fp, dwarf, lbr, vm, vmx, no"},
+		{"Alloc", &f.allocEditor, "allocation threshold, e.g. 512k"},
+		{"Lock", &f.lockEditor, "lock threshold, e.g. 10ms"},
+	}
+
+	return layout.Background{}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			// Gray border
+			defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+			paint.Fill(gtx.Ops, color.NRGBA{R: 180, G: 180, B: 180, A: 255})
+			return layout.Dimensions{Size: gtx.Constraints.Max}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: unit.Dp(1), Bottom: unit.Dp(1), Left: unit.Dp(1), Right: unit.Dp(1)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				// White background inside
+				defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+				paint.Fill(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+				// Строки полей + чекбокс потоков
+				return material.List(th, &f.list).Layout(gtx, len(rows)+1, func(gtx layout.Context, index int) layout.Dimensions {
+					return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						if index == len(rows) {
+							checkbox := material.CheckBox(th, &f.threads, "Profile threads separately (-t)")
+							checkbox.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
+							return checkbox.Layout(gtx)
+						}
+
+						row := rows[index]
+						return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								gtx.Constraints.Min.X = gtx.Dp(unit.Dp(100))
+								gtx.Constraints.Max.X = gtx.Dp(unit.Dp(100))
+								return material.Label(th, unit.Sp(14), row.label+":").Layout(gtx)
+							}),
+							layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+								return layout.Background{}.Layout(gtx,
+									func(gtx layout.Context) layout.Dimensions {
+										defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+										paint.Fill(gtx.Ops, color.NRGBA{R: 248, G: 248, B: 248, A: 255})
+										return layout.Dimensions{Size: gtx.Constraints.Min}
+									},
+									func(gtx layout.Context) layout.Dimensions {
+										return layout.UniformInset(unit.Dp(6)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+											editor := material.Editor(th, row.editor, row.hint)
+											editor.TextSize = unit.Sp(14)
+											editor.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
+											editor.HintColor = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+											return editor.Layout(gtx)
+										})
+									},
+								)
+							}),
+						)
+					})
+				})
+			})
+		},
+	)
+}
+
+// validateAsprofArgs проверяет текущие аргументы для установленной версии async-profiler
+func (a *Application) validateAsprofArgs() (errs []string, warnings []string) {
+	opts, err := ParseAsprofArgs(a.asprofArgs)
+	if err != nil {
+		return []string{err.Error()}, nil
+	}
+	return opts.Validate(a.version)
+}
+
+// drawArgsValidation отрисовывает первую ошибку или предупреждение под полем аргументов
+func (a *Application) drawArgsValidation(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	errs, warnings := a.validateAsprofArgs()
+	var message string
+	var textColor color.NRGBA
+	switch {
+	case len(errs) > 0:
+		message = "Error: " + strings.Join(errs, "; ")
+		textColor = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+	case len(warnings) > 0:
+		message = "Warning: " + strings.Join(warnings, "; ")
+		textColor = color.NRGBA{R: 200, G: 120, B: 0, A: 255}
+	default:
+		return layout.Dimensions{}
+	}
+
+	return layout.Inset{Top: unit.Dp(6), Left: unit.Dp(130)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		label := material.Label(th, unit.Sp(12), message)
+		label.Color = textColor
+		label.MaxLines = 2
+		return label.Layout(gtx)
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// pprofSampleTypes типы значений сэмплов pprof по виду профиля. Первое значение - число событий,
+// второе (если есть) - их вес; названия как у профилей Go, чтобы go tool pprof показывал привычные единицы
+var pprofSampleTypes = map[string][][2]string{
+	"cpu":   {{"samples", "count"}},
+	"wall":  {{"samples", "count"}},
+	"alloc": {{"alloc_objects", "count"}, {"alloc_space", "bytes"}},
+	"lock":  {{"contentions", "count"}, {"delay", "nanoseconds"}},
+}
+
+// Номера полей profile.proto (github.com/google/pprof/proto/profile.proto)
+const (
+	pprofProfileSampleType        = 1
+	pprofProfileSample            = 2
+	pprofProfileLocation          = 4
+	pprofProfileFunction          = 5
+	pprofProfileStringTable       = 6
+	pprofProfileTimeNanos         = 9
+	pprofProfileDurationNanos     = 10
+	pprofProfilePeriodType        = 11
+	pprofProfilePeriod            = 12
+	pprofProfileDefaultSampleType = 14
+
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+
+	pprofSampleLocationID = 1
+	pprofSampleValue      = 2
+
+	pprofLocationID   = 1
+	pprofLocationLine = 4
+
+	pprofLineFunctionID = 1
+	pprofLineLine       = 2
+
+	pprofFunctionID         = 1
+	pprofFunctionName       = 2
+	pprofFunctionSystemName = 3
+)
+
+// protoBuffer минимальный кодировщик protobuf для profile.proto
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.data = append(b.data, byte(v)|0x80)
+		v >>= 7
+	}
+	b.data = append(b.data, byte(v))
+}
+
+func (b *protoBuffer) tag(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// int64Field поле varint; нулевые значения, как и в proto3, не пишутся
+func (b *protoBuffer) int64Field(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, 0)
+	b.varint(uint64(v))
+}
+
+func (b *protoBuffer) bytesField(field int, v []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(v)))
+	b.data = append(b.data, v...)
+}
+
+func (b *protoBuffer) stringField(field int, v string) {
+	b.tag(field, 2)
+	b.varint(uint64(len(v)))
+	b.data = append(b.data, v...)
+}
+
+// packedField упакованное repeated-поле varint
+func (b *protoBuffer) packedField(field int, values []int64) {
+	var packed protoBuffer
+	for _, v := range values {
+		packed.varint(uint64(v))
+	}
+	b.bytesField(field, packed.data)
+}
+
+// message вложенное сообщение
+func (b *protoBuffer) message(field int, build func(m *protoBuffer)) {
+	var m protoBuffer
+	build(&m)
+	b.bytesField(field, m.data)
+}
+
+// pprofBuilder таблицы строк, функций и локаций профиля
+type pprofBuilder struct {
+	strings   []string
+	stringIDs map[string]int64
+	functions map[string]int64
+	locations map[Frame]int64
+	body      protoBuffer // функции и локации в порядке появления
+}
+
+func newPprofBuilder() *pprofBuilder {
+	return &pprofBuilder{
+		strings:   []string{""}, // нулевая строка таблицы всегда пустая
+		stringIDs: map[string]int64{"": 0},
+		functions: map[string]int64{},
+		locations: map[Frame]int64{},
+	}
+}
+
+func (b *pprofBuilder) str(s string) int64 {
+	if id, ok := b.stringIDs[s]; ok {
+		return id
+	}
+	id := int64(len(b.strings))
+	b.strings = append(b.strings, s)
+	b.stringIDs[s] = id
+	return id
+}
+
+func (b *pprofBuilder) function(name string) int64 {
+	if id, ok := b.functions[name]; ok {
+		return id
+	}
+	id := int64(len(b.functions) + 1)
+	b.functions[name] = id
+	nameID := b.str(name)
+	b.body.message(pprofProfileFunction, func(m *protoBuffer) {
+		m.int64Field(pprofFunctionID, id)
+		m.int64Field(pprofFunctionName, nameID)
+		m.int64Field(pprofFunctionSystemName, nameID)
+	})
+	return id
+}
+
+// location локация на фрейм с учетом строки; тип фрейма в pprof не передается
+func (b *pprofBuilder) location(f Frame) int64 {
+	f.Type = 0
+	if id, ok := b.locations[f]; ok {
+		return id
+	}
+	id := int64(len(b.locations) + 1)
+	b.locations[f] = id
+	functionID := b.function(f.Name)
+	b.body.message(pprofProfileLocation, func(m *protoBuffer) {
+		m.int64Field(pprofLocationID, id)
+		m.message(pprofLocationLine, func(line *protoBuffer) {
+			line.int64Field(pprofLineFunctionID, functionID)
+			line.int64Field(pprofLineLine, int64(f.Line))
+		})
+	})
+	return id
+}
+
+// WritePprof пишет профиль в формате profile.proto со сжатием gzip, как его читает go tool pprof
+func (p *Profile) WritePprof(w io.Writer) error {
+	sampleTypes, ok := pprofSampleTypes[p.Event]
+	if !ok {
+		sampleTypes = pprofSampleTypes["cpu"]
+	}
+
+	b := newPprofBuilder()
+	var out protoBuffer
+	for _, st := range sampleTypes {
+		typeID, unitID := b.str(st[0]), b.str(st[1])
+		out.message(pprofProfileSampleType, func(m *protoBuffer) {
+			m.int64Field(pprofValueTypeType, typeID)
+			m.int64Field(pprofValueTypeUnit, unitID)
+		})
+	}
+
+	// Стеки сортируются, чтобы одинаковый профиль давал одинаковый файл
+	stacks := append([]ProfileStack(nil), p.Stacks...)
+	sort.SliceStable(stacks, func(i, j int) bool {
+		return stackKey(stacks[i].Frames) < stackKey(stacks[j].Frames)
+	})
+	for _, s := range stacks {
+		// В pprof первым идет лист
+		locationIDs := make([]int64, 0, len(s.Frames))
+		for i := len(s.Frames) - 1; i >= 0; i-- {
+			locationIDs = append(locationIDs, b.location(s.Frames[i]))
+		}
+		values := []int64{s.Samples}
+		if len(sampleTypes) > 1 {
+			values = append(values, s.Value)
+		}
+		out.message(pprofProfileSample, func(m *protoBuffer) {
+			m.packedField(pprofSampleLocationID, locationIDs)
+			m.packedField(pprofSampleValue, values)
+		})
+	}
+	out.data = append(out.data, b.body.data...)
+
+	last := sampleTypes[len(sampleTypes)-1]
+	periodTypeID, periodUnitID := b.str(last[0]), b.str(last[1])
+	defaultTypeID := b.str(last[0])
+
+	for _, s := range b.strings {
+		out.stringField(pprofProfileStringTable, s)
+	}
+	out.int64Field(pprofProfileTimeNanos, p.StartNanos)
+	if p.EndNanos > p.StartNanos {
+		out.int64Field(pprofProfileDurationNanos, p.EndNanos-p.StartNanos)
+	}
+	out.message(pprofProfilePeriodType, func(m *protoBuffer) {
+		m.int64Field(pprofValueTypeType, periodTypeID)
+		m.int64Field(pprofValueTypeUnit, periodUnitID)
+	})
+	out.int64Field(pprofProfilePeriod, 1)
+	out.int64Field(pprofProfileDefaultSampleType, defaultTypeID)
+
+	// Заголовок gzip без имени и времени модификации, поэтому вывод воспроизводим
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(out.data); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
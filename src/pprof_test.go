@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// protoField поле protobuf: значение varint или содержимое length-delimited
+type protoField struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeProto разбирает сообщение protobuf на поля; поддерживаются только wire type 0 и 2, как в profile.proto
+func decodeProto(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := decodeVarint(data)
+		if n == 0 {
+			return nil, fmt.Errorf("truncated key")
+		}
+		data = data[n:]
+		f := protoField{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			if f.varint, n = decodeVarint(data); n == 0 {
+				return nil, fmt.Errorf("field %d: truncated varint", f.number)
+			}
+			data = data[n:]
+		case 2:
+			size, n := decodeVarint(data)
+			if n == 0 || uint64(len(data)-n) < size {
+				return nil, fmt.Errorf("field %d: truncated bytes", f.number)
+			}
+			f.bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return nil, fmt.Errorf("field %d: unexpected wire type %d", f.number, key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func decodeVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i, b := range data {
+		if i == 10 {
+			return 0, 0
+		}
+		v |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// decodePacked значения repeated-поля: упакованного или записанного по одному
+func decodePacked(f protoField) ([]int64, error) {
+	if f.bytes == nil {
+		return []int64{int64(f.varint)}, nil
+	}
+	var values []int64
+	for data := f.bytes; len(data) > 0; {
+		v, n := decodeVarint(data)
+		if n == 0 {
+			return nil, fmt.Errorf("field %d: truncated packed varint", f.number)
+		}
+		values = append(values, int64(v))
+		data = data[n:]
+	}
+	return values, nil
+}
+
+// decodedPprof профиль, восстановленный из profile.proto
+type decodedPprof struct {
+	sampleTypes []string // "type/unit"
+	samples     []string // "корень;...;лист:строка значение1 значение2", отсортированы
+	timeNanos   int64
+	duration    int64
+	periodType  string
+	period      int64
+	defaultType string
+}
+
+func decodePprof(t *testing.T, compressed []byte) decodedPprof {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	fields, err := decodeProto(data)
+	if err != nil {
+		t.Fatalf("profile: %v", err)
+	}
+	sub := func(f protoField) map[int][]protoField {
+		nested, err := decodeProto(f.bytes)
+		if err != nil {
+			t.Fatalf("field %d: %v", f.number, err)
+		}
+		byNumber := map[int][]protoField{}
+		for _, nf := range nested {
+			byNumber[nf.number] = append(byNumber[nf.number], nf)
+		}
+		return byNumber
+	}
+	first := func(m map[int][]protoField, number int) uint64 {
+		if len(m[number]) == 0 {
+			return 0
+		}
+		return m[number][0].varint
+	}
+
+	var stringTable []string
+	functions := map[uint64]uint64{}    // id -> name
+	locations := map[uint64][2]uint64{} // id -> function, line
+	var sampleTypes []map[int][]protoField
+	var periodType map[int][]protoField
+	var samples []map[int][]protoField
+	var result decodedPprof
+	var defaultType uint64
+	for _, f := range fields {
+		switch f.number {
+		case pprofProfileSampleType:
+			sampleTypes = append(sampleTypes, sub(f))
+		case pprofProfileSample:
+			samples = append(samples, sub(f))
+		case pprofProfileLocation:
+			m := sub(f)
+			if len(m[pprofLocationLine]) != 1 {
+				t.Fatalf("location %d: %d lines, want 1", first(m, pprofLocationID), len(m[pprofLocationLine]))
+			}
+			line := sub(m[pprofLocationLine][0])
+			locations[first(m, pprofLocationID)] = [2]uint64{first(line, pprofLineFunctionID), first(line, pprofLineLine)}
+		case pprofProfileFunction:
+			m := sub(f)
+			functions[first(m, pprofFunctionID)] = first(m, pprofFunctionName)
+		case pprofProfileStringTable:
+			stringTable = append(stringTable, string(f.bytes))
+		case pprofProfileTimeNanos:
+			result.timeNanos = int64(f.varint)
+		case pprofProfileDurationNanos:
+			result.duration = int64(f.varint)
+		case pprofProfilePeriodType:
+			periodType = sub(f)
+		case pprofProfilePeriod:
+			result.period = int64(f.varint)
+		case pprofProfileDefaultSampleType:
+			defaultType = f.varint
+		}
+	}
+
+	str := func(id uint64) string {
+		if id >= uint64(len(stringTable)) {
+			t.Fatalf("string index %d out of range %d", id, len(stringTable))
+		}
+		return stringTable[id]
+	}
+	if len(stringTable) == 0 || stringTable[0] != "" {
+		t.Fatalf("string table must start with an empty string: %q", stringTable)
+	}
+	valueType := func(m map[int][]protoField) string {
+		return str(first(m, pprofValueTypeType)) + "/" + str(first(m, pprofValueTypeUnit))
+	}
+	for _, st := range sampleTypes {
+		result.sampleTypes = append(result.sampleTypes, valueType(st))
+	}
+	if periodType != nil {
+		result.periodType = valueType(periodType)
+	}
+	result.defaultType = str(defaultType)
+
+	for _, s := range samples {
+		var ids, values []int64
+		for _, f := range s[pprofSampleLocationID] {
+			v, err := decodePacked(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, v...)
+		}
+		for _, f := range s[pprofSampleValue] {
+			v, err := decodePacked(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, v...)
+		}
+		if len(values) != len(result.sampleTypes) {
+			t.Fatalf("sample has %d values for %d sample types", len(values), len(result.sampleTypes))
+		}
+		// В pprof первым идет лист
+		frames := make([]string, len(ids))
+		for i, id := range ids {
+			location, ok := locations[uint64(id)]
+			if !ok {
+				t.Fatalf("sample references unknown location %d", id)
+			}
+			name, ok := functions[location[0]]
+			if !ok {
+				t.Fatalf("location %d references unknown function %d", id, location[0])
+			}
+			frame := str(name)
+			if location[1] != 0 {
+				frame += ":" + strconv.FormatUint(location[1], 10)
+			}
+			frames[len(ids)-1-i] = frame
+		}
+		line := strings.Join(frames, ";")
+		for _, v := range values {
+			line += " " + strconv.FormatInt(v, 10)
+		}
+		result.samples = append(result.samples, line)
+	}
+	sort.Strings(result.samples)
+	return result
+}
+
+func TestWritePprofRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts JFRReadOptions
+		want decodedPprof
+	}{
+		{
+			name: "cpu",
+			opts: JFRReadOptions{Event: "cpu", Dotted: true},
+			want: decodedPprof{
+				sampleTypes: []string{"samples/count"},
+				samples: []string{
+					"java.lang.Thread.run:20;com.acme.Work.compute:10 6",
+					"java.lang.Thread.run:20;com.acme.Work.compute:10;malloc 2",
+				},
+				timeNanos:   1_700_000_000_000_000_000,
+				duration:    1000,
+				periodType:  "samples/count",
+				period:      1,
+				defaultType: "samples",
+			},
+		},
+		{
+			name: "alloc with weight",
+			opts: JFRReadOptions{Event: "alloc", Dotted: true, Threads: true},
+			want: decodedPprof{
+				sampleTypes: []string{"alloc_objects/count", "alloc_space/bytes"},
+				samples: []string{
+					"[main tid=100];java.lang.Thread.run:20;com.acme.Work.compute:10;com.acme.Work 2 8192",
+				},
+				timeNanos:   1_700_000_000_000_001_000,
+				periodType:  "alloc_space/bytes",
+				period:      1,
+				defaultType: "alloc_space",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ReadJFRProfile(context.Background(), sampleJFR, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var first, second bytes.Buffer
+			if err := profile.WritePprof(&first); err != nil {
+				t.Fatal(err)
+			}
+			if err := profile.WritePprof(&second); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first.Bytes(), second.Bytes()) {
+				t.Error("two writes of the same profile differ")
+			}
+			if got := decodePprof(t, first.Bytes()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decoded pprof =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// preflightStatus результат одной проверки прав
+type preflightStatus int
+
+const (
+	preflightPending preflightStatus = iota
+	preflightAllowed
+	preflightDenied
+	preflightFailed // проверку не удалось выполнить
+)
+
+// preflightCheck проверка права через "kubectl auth can-i" (SelfSubjectAccessReview)
+type preflightCheck struct {
+	label       string
+	verb        string
+	resource    string
+	subresource string
+	required    bool // без этого права запись невозможна
+	status      preflightStatus
+	detail      string
+}
+
+// preflightTarget под, для которого выполнены проверки
+type preflightTarget struct {
+	kubeconfig string
+	namespace  string
+	pod        string
+}
+
+func (t preflightTarget) complete() bool {
+	return t.kubeconfig != "" && t.namespace != "" && t.pod != ""
+}
+
+// Preflight состояние проверки прав для выбранного пода
+type Preflight struct {
+	target     preflightTarget
+	checks     []preflightCheck
+	running    bool
+	generation uint64             // ответы устаревших проверок отбрасываются
+	cancel     context.CancelFunc // отмена выполняющейся проверки
+	recheck    widget.Clickable
+}
+
+// preflightChecks права, нужные для записи: чтение пода и exec (через него же работает kubectl cp)
+func preflightChecks(pod string) []preflightCheck {
+	return []preflightCheck{
+		{label: "get pod", verb: "get", resource: "pods/" + pod, required: true},
+		{label: "list pods", verb: "list", resource: "pods", required: true},
+		{label: "exec into pod (create pods/exec)", verb: "create", resource: "pods/" + pod, subresource: "exec", required: true},
+	}
+}
+
+// currentPreflightTarget под, выбранный сейчас в селекторах
+func (a *Application) currentPreflightTarget() preflightTarget {
+	return preflightTarget{
+		kubeconfig: a.kubeconfigSelector.GetSelectedConfig(),
+		namespace:  a.namespaceSelector.GetSelectedNamespace(),
+		pod:        a.podSelector.GetSelectedPod(),
+	}
+}
+
+// ensurePreflight запускает проверку, если выбранный под изменился. Вызывается из UI-горутины
+func (a *Application) ensurePreflight() {
+	target := a.currentPreflightTarget()
+	if target == a.preflight.target {
+		return
+	}
+	a.startPreflight(target)
+}
+
+// startPreflight отменяет прежнюю проверку и запускает новую для target
+func (a *Application) startPreflight(target preflightTarget) {
+	p := &a.preflight
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.generation++
+	p.target = target
+	p.running = false
+	p.checks = nil
+	if !target.complete() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.running = true
+	p.checks = preflightChecks(target.pod)
+	generation := p.generation
+	checks := append([]preflightCheck(nil), p.checks...)
+	timeout := a.timeouts.List
+
+	go func() {
+		defer cancel()
+		runPreflightChecks(ctx, target, checks, timeout)
+		if ctx.Err() == context.Canceled {
+			return
+		}
+		a.post(preflightCompletedEvent{generation: generation, checks: checks})
+	}()
+}
+
+// runPreflightChecks выполняет проверки параллельно и записывает результат в checks
+func runPreflightChecks(parent context.Context, target preflightTarget, checks []preflightCheck, timeout time.Duration) {
+	kubeconfigPath := filepath.Join(getKubeDir(), target.kubeconfig)
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(check *preflightCheck) {
+			defer wg.Done()
+			check.status, check.detail = canI(parent, kubeconfigPath, target.namespace, check, timeout)
+		}(&checks[i])
+	}
+	wg.Wait()
+}
+
+// canI выполняет "kubectl auth can-i" для одной проверки
+func canI(parent context.Context, kubeconfigPath, namespace string, check *preflightCheck, timeout time.Duration) (preflightStatus, string) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	args := []string{"auth", "can-i", check.verb, check.resource, "-n", namespace}
+	if check.subresource != "" {
+		args = append(args, "--subresource="+check.subresource)
+	}
+	output, err := runKubectlOutput(ctx, "checking permissions", kubeconfigPath, args...)
+	return canIResult(output, err)
+}
+
+// canIResult разбирает ответ can-i: kubectl печатает "yes"/"no" и на "no" завершается с кодом 1,
+// а сетевые ошибки и ошибки авторизации распознаются классификатором
+func canIResult(output []byte, err error) (preflightStatus, string) {
+	var clusterErr *ClusterError
+	if err != nil && errors.As(err, &clusterErr) {
+		var exitErr interface{ ExitCode() int }
+		if errors.As(clusterErr.Err, &exitErr) && exitErr.ExitCode() == 1 && clusterErr.Kind == clusterErrorUnknown {
+			return preflightDenied, "not allowed"
+		}
+		return preflightFailed, clusterErr.Title()
+	}
+	if err != nil {
+		return preflightFailed, err.Error()
+	}
+	if strings.TrimSpace(string(output)) == "yes" {
+		return preflightAllowed, ""
+	}
+	return preflightDenied, "not allowed"
+}
+
+// preflightCompletedEvent проверки прав завершены
+type preflightCompletedEvent struct {
+	generation uint64
+	checks     []preflightCheck
+}
+
+func (e preflightCompletedEvent) apply(a *Application) {
+	p := &a.preflight
+	if e.generation != p.generation {
+		return
+	}
+	p.running = false
+	p.cancel = nil
+	p.checks = e.checks
+}
+
+// preflightPassed разрешена ли запись: проверки выполнены для выбранного пода и обязательные права есть
+func (a *Application) preflightPassed() bool {
+	p := &a.preflight
+	if p.running || p.target != a.currentPreflightTarget() || !p.target.complete() {
+		return false
+	}
+	return len(missingPermissions(p.checks)) == 0
+}
+
+// missingPermissions обязательные проверки, которые не прошли; без проверок запись тоже не разрешена
+func missingPermissions(checks []preflightCheck) []string {
+	if len(checks) == 0 {
+		return []string{"permissions were not checked"}
+	}
+	var missing []string
+	for _, check := range checks {
+		if check.required && check.status != preflightAllowed {
+			missing = append(missing, check.label)
+		}
+	}
+	return missing
+}
+
+// drawPreflightChecklist отрисовывает список проверок прав для выбранного пода
+func (a *Application) drawPreflightChecklist(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	p := &a.preflight
+	if len(p.checks) == 0 {
+		return layout.Dimensions{}
+	}
+
+	for p.recheck.Clicked(gtx) {
+		if !p.running {
+			a.startPreflight(p.target)
+		}
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					label := material.Label(th, unit.Sp(14), "Permissions:")
+					label.Color = color.NRGBA{R: 60, G: 60, B: 60, A: 255}
+					return label.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(12)}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if p.running {
+						return layout.Dimensions{}
+					}
+					if p.recheck.Hovered() {
+						pointer.CursorPointer.Add(gtx.Ops)
+					}
+					return material.Clickable(gtx, &p.recheck, func(gtx layout.Context) layout.Dimensions {
+						label := material.Label(th, unit.Sp(12), "Re-check")
+						label.Color = color.NRGBA{R: 0, G: 0, B: 255, A: 255}
+						return label.Layout(gtx)
+					})
+				}),
+			)
+		}),
+	}
+
+	for _, check := range p.checks {
+		check := check
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			var mark string
+			var markColor color.NRGBA
+			switch check.status {
+			case preflightAllowed:
+				mark, markColor = "✓", color.NRGBA{R: 50, G: 150, B: 50, A: 255}
+			case preflightDenied, preflightFailed:
+				if check.required {
+					mark, markColor = "✗", color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+				} else {
+					mark, markColor = "–", color.NRGBA{R: 200, G: 120, B: 0, A: 255}
+				}
+			default:
+				mark, markColor = "…", color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+			}
+
+			text := mark + " " + check.label
+			if check.detail != "" {
+				text += ": " + check.detail
+			}
+			return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				label := material.Label(th, unit.Sp(12), text)
+				label.Color = markColor
+				label.MaxLines = 1
+				return label.Layout(gtx)
+			})
+		}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
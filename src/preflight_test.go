@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// exitCodeError ошибка завершения kubectl с заданным кодом, как *exec.ExitError
+type exitCodeError int
+
+func (e exitCodeError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitCodeError) ExitCode() int { return int(e) }
+
+func TestCanIResult(t *testing.T) {
+	kubectlFailed := func(code int, stderr string) error {
+		return classifyKubectlError(context.Background(), "checking permissions", exitCodeError(code), stderr)
+	}
+
+	tests := []struct {
+		name       string
+		output     string
+		err        error
+		wantStatus preflightStatus
+		wantDetail string
+	}{
+		{name: "allowed", output: "yes\n", wantStatus: preflightAllowed},
+		{name: "allowed with spaces", output: "  yes \r\n", wantStatus: preflightAllowed},
+		// runKubectlOutput не возвращает stdout вместе с ошибкой: "no" распознается только по коду выхода
+		{name: "denied with exit code", err: kubectlFailed(1, ""), wantStatus: preflightDenied, wantDetail: "not allowed"},
+		{name: "denied without exit code", output: "no\n", wantStatus: preflightDenied, wantDetail: "not allowed"},
+		{name: "unexpected answer", output: "maybe\n", wantStatus: preflightDenied, wantDetail: "not allowed"},
+		{
+			name:       "expired token",
+			err:        kubectlFailed(1, "error: You must be logged in to the server (Unauthorized)"),
+			wantStatus: preflightFailed,
+			wantDetail: (&ClusterError{Kind: clusterErrorAuthExpired}).Title(),
+		},
+		{
+			name:       "cluster unreachable",
+			err:        kubectlFailed(1, "Unable to connect to the server: dial tcp 10.0.0.1:6443: connect: connection refused"),
+			wantStatus: preflightFailed,
+			wantDetail: (&ClusterError{Kind: clusterErrorUnreachable}).Title(),
+		},
+		{
+			name:       "other exit code",
+			err:        kubectlFailed(2, "error: something went wrong"),
+			wantStatus: preflightFailed,
+			wantDetail: (&ClusterError{Kind: clusterErrorUnknown}).Title(),
+		},
+		{name: "not a kubectl error", err: errors.New("boom"), wantStatus: preflightFailed, wantDetail: "boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, detail := canIResult([]byte(tt.output), tt.err)
+			if status != tt.wantStatus || detail != tt.wantDetail {
+				t.Errorf("canIResult = %v, %q; want %v, %q", status, detail, tt.wantStatus, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestMissingPermissions(t *testing.T) {
+	checks := func(statuses ...preflightStatus) []preflightCheck {
+		result := preflightChecks("api-1")
+		for i, status := range statuses {
+			result[i].status = status
+		}
+		return result
+	}
+	tests := []struct {
+		name   string
+		checks []preflightCheck
+		want   []string
+	}{
+		{"not checked", nil, []string{"permissions were not checked"}},
+		{"all allowed", checks(preflightAllowed, preflightAllowed, preflightAllowed), nil},
+		{"exec denied", checks(preflightAllowed, preflightAllowed, preflightDenied), []string{"exec into pod (create pods/exec)"}},
+		{"still running", checks(preflightAllowed, preflightPending, preflightFailed), []string{"list pods", "exec into pod (create pods/exec)"}},
+	}
+	for _, tt := range tests {
+		if got := missingPermissions(tt.checks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: missingPermissions = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
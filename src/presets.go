@@ -0,0 +1,515 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// AsprofPreset именованный набор аргументов async-profiler
+type AsprofPreset struct {
+	Name string `json:"name"`
+	Args string `json:"args"`
+}
+
+// presetsFile формат файла пресетов (и локального, и для обмена с командой)
+type presetsFile struct {
+	Version int            `json:"version"`
+	Presets []AsprofPreset `json:"presets"`
+}
+
+// Встроенные пресеты, доступные всегда
+var builtinPresets = []AsprofPreset{
+	{Name: "CPU 30s", Args: "-e cpu -d 30"},
+	{Name: "Allocations 60s", Args: "-e alloc -d 60"},
+	{Name: "Wall-clock threads", Args: "-e wall -t -d 30"},
+	{Name: "Lock contention", Args: "-e lock -d 30"},
+}
+
+func getPresetsFilePath() string {
+	return filepath.Join(getConfigDir(), "presets.json")
+}
+
+// PresetSelector управляет выбором, сохранением и обменом пресетами
+type PresetSelector struct {
+	userPresets  []AsprofPreset
+	expanded     bool
+	button       widget.Clickable
+	list         widget.List
+	clickables   []widget.Clickable
+	nameEditor   widget.Editor
+	saveButton   widget.Clickable
+	deleteButton widget.Clickable
+	importButton widget.Clickable
+	exportButton widget.Clickable
+	message      string
+}
+
+func NewPresetSelector() *PresetSelector {
+	pr := &PresetSelector{
+		list: widget.List{
+			List: layout.List{
+				Axis: layout.Vertical,
+			},
+		},
+		nameEditor: widget.Editor{
+			SingleLine: true,
+		},
+	}
+	pr.loadUserPresets()
+	return pr
+}
+
+func (pr *PresetSelector) loadUserPresets() {
+	pr.userPresets = []AsprofPreset{}
+	presets, err := readPresetsFile(getPresetsFilePath())
+	if err == nil {
+		for _, preset := range presets {
+			if !isBuiltinPresetName(preset.Name) {
+				pr.userPresets = append(pr.userPresets, preset)
+			}
+		}
+	}
+	pr.clickables = make([]widget.Clickable, len(pr.allPresets()))
+}
+
+func (pr *PresetSelector) saveUserPresets() error {
+	return writePresetsFile(getPresetsFilePath(), pr.userPresets)
+}
+
+// allPresets возвращает встроенные пресеты, за которыми следуют пользовательские
+func (pr *PresetSelector) allPresets() []AsprofPreset {
+	presets := make([]AsprofPreset, 0, len(builtinPresets)+len(pr.userPresets))
+	presets = append(presets, builtinPresets...)
+	presets = append(presets, pr.userPresets...)
+	return presets
+}
+
+// findByArgs ищет пресет с такими же аргументами (без учета лишних пробелов)
+func (pr *PresetSelector) findByArgs(args string) (AsprofPreset, bool) {
+	normalized := strings.Join(strings.Fields(args), " ")
+	for _, preset := range pr.allPresets() {
+		if strings.Join(strings.Fields(preset.Args), " ") == normalized {
+			return preset, true
+		}
+	}
+	return AsprofPreset{}, false
+}
+
+// addPreset добавляет пользовательский пресет или заменяет существующий с тем же именем
+func (pr *PresetSelector) addPreset(preset AsprofPreset) error {
+	preset.Name = strings.TrimSpace(preset.Name)
+	preset.Args = strings.TrimSpace(preset.Args)
+	if preset.Name == "" {
+		return fmt.Errorf("preset name is empty")
+	}
+	if preset.Args == "" {
+		return fmt.Errorf("preset arguments are empty")
+	}
+	if isBuiltinPresetName(preset.Name) {
+		return fmt.Errorf("%q is a built-in preset", preset.Name)
+	}
+
+	for i := range pr.userPresets {
+		if pr.userPresets[i].Name == preset.Name {
+			pr.userPresets[i] = preset
+			return nil
+		}
+	}
+	pr.userPresets = append(pr.userPresets, preset)
+	pr.clickables = make([]widget.Clickable, len(pr.allPresets()))
+	return nil
+}
+
+func (pr *PresetSelector) deletePreset(name string) bool {
+	for i := range pr.userPresets {
+		if pr.userPresets[i].Name == name {
+			pr.userPresets = append(pr.userPresets[:i], pr.userPresets[i+1:]...)
+			pr.clickables = make([]widget.Clickable, len(pr.allPresets()))
+			return true
+		}
+	}
+	return false
+}
+
+func (pr *PresetSelector) IsExpanded() bool {
+	return pr.expanded
+}
+
+func isBuiltinPresetName(name string) bool {
+	for _, preset := range builtinPresets {
+		if preset.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readPresetsFile читает пресеты из файла. Поддерживается и голый JSON-массив
+func readPresetsFile(path string) ([]AsprofPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file presetsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		var presets []AsprofPreset
+		if errArray := json.Unmarshal(data, &presets); errArray != nil {
+			return nil, fmt.Errorf("invalid presets file: %v", err)
+		}
+		file.Presets = presets
+	}
+
+	presets := []AsprofPreset{}
+	for _, preset := range file.Presets {
+		preset.Name = strings.TrimSpace(preset.Name)
+		preset.Args = strings.TrimSpace(preset.Args)
+		if preset.Name != "" && preset.Args != "" {
+			presets = append(presets, preset)
+		}
+	}
+	return presets, nil
+}
+
+func writePresetsFile(path string, presets []AsprofPreset) error {
+	data, err := json.MarshalIndent(presetsFile{Version: 1, Presets: presets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// presetName имя пресета с такими же аргументами; пусто, если аргументы введены вручную
+func (a *Application) presetName(args string) string {
+	if a.presetSelector == nil {
+		return ""
+	}
+	if preset, ok := a.presetSelector.findByArgs(args); ok {
+		return preset.Name
+	}
+	return ""
+}
+
+// applyPreset подставляет аргументы пресета в поле ввода и запоминает их
+func (a *Application) applyPreset(preset AsprofPreset) {
+	a.asprofArgs = preset.Args
+	a.asprofArgsEditor.SetText(preset.Args)
+	a.saveAsprofArgs()
+	a.rememberSetting(asprofArgsSettingName, preset.Args)
+}
+
+// presetsImportedEvent пресеты прочитаны из выбранного файла
+type presetsImportedEvent struct {
+	path    string
+	presets []AsprofPreset
+	err     error
+}
+
+func (e presetsImportedEvent) apply(a *Application) {
+	a.isChoosingFolder = false
+	a.choosingMessage = ""
+
+	if e.path == "" {
+		return // Пользователь отменил выбор
+	}
+	if e.err != nil {
+		a.presetSelector.message = fmt.Sprintf("Error importing presets: %v", e.err)
+		return
+	}
+
+	imported := 0
+	for _, preset := range e.presets {
+		if a.presetSelector.addPreset(preset) == nil {
+			imported++
+		}
+	}
+	if err := a.presetSelector.saveUserPresets(); err != nil {
+		a.presetSelector.message = fmt.Sprintf("Error saving presets: %v", err)
+		return
+	}
+	a.presetSelector.message = fmt.Sprintf("Imported %d preset(s) from %s", imported, filepath.Base(e.path))
+}
+
+// presetsExportedEvent пресеты записаны в выбранный файл
+type presetsExportedEvent struct {
+	path  string
+	count int
+	err   error
+}
+
+func (e presetsExportedEvent) apply(a *Application) {
+	a.isChoosingFolder = false
+	a.choosingMessage = ""
+
+	switch {
+	case e.path == "":
+		return // Пользователь отменил выбор
+	case e.err != nil:
+		a.presetSelector.message = fmt.Sprintf("Error exporting presets: %v", e.err)
+	default:
+		a.presetSelector.message = fmt.Sprintf("Exported %d preset(s) to %s", e.count, filepath.Base(e.path))
+	}
+}
+
+// importPresets запрашивает файл и добавляет из него пресеты
+func (a *Application) importPresets() {
+	a.isChoosingFolder = true
+	a.choosingMessage = "Choosing presets file..."
+
+	go func() {
+		path, err := chooseFileDialog("Import async-profiler presets", "json")
+		if err != nil {
+			a.post(presetsImportedEvent{})
+			return
+		}
+		presets, err := readPresetsFile(path)
+		a.post(presetsImportedEvent{path: path, presets: presets, err: err})
+	}()
+}
+
+// exportPresets сохраняет пользовательские пресеты в выбранный файл
+func (a *Application) exportPresets() {
+	if len(a.presetSelector.userPresets) == 0 {
+		a.presetSelector.message = "No custom presets to export"
+		return
+	}
+
+	a.isChoosingFolder = true
+	a.choosingMessage = "Choosing presets file..."
+
+	// Копия списка: фоновая горутина не должна читать состояние селектора
+	presets := append([]AsprofPreset{}, a.presetSelector.userPresets...)
+
+	go func() {
+		path, err := chooseSaveFileDialog("Export async-profiler presets", "k8s-jprof-presets.json", "json")
+		if err != nil {
+			a.post(presetsExportedEvent{})
+			return
+		}
+		if filepath.Ext(path) == "" {
+			path += ".json"
+		}
+		err = writePresetsFile(path, presets)
+		a.post(presetsExportedEvent{path: path, count: len(presets), err: err})
+	}()
+}
+
+// LayoutButton отрисовывает кнопку выбора пресета рядом с полем аргументов
+func (pr *PresetSelector) LayoutButton(gtx layout.Context, th *material.Theme, app *Application) layout.Dimensions {
+	for pr.button.Clicked(gtx) {
+		if pr.expanded {
+			pr.expanded = false
+		} else {
+			app.closeAllSelectors()
+			pr.expanded = true
+			pr.message = ""
+		}
+	}
+
+	if pr.button.Hovered() {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+
+	buttonText := "Custom"
+	if preset, ok := pr.findByArgs(app.asprofArgs); ok {
+		buttonText = preset.Name
+	}
+
+	gtx.Constraints.Min.X = gtx.Dp(unit.Dp(170))
+	gtx.Constraints.Max.X = gtx.Dp(unit.Dp(170))
+	btn := material.Button(th, &pr.button, buttonText)
+	btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+	btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+	return btn.Layout(gtx)
+}
+
+// LayoutList отрисовывает раскрытый список пресетов и действия над ними
+func (pr *PresetSelector) LayoutList(gtx layout.Context, th *material.Theme, app *Application) layout.Dimensions {
+	if !pr.expanded {
+		return layout.Dimensions{}
+	}
+
+	presets := pr.allPresets()
+	if len(pr.clickables) < len(presets) {
+		pr.clickables = make([]widget.Clickable, len(presets))
+	}
+	current, hasCurrent := pr.findByArgs(app.asprofArgs)
+
+	// Сохранение текущих аргументов как пресета
+	for pr.saveButton.Clicked(gtx) {
+		preset := AsprofPreset{Name: pr.nameEditor.Text(), Args: app.asprofArgs}
+		if err := pr.addPreset(preset); err != nil {
+			pr.message = fmt.Sprintf("Error: %v", err)
+		} else if err := pr.saveUserPresets(); err != nil {
+			pr.message = fmt.Sprintf("Error saving presets: %v", err)
+		} else {
+			pr.message = fmt.Sprintf("Saved preset %q", strings.TrimSpace(preset.Name))
+			pr.nameEditor.SetText("")
+		}
+	}
+
+	// Удаление выбранного пользовательского пресета
+	for pr.deleteButton.Clicked(gtx) {
+		if hasCurrent && pr.deletePreset(current.Name) {
+			if err := pr.saveUserPresets(); err != nil {
+				pr.message = fmt.Sprintf("Error saving presets: %v", err)
+			} else {
+				pr.message = fmt.Sprintf("Deleted preset %q", current.Name)
+			}
+		}
+	}
+
+	for pr.importButton.Clicked(gtx) {
+		app.importPresets()
+	}
+
+	for pr.exportButton.Clicked(gtx) {
+		app.exportPresets()
+	}
+
+	return layout.Background{}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			// Gray border
+			defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+			paint.Fill(gtx.Ops, color.NRGBA{R: 180, G: 180, B: 180, A: 255})
+			return layout.Dimensions{Size: gtx.Constraints.Max}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: unit.Dp(1), Bottom: unit.Dp(1), Left: unit.Dp(1), Right: unit.Dp(1)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				// White background inside
+				defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+				paint.Fill(gtx.Ops, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					// Список пресетов
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						return material.List(th, &pr.list).Layout(gtx, len(presets), func(gtx layout.Context, index int) layout.Dimensions {
+							for pr.clickables[index].Clicked(gtx) {
+								app.applyPreset(presets[index])
+								pr.expanded = false
+							}
+
+							isSelected := hasCurrent && presets[index].Name == current.Name
+
+							return material.Clickable(gtx, &pr.clickables[index], func(gtx layout.Context) layout.Dimensions {
+								if isSelected {
+									defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+									paint.Fill(gtx.Ops, color.NRGBA{R: 220, G: 220, B: 220, A: 255})
+								}
+
+								if pr.clickables[index].Hovered() {
+									pointer.CursorPointer.Add(gtx.Ops)
+								}
+
+								return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(12), Right: unit.Dp(12)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+									return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+										layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+											name := presets[index].Name
+											if index >= len(builtinPresets) {
+												name += " (custom)"
+											}
+											label := material.Label(th, unit.Sp(14), name)
+											label.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
+											return label.Layout(gtx)
+										}),
+										layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+											label := material.Label(th, unit.Sp(12), presets[index].Args)
+											label.Color = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+											return label.Layout(gtx)
+										}),
+									)
+								})
+							})
+						})
+					}),
+					// Сохранение текущих аргументов под новым именем
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(4), Right: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+								layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+									return layout.Background{}.Layout(gtx,
+										func(gtx layout.Context) layout.Dimensions {
+											defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+											paint.Fill(gtx.Ops, color.NRGBA{R: 248, G: 248, B: 248, A: 255})
+											return layout.Dimensions{Size: gtx.Constraints.Min}
+										},
+										func(gtx layout.Context) layout.Dimensions {
+											return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+												editor := material.Editor(th, &pr.nameEditor, "Save current arguments as...")
+												editor.Color = color.NRGBA{R: 40, G: 40, B: 40, A: 255}
+												editor.HintColor = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+												return editor.Layout(gtx)
+											})
+										},
+									)
+								}),
+								layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+									return presetActionButton(gtx, th, &pr.saveButton, "Save")
+								}),
+								layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+									if !hasCurrent || isBuiltinPresetName(current.Name) {
+										return layout.Dimensions{}
+									}
+									return presetActionButton(gtx, th, &pr.deleteButton, "Delete")
+								}),
+							)
+						})
+					}),
+					// Импорт/экспорт и сообщение о результате
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return layout.Inset{Bottom: unit.Dp(4), Left: unit.Dp(4), Right: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+									return presetActionButton(gtx, th, &pr.importButton, "Import...")
+								}),
+								layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+									return presetActionButton(gtx, th, &pr.exportButton, "Export...")
+								}),
+								layout.Rigid(layout.Spacer{Width: unit.Dp(12)}.Layout),
+								layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+									label := material.Label(th, unit.Sp(12), pr.message)
+									if strings.HasPrefix(pr.message, "Error") {
+										label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+									} else {
+										label.Color = color.NRGBA{R: 80, G: 80, B: 80, A: 255}
+									}
+									return label.Layout(gtx)
+								}),
+							)
+						})
+					}),
+				)
+			})
+		},
+	)
+}
+
+// presetActionButton небольшая серая кнопка для действий с пресетами
+func presetActionButton(gtx layout.Context, th *material.Theme, clickable *widget.Clickable, text string) layout.Dimensions {
+	if clickable.Hovered() {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+	btn := material.Button(th, clickable, text)
+	btn.Background = color.NRGBA{R: 230, G: 230, B: 230, A: 255}
+	btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+	btn.TextSize = unit.Sp(13)
+	return btn.Layout(gtx)
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FrameType тип фрейма, как его записывает async-profiler (jdk.types.FrameType)
+type FrameType uint8
+
+const (
+	FrameInterpreted FrameType = iota
+	FrameJIT
+	FrameInlined
+	FrameNative
+	FrameCpp
+	FrameKernel
+	FrameC1
+)
+
+// Frame фрейм стека
+type Frame struct {
+	Name string
+	Type FrameType
+	Line int // номер строки в исходнике, 0 - неизвестен
+}
+
+// ProfileStack агрегированный стек: фреймы от корня к листу
+type ProfileStack struct {
+	Frames  []Frame
+	Samples int64 // число событий
+	Value   int64 // вес событий: сэмплы, байты или наносекунды
+}
+
+// Profile профиль, построенный из JFR или collapsed-файла
+type Profile struct {
+	Event  string // cpu, wall, alloc, lock
+	Unit   string // единица Value: samples, bytes, nanoseconds
+	Stacks []ProfileStack
+
+	StartNanos int64
+	EndNanos   int64
+}
+
+// jfrEventKinds события async-profiler для каждого вида профиля (в порядке выбора по умолчанию)
+var jfrEventKinds = []struct {
+	kind   string
+	unit   string
+	events []string
+}{
+	{"cpu", "samples", []string{"jdk.ExecutionSample"}},
+	{"wall", "samples", []string{"profiler.WallClockSample"}},
+	{"alloc", "bytes", []string{"jdk.ObjectAllocationInNewTLAB", "jdk.ObjectAllocationOutsideTLAB", "jdk.ObjectAllocationSample"}},
+	{"lock", "nanoseconds", []string{"jdk.JavaMonitorEnter", "jdk.ThreadPark"}},
+}
+
+// JFRReadOptions что взять из JFR
+type JFRReadOptions struct {
+	Event   string         // cpu, wall, alloc, lock; пусто - первый вид, для которого есть события
+	Threads bool           // корневой фрейм с именем потока
+	Simple  bool           // имена классов без пакета
+	Dotted  bool           // пакеты через точку (java.lang.String), иначе через слэш, как в JFR
+	Include *regexp.Regexp // только стеки, где есть подходящий фрейм
+	Exclude *regexp.Regexp // без стеков, где есть подходящий фрейм
+	From    time.Duration  // окно от начала записи; 0 - без ограничения
+	To      time.Duration
+}
+
+// profileBuilder агрегирует одинаковые стеки (с учетом типов и строк фреймов)
+type profileBuilder struct {
+	profile *Profile
+	index   map[string]int
+}
+
+func newProfileBuilder(event, unit string) *profileBuilder {
+	return &profileBuilder{profile: &Profile{Event: event, Unit: unit}, index: map[string]int{}}
+}
+
+func (b *profileBuilder) add(frames []Frame, samples, value int64) {
+	key := stackKey(frames)
+	if i, ok := b.index[key]; ok {
+		b.profile.Stacks[i].Samples += samples
+		b.profile.Stacks[i].Value += value
+		return
+	}
+	b.index[key] = len(b.profile.Stacks)
+	b.profile.Stacks = append(b.profile.Stacks, ProfileStack{Frames: frames, Samples: samples, Value: value})
+}
+
+func (b *profileBuilder) observeTime(nanos int64) {
+	p := b.profile
+	if p.StartNanos == 0 || nanos < p.StartNanos {
+		p.StartNanos = nanos
+	}
+	if nanos > p.EndNanos {
+		p.EndNanos = nanos
+	}
+}
+
+// jfrCancelCheckInterval через сколько событий чтение JFR проверяет отмену контекста
+const jfrCancelCheckInterval = 4096
+
+// jfrCancelChecker проверка отмены для обработчика событий: в начале каждого чанка и периодически внутри него
+func jfrCancelChecker(ctx context.Context) func(chunk *jfrChunk) error {
+	var lastChunk *jfrChunk
+	events := 0
+	return func(chunk *jfrChunk) error {
+		events++
+		if chunk == lastChunk && events%jfrCancelCheckInterval != 0 {
+			return nil
+		}
+		lastChunk = chunk
+		return ctx.Err()
+	}
+}
+
+// ReadJFRProfile строит профиль из JFR без Java. Чтение прерывается с ошибкой ctx.Err(),
+// когда контекст отменен или истек его таймаут
+func ReadJFRProfile(ctx context.Context, path string, opts JFRReadOptions) (*Profile, error) {
+	data, err := readJFRData(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	event := opts.Event
+	if event == "" {
+		if event, err = detectJFREvent(ctx, data); err != nil {
+			return nil, err
+		}
+	}
+
+	var wanted map[string]bool
+	unit := ""
+	for _, k := range jfrEventKinds {
+		if k.kind == event {
+			unit = k.unit
+			wanted = map[string]bool{}
+			for _, name := range k.events {
+				wanted[name] = true
+			}
+		}
+	}
+	if wanted == nil {
+		return nil, fmt.Errorf("unknown event type %q", event)
+	}
+
+	b := newProfileBuilder(event, unit)
+	stacks := &jfrStackCache{names: classNameStyle{simple: opts.Simple, dotted: opts.Dotted}}
+	recordingStart := int64(-1)
+	checkCancel := jfrCancelChecker(ctx)
+	err = readJFR(data, func(chunk *jfrChunk, e jfrEvent) error {
+		if err := checkCancel(chunk); err != nil {
+			return err
+		}
+		if recordingStart < 0 {
+			recordingStart = chunk.startNanos
+		}
+		if !wanted[e.typ.name] {
+			return nil
+		}
+
+		eventTime := chunk.ticksToNanos(chunk.intField(e.typ, e.fields, "startTime"))
+		if opts.From > 0 && eventTime < recordingStart+int64(opts.From) {
+			return nil
+		}
+		if opts.To > 0 && eventTime > recordingStart+int64(opts.To) {
+			return nil
+		}
+		stacks.reset(chunk)
+
+		frames := stacks.frames(chunk.intField(e.typ, e.fields, "stackTrace"))
+		samples, value := int64(1), int64(1)
+
+		switch e.typ.name {
+		case "profiler.WallClockSample":
+			if n := chunk.intField(e.typ, e.fields, "samples"); n > 0 {
+				samples, value = n, n
+			}
+		case "jdk.ObjectAllocationInNewTLAB":
+			value = chunk.intField(e.typ, e.fields, "tlabSize")
+			frames = stacks.appendClassFrame(frames, chunk.intField(e.typ, e.fields, "objectClass"), FrameInlined)
+		case "jdk.ObjectAllocationOutsideTLAB":
+			value = chunk.intField(e.typ, e.fields, "allocationSize")
+			frames = stacks.appendClassFrame(frames, chunk.intField(e.typ, e.fields, "objectClass"), FrameKernel)
+		case "jdk.ObjectAllocationSample":
+			value = chunk.intField(e.typ, e.fields, "weight")
+			frames = stacks.appendClassFrame(frames, chunk.intField(e.typ, e.fields, "objectClass"), FrameInlined)
+		case "jdk.JavaMonitorEnter":
+			value = chunk.durationToNanos(chunk.intField(e.typ, e.fields, "duration"))
+			frames = stacks.appendClassFrame(frames, chunk.intField(e.typ, e.fields, "monitorClass"), FrameKernel)
+		case "jdk.ThreadPark":
+			value = chunk.durationToNanos(chunk.intField(e.typ, e.fields, "duration"))
+			frames = stacks.appendClassFrame(frames, chunk.intField(e.typ, e.fields, "parkedClass"), FrameKernel)
+		}
+
+		if len(frames) == 0 || !stackMatches(frames, opts.Include, opts.Exclude) {
+			return nil
+		}
+		if opts.Threads {
+			thread := chunk.intField(e.typ, e.fields, "sampledThread")
+			if thread == 0 {
+				thread = chunk.intField(e.typ, e.fields, "eventThread")
+			}
+			frames = append([]Frame{{Name: chunk.threadName(thread), Type: FrameNative}}, frames...)
+		}
+		b.observeTime(eventTime)
+		b.add(frames, samples, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.profile, nil
+}
+
+// detectJFREvent первый вид профиля, для которого в файле есть события.
+// Это отдельный проход по файлу, поэтому он тоже прерывается по ctx
+func detectJFREvent(ctx context.Context, data []byte) (string, error) {
+	present := map[string]bool{}
+	checkCancel := jfrCancelChecker(ctx)
+	err := readJFR(data, func(chunk *jfrChunk, e jfrEvent) error {
+		if err := checkCancel(chunk); err != nil {
+			return err
+		}
+		present[e.typ.name] = true
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, k := range jfrEventKinds {
+		for _, name := range k.events {
+			if present[name] {
+				return k.kind, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no profiling samples in JFR")
+}
+
+// jfrStackCache разрешенные стеки текущего чанка
+type jfrStackCache struct {
+	chunk  *jfrChunk
+	stacks map[int64][]Frame
+	names  classNameStyle
+}
+
+// classNameStyle вид имен классов в стеках
+type classNameStyle struct {
+	simple bool // без пакета
+	dotted bool // java.lang.String вместо java/lang/String
+}
+
+// format приводит имя класса из JFR (java/lang/String) к выбранному виду
+func (n classNameStyle) format(name string) string {
+	if n.simple {
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+	}
+	if n.dotted {
+		name = strings.ReplaceAll(name, "/", ".")
+	}
+	return name
+}
+
+func (s *jfrStackCache) reset(chunk *jfrChunk) {
+	if s.chunk != chunk {
+		s.chunk = chunk
+		s.stacks = map[int64][]Frame{}
+	}
+}
+
+// frames стек по id из пула jdk.types.StackTrace, от корня к листу
+func (s *jfrStackCache) frames(id int64) []Frame {
+	if frames, ok := s.stacks[id]; ok {
+		return frames
+	}
+	c := s.chunk
+	trace, traceType := c.constant("jdk.types.StackTrace", id)
+	rawFrames, _ := c.field(traceType, trace, "frames").([]interface{})
+	frameType := c.byName["jdk.types.StackFrame"]
+
+	// В JFR первым идет лист
+	frames := make([]Frame, 0, len(rawFrames))
+	for i := len(rawFrames) - 1; i >= 0; i-- {
+		raw, _ := rawFrames[i].(jfrStruct)
+		frames = append(frames, Frame{
+			Name: s.methodName(c.intField(frameType, raw, "method")),
+			Type: c.frameType(c.intField(frameType, raw, "type")),
+			Line: int(c.intField(frameType, raw, "lineNumber")),
+		})
+	}
+	if truncated, _ := c.field(traceType, trace, "truncated").(bool); truncated {
+		frames = append([]Frame{{Name: "[truncated]", Type: FrameNative}}, frames...)
+	}
+	s.stacks[id] = frames
+	return frames
+}
+
+// methodName имя метода вида "java.lang.Thread.run"; у нативных фреймов класса нет
+func (s *jfrStackCache) methodName(id int64) string {
+	c := s.chunk
+	method, methodType := c.constant("jdk.types.Method", id)
+	if method == nil {
+		return "[unknown]"
+	}
+	name := c.symbol(c.intField(methodType, method, "name"))
+	className := s.className(c.intField(methodType, method, "type"))
+	if className == "" {
+		return name
+	}
+	return className + "." + name
+}
+
+// className имя класса в выбранном виде
+func (s *jfrStackCache) className(id int64) string {
+	c := s.chunk
+	class, classType := c.constant("java.lang.Class", id)
+	if class == nil {
+		return ""
+	}
+	return s.names.format(c.symbol(c.intField(classType, class, "name")))
+}
+
+func (c *jfrChunk) symbol(id int64) string {
+	symbol, symbolType := c.constant("jdk.types.Symbol", id)
+	return c.stringField(symbolType, symbol, "string")
+}
+
+// frameType тип фрейма по описанию из пула jdk.types.FrameType
+func (c *jfrChunk) frameType(id int64) FrameType {
+	value, t := c.constant("jdk.types.FrameType", id)
+	switch c.stringField(t, value, "description") {
+	case "Interpreted":
+		return FrameInterpreted
+	case "JIT compiled":
+		return FrameJIT
+	case "Inlined":
+		return FrameInlined
+	case "Native":
+		return FrameNative
+	case "C++":
+		return FrameCpp
+	case "Kernel":
+		return FrameKernel
+	case "C1 compiled":
+		return FrameC1
+	}
+	if id >= 0 && id <= int64(FrameC1) {
+		return FrameType(id)
+	}
+	return FrameNative
+}
+
+// threadName имя потока в виде "[name tid=N]", как его показывает async-profiler
+func (c *jfrChunk) threadName(id int64) string {
+	thread, t := c.constant("java.lang.Thread", id)
+	if thread == nil {
+		return "[unknown thread]"
+	}
+	name := c.stringField(t, thread, "javaName")
+	if name == "" {
+		name = c.stringField(t, thread, "osName")
+	}
+	if tid := c.intField(t, thread, "osThreadId"); tid != 0 {
+		return fmt.Sprintf("[%s tid=%d]", name, tid)
+	}
+	return "[" + name + "]"
+}
+
+// stackMatches проходит ли стек фильтры include/exclude: они проверяются по каждому фрейму
+func stackMatches(frames []Frame, include, exclude *regexp.Regexp) bool {
+	matches := func(re *regexp.Regexp) bool {
+		for _, f := range frames {
+			if re.MatchString(f.Name) {
+				return true
+			}
+		}
+		return false
+	}
+	if include != nil && !matches(include) {
+		return false
+	}
+	return exclude == nil || !matches(exclude)
+}
+
+// appendClassFrame добавляет листом класс объекта аллокации или монитора
+func (s *jfrStackCache) appendClassFrame(frames []Frame, classID int64, frameType FrameType) []Frame {
+	name := s.className(classID)
+	if name == "" {
+		return frames
+	}
+	result := make([]Frame, len(frames), len(frames)+1)
+	copy(result, frames)
+	return append(result, Frame{Name: name, Type: frameType})
+}
+
+// collapsedKey строка стека в collapsed-формате; с annotate добавляются суффиксы типов фреймов
+func collapsedKey(frames []Frame, annotate bool) string {
+	var sb strings.Builder
+	for i, f := range frames {
+		if i > 0 {
+			sb.WriteByte(';')
+		}
+		sb.WriteString(f.Name)
+		if annotate {
+			sb.WriteString(frameTypeSuffix(f.Type))
+		}
+	}
+	return sb.String()
+}
+
+// stackKey ключ агрегации стека: имя, тип и строка каждого фрейма
+func stackKey(frames []Frame) string {
+	var sb strings.Builder
+	for _, f := range frames {
+		sb.WriteString(f.Name)
+		sb.WriteByte(0)
+		sb.WriteString(strconv.Itoa(int(f.Type)))
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(f.Line))
+		sb.WriteByte(';')
+	}
+	return sb.String()
+}
+
+func frameTypeSuffix(t FrameType) string {
+	switch t {
+	case FrameJIT:
+		return "_[j]"
+	case FrameInlined:
+		return "_[i]"
+	case FrameKernel:
+		return "_[k]"
+	case FrameC1:
+		return "_[1]"
+	case FrameInterpreted:
+		return "_[0]"
+	}
+	return ""
+}
+
+// ReadCollapsedProfile читает профиль из collapsed-файла ("стек количество").
+// Суффиксы типов фреймов (_[j], _[i], ...) распознаются; фреймы без суффикса считаются Java-фреймами
+func ReadCollapsedProfile(path string, include, exclude *regexp.Regexp) (*Profile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	b := newProfileBuilder("", "samples")
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		space := strings.LastIndexByte(text, ' ')
+		if space <= 0 {
+			return nil, fmt.Errorf("line %d: expected \"stack count\"", line)
+		}
+		count, err := strconv.ParseInt(text[space+1:], 10, 64)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("line %d: invalid count %q", line, text[space+1:])
+		}
+		var frames []Frame
+		for _, name := range strings.Split(text[:space], ";") {
+			frames = append(frames, parseCollapsedFrame(name))
+		}
+		if stackMatches(frames, include, exclude) {
+			b.add(frames, count, count)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b.profile, nil
+}
+
+// parseCollapsedFrame фрейм collapsed-стека с необязательным суффиксом типа
+func parseCollapsedFrame(name string) Frame {
+	for _, t := range []FrameType{FrameJIT, FrameInlined, FrameKernel, FrameC1, FrameInterpreted} {
+		if suffix := frameTypeSuffix(t); strings.HasSuffix(name, suffix) {
+			return Frame{Name: strings.TrimSuffix(name, suffix), Type: t}
+		}
+	}
+	return Frame{Name: name, Type: FrameJIT}
+}
+
+// WriteCollapsed пишет профиль в collapsed-формате ("стек количество"), строки отсортированы
+func (p *Profile) WriteCollapsed(w io.Writer) error {
+	lines := make([]string, 0, len(p.Stacks))
+	counts := map[string]int64{}
+	for _, s := range p.Stacks {
+		key := collapsedKey(s.Frames, false)
+		if _, ok := counts[key]; !ok {
+			lines = append(lines, key)
+		}
+		counts[key] += s.Samples
+	}
+	sort.Strings(lines)
+
+	bw := bufio.NewWriter(w)
+	for _, line := range lines {
+		fmt.Fprintf(bw, "%s %d\n", line, counts[line])
+	}
+	return bw.Flush()
+}
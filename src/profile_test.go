@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collapsedProfile профиль из строк "стек количество"; Value совпадает с числом сэмплов
+func collapsedProfile(event, unit string, lines ...string) *Profile {
+	b := newProfileBuilder(event, unit)
+	for _, line := range lines {
+		space := strings.LastIndexByte(line, ' ')
+		count, _ := strconv.ParseInt(line[space+1:], 10, 64)
+		var frames []Frame
+		for _, name := range strings.Split(line[:space], ";") {
+			frames = append(frames, parseCollapsedFrame(name))
+		}
+		b.add(frames, count, count)
+	}
+	return b.profile
+}
+
+// collapsedLines стеки профиля в виде отсортированных строк "стек количество"
+func collapsedLines(p *Profile) []string {
+	var lines []string
+	for _, s := range p.Stacks {
+		lines = append(lines, collapsedKey(s.Frames, false)+" "+strconv.FormatInt(s.Samples, 10))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestReadJFRProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      JFRReadOptions
+		wantEvent string
+		wantUnit  string
+		want      []string // collapsed-стеки с числом сэмплов
+		wantValue int64
+	}{
+		{
+			name:      "detects first event kind",
+			wantEvent: "cpu", wantUnit: "samples",
+			want:      []string{"java/lang/Thread.run;com/acme/Work.compute 6", "java/lang/Thread.run;com/acme/Work.compute;malloc 2"},
+			wantValue: 8,
+		},
+		{
+			name:      "dotted names with threads",
+			opts:      JFRReadOptions{Event: "cpu", Dotted: true, Threads: true},
+			wantEvent: "cpu", wantUnit: "samples",
+			want:      []string{"[main tid=100];java.lang.Thread.run;com.acme.Work.compute 6", "[main tid=100];java.lang.Thread.run;com.acme.Work.compute;malloc 2"},
+			wantValue: 8,
+		},
+		{
+			name:      "simple names",
+			opts:      JFRReadOptions{Event: "cpu", Simple: true},
+			wantEvent: "cpu", wantUnit: "samples",
+			want:      []string{"Thread.run;Work.compute 6", "Thread.run;Work.compute;malloc 2"},
+			wantValue: 8,
+		},
+		{
+			name:      "allocations with class frame",
+			opts:      JFRReadOptions{Event: "alloc", Dotted: true},
+			wantEvent: "alloc", wantUnit: "bytes",
+			want:      []string{"java.lang.Thread.run;com.acme.Work.compute;com.acme.Work 2"},
+			wantValue: 2 * 4096,
+		},
+		{
+			name:      "include",
+			opts:      JFRReadOptions{Event: "cpu", Include: regexp.MustCompile(`malloc`)},
+			wantEvent: "cpu", wantUnit: "samples",
+			want:      []string{"java/lang/Thread.run;com/acme/Work.compute;malloc 2"},
+			wantValue: 2,
+		},
+		{
+			name:      "exclude",
+			opts:      JFRReadOptions{Event: "cpu", Exclude: regexp.MustCompile(`malloc`)},
+			wantEvent: "cpu", wantUnit: "samples",
+			want:      []string{"java/lang/Thread.run;com/acme/Work.compute 6"},
+			wantValue: 6,
+		},
+		{
+			// Сэмплы в compute записаны в первую микросекунду чанка, malloc - позже
+			name:      "time range",
+			opts:      JFRReadOptions{Event: "cpu", From: 500 * time.Nanosecond},
+			wantEvent: "cpu", wantUnit: "samples",
+			want:      []string{"java/lang/Thread.run;com/acme/Work.compute;malloc 2"},
+			wantValue: 2,
+		},
+		{
+			name:      "no events of this kind",
+			opts:      JFRReadOptions{Event: "lock"},
+			wantEvent: "lock", wantUnit: "nanoseconds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ReadJFRProfile(context.Background(), sampleJFR, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Event != tt.wantEvent || p.Unit != tt.wantUnit {
+				t.Errorf("event/unit = %s/%s, want %s/%s", p.Event, p.Unit, tt.wantEvent, tt.wantUnit)
+			}
+			if got := collapsedLines(p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stacks = %q, want %q", got, tt.want)
+			}
+			var value int64
+			for _, s := range p.Stacks {
+				value += s.Value
+			}
+			if value != tt.wantValue {
+				t.Errorf("total value = %d, want %d", value, tt.wantValue)
+			}
+		})
+	}
+}
+
+// expiringContext контекст, который истекает после заданного числа проверок Err
+type expiringContext struct {
+	context.Context
+	checks int
+}
+
+func (c *expiringContext) Err() error {
+	if c.checks <= 0 {
+		return context.DeadlineExceeded
+	}
+	c.checks--
+	return nil
+}
+
+func TestReadJFRProfileErrors(t *testing.T) {
+	if _, err := ReadJFRProfile(context.Background(), sampleJFR, JFRReadOptions{Event: "itimer"}); err == nil {
+		t.Error("unknown event kind: want error")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReadJFRProfile(ctx, sampleJFR, JFRReadOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled context: error = %v, want %v", err, context.Canceled)
+	}
+	// Таймаут посреди чтения возвращается вместе с позицией чанка, но остается распознаваемым
+	expiring := &expiringContext{Context: context.Background(), checks: 1}
+	if _, err := ReadJFRProfile(expiring, sampleJFR, JFRReadOptions{Event: "cpu"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline during read: error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// Без выбранного события файл сначала читается целиком, чтобы найти вид профиля
+	data, err := readJFRData(sampleJFR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiring = &expiringContext{Context: context.Background()}
+	if _, err := detectJFREvent(expiring, data); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline while detecting the event: error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestConvertNativeTimeout(t *testing.T) {
+	ctx := &expiringContext{Context: context.Background(), checks: 1}
+	_, err := convertNative(ctx, sampleJFR, "collapsed", filepath.Join(t.TempDir(), "out"), ConvertOptions{}, "cpu")
+	if err == nil || err.Error() != "converting JFR: timed out" {
+		t.Errorf("error = %v, want converting JFR: timed out", err)
+	}
+}
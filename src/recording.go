@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// recordingParams параметры записи, снятые в UI-горутине в момент нажатия "Start Recording".
+// Фоновая горутина работает только с ними и не читает состояние приложения.
+type recordingParams struct {
+	kubeconfig   string
+	namespace    string
+	pod          string
+	asprofArgs   string
+	version      string
+	profilerTar  string
+	outputFolder string
+	formats      []string // форматы конвертации; пусто - только JFR
+	duration     time.Duration // длительность записи из -d
+	timeouts     OperationTimeouts
+	converter    string // предпочитаемый бэкенд конвертации
+	convertOpts  ConvertOptions
+	naming       NamingSettings // шаблоны имени файла и подпапок
+	event        string         // событие из -e для {event}
+	preset       string         // пресет с такими же аргументами для {preset}
+
+	retention RetentionSettings // правила хранения на момент запуска
+}
+
+// recordingOutcome результат успешной записи
+type recordingOutcome struct {
+	jfrPath     string             // сохраненный JFR
+	conversions []conversionResult // результаты конвертации по каждому выбранному формату
+	htmlPath    string             // HTML для кнопки "Open in Browser"
+	metaPath    string             // sidecar с метаданными записи; пусто, если записать не удалось
+
+	summary      *RecordingSummary // сводка по записи; nil, если построить не удалось
+	summaryFiles []string          // сводка в markdown и JSON
+}
+
+// recordingStatusEvent промежуточный статус записи
+type recordingStatusEvent struct {
+	status string
+}
+
+func (e recordingStatusEvent) apply(a *Application) {
+	a.recordingResult = e.status
+}
+
+// recordingFinishedEvent завершение записи (успешное или с ошибкой)
+// История и правила хранения к этому моменту уже обработаны в фоне, apply меняет только состояние в памяти
+type recordingFinishedEvent struct {
+	params  recordingParams
+	outcome recordingOutcome
+	err     error
+	history historySnapshot // история после добавления записи для открытого окна истории
+}
+
+func (e recordingFinishedEvent) apply(a *Application) {
+	a.isRecording = false
+	a.lastJFRPath = ""
+	a.recordingSummary = ""
+	if a.historyPanel != nil && a.historyPanel.visible {
+		a.historyPanel.setSnapshot(e.history)
+	}
+
+	if e.err != nil {
+		a.recordingResult = "Error " + e.err.Error()
+		return
+	}
+
+	// Перечисляем все созданные файлы, включая сводку, даже если конвертация не выбрана;
+	// ошибки отдельных форматов не отменяют остальные результаты
+	saved := []string{filepath.Base(e.outcome.jfrPath)}
+	var failed []string
+	for _, c := range e.outcome.conversions {
+		if c.err != nil {
+			failed = append(failed, fmt.Sprintf("%s failed: %v", c.label(), c.err))
+			continue
+		}
+		saved = append(saved, filepath.Base(c.path))
+	}
+	for _, path := range e.outcome.summaryFiles {
+		saved = append(saved, filepath.Base(path))
+	}
+	a.recordingResult = fmt.Sprintf("Saved %s to %s", strings.Join(saved, ", "), filepath.Dir(e.outcome.jfrPath))
+	if len(failed) > 0 {
+		a.recordingResult += " | Error: " + strings.Join(failed, "; ")
+	}
+	a.outputPath = filepath.Dir(e.outcome.jfrPath) // Папка с файлами (с учетом подпапок из шаблона) для кликабельности
+	a.htmlOutputPath = e.outcome.htmlPath
+	a.lastJFRPath = e.outcome.jfrPath
+	if e.outcome.summary != nil {
+		a.recordingSummary = e.outcome.summary.Text()
+	}
+	a.hasCompletedRecording = true // Помечаем что запись завершена
+
+	if a.htmlOutputPath != "" {
+		a.showBrowserButton = true
+	}
+}
+
+// Функция для запуска профилирования в отдельной горутине
+func (a *Application) startRecording() {
+	// Проверяем аргументы до загрузки профайлера в под
+	if errs, _ := a.validateAsprofArgs(); len(errs) > 0 {
+		a.recordingResult = "Error: invalid arguments: " + strings.Join(errs, "; ")
+		return
+	}
+
+	opts, _ := ParseAsprofArgs(a.asprofArgs)
+	params := recordingParams{
+		kubeconfig:   a.kubeconfigSelector.GetSelectedConfig(),
+		namespace:    a.namespaceSelector.GetSelectedNamespace(),
+		pod:          a.podSelector.GetSelectedPod(),
+		asprofArgs:   a.asprofArgs,
+		version:      a.version,
+		profilerTar:  a.profilerPath,
+		outputFolder: a.selectedFolder,
+		formats:      a.formatSelector.GetSelectedFormats(),
+		duration:     opts.RecordingDuration(),
+		timeouts:     a.timeouts,
+		converter:    a.converterBackend,
+		convertOpts:  a.convertOptions,
+		naming:       a.naming,
+		event:        opts.Event,
+		preset:       a.presetName(a.asprofArgs),
+	}
+	a.launchRecording(params)
+}
+
+// launchRecording запускает запись с готовыми параметрами: из UI или повтор из истории
+func (a *Application) launchRecording(params recordingParams) {
+	// Устанавливаем состояние записи
+	a.isRecording = true
+	a.recordingResult = ""
+	a.showBrowserButton = false // Скрываем кнопку браузера при новой записи
+	a.htmlOutputPath = ""       // Очищаем путь к HTML файлу
+	params.retention = a.retention
+
+	go func() {
+		// Общий лимит сессии: зависший туннель не оставит запись в состоянии "Recording..." навсегда
+		ctx, cancel := context.WithTimeout(context.Background(), params.timeouts.SessionTimeout(params.duration))
+		defer cancel()
+
+		outcome, err := runRecording(ctx, params, func(status string) {
+			a.post(recordingStatusEvent{status: status})
+		})
+		// Работа с диском после записи выполняется здесь, а не в UI-горутине
+		recordHistoryEntry(newHistoryEntry(params, outcome, err))
+		applyRetentionAfterRecording(params.retention)
+		a.post(recordingFinishedEvent{params: params, outcome: outcome, err: err, history: loadHistorySnapshot()})
+	}()
+}
+
+// runRecording выполняет запись: загрузка профайлера в под, запуск, копирование и конвертация результата.
+// Ошибки формулируются так, чтобы после "Error " получалось понятное сообщение.
+// Идемпотентные шаги повторяются при сетевых сбоях; запуск asprof не повторяется.
+func runRecording(ctx context.Context, p recordingParams, progress func(status string)) (recordingOutcome, error) {
+	var outcome recordingOutcome
+	t := p.timeouts
+
+	// kubectl с таймаутом на попытку и повторами
+	retryKubectl := func(timeout time.Duration, dir string, args ...string) error {
+		return withRetry(ctx, t.Retries, timeout, func(ctx context.Context) error {
+			return runKubectlInDir(ctx, dir, kubeconfigPath(p.kubeconfig), args...)
+		})
+	}
+
+	// Имена и аргументы передаются в под отдельными токенами, без shell
+	if err := validateNamespaceName(p.namespace); err != nil {
+		return outcome, fmt.Errorf("validating target: %v", err)
+	}
+	if err := validatePodName(p.pod); err != nil {
+		return outcome, fmt.Errorf("validating target: %v", err)
+	}
+	asprofArgv, err := asprofCommandArgv(p.asprofArgs, p.version)
+	if err != nil {
+		return outcome, fmt.Errorf("validating arguments: %v", err)
+	}
+
+	// Kubeconfig передается каждой команде через --kubeconfig: без копий с токенами и без изменения окружения процесса
+	if _, err := os.Stat(kubeconfigPath(p.kubeconfig)); err != nil {
+		return outcome, fmt.Errorf("reading kubeconfig: %v", err)
+	}
+
+	// Приватная папка для временных файлов записи (удаляется при следующем запуске, если процесс упадет)
+	workDir, err := createRecordingWorkDir()
+	if err != nil {
+		return outcome, fmt.Errorf("creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	// Пути для профайлера
+	remoteDir := "/tmp/async-profiler-4.1-linux-x64"
+	remoteTar := "/tmp/async-profiler-4.1-linux-x64.tar.gz"
+	remoteJfr := "/tmp/recording.jfr"
+
+	// Аргументы для namespace
+	nsArgs := []string{}
+	if p.namespace != "" {
+		nsArgs = append(nsArgs, "-n", p.namespace)
+	}
+
+	// Проверяем наличие профайлера в поде
+	checkArgs := kubectlExecArgs(p.namespace, p.pod, "test", "-d", remoteDir)
+	if err := retryKubectl(t.Exec, "", checkArgs...); err != nil {
+		if ctx.Err() != nil {
+			return outcome, sessionError(ctx, "checking profiler", err)
+		}
+		progress("Copying profiler...")
+
+		// Копируем профайлер в под
+		copyArgs := append(nsArgs, "cp", p.profilerTar, fmt.Sprintf("%s:%s", p.pod, remoteTar))
+		if err := retryKubectl(t.Copy, "", copyArgs...); err != nil {
+			return outcome, sessionError(ctx, "copying profiler", err)
+		}
+
+		progress("Extracting profiler...")
+
+		// Извлекаем профайлер
+		extractArgs := kubectlExecArgs(p.namespace, p.pod, "tar", "xzf", remoteTar, "-C", "/tmp")
+		if err := retryKubectl(t.Exec, "", extractArgs...); err != nil {
+			return outcome, sessionError(ctx, "extracting profiler", err)
+		}
+	}
+
+	// Метаданные цели собираются до запуска: под может быть пересоздан во время записи
+	meta := RecordingMetadata{
+		Kubeconfig:      p.kubeconfig,
+		Namespace:       p.namespace,
+		Pod:             p.pod,
+		AsprofArgs:      p.asprofArgs,
+		ProfilerVersion: p.version,
+		Formats:         p.formats,
+		ConvertOptions:  p.convertOpts,
+	}
+	collectTargetMetadata(ctx, &meta, kubeconfigPath(p.kubeconfig), t.List)
+
+	progress("Starting profiler...")
+
+	// Запускаем профайлер
+	profilerCmd := append([]string{remoteDir + "/bin/asprof", "-f", remoteJfr}, asprofArgv...)
+	execArgs := kubectlExecArgs(p.namespace, p.pod, append(profilerCmd, "1")...)
+	profilerCtx, cancelProfiler := context.WithTimeout(ctx, p.duration+t.Exec)
+	meta.Start = time.Now()
+	err = runKubectlWithConfig(profilerCtx, kubeconfigPath(p.kubeconfig), execArgs...)
+	meta.End = time.Now()
+	cancelProfiler()
+	if err != nil {
+		return outcome, sessionError(ctx, "running profiler", err)
+	}
+
+	progress("Copying result...")
+
+	// Имя файла и подпапки по шаблонам; при совпадении имен добавляется суффикс
+	outputDir, base := p.naming.recordingOutputBase(p.outputFolder, namingValues(meta, p.event, p.preset, meta.Start))
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return outcome, fmt.Errorf("creating output folder: %v", err)
+	}
+	base = uniqueOutputBase(outputDir, base)
+	filename := base + ".jfr"
+	outputPath := filepath.Join(outputDir, filename)
+
+	// Сначала копируем из пода в рабочую папку записи под фиксированным именем. Путь для kubectl cp относительный:
+	// абсолютный путь Windows ("C:\...") kubectl принял бы за "под:путь"
+	const localName = "recording.jfr"
+	localTempFile := filepath.Join(workDir, localName)
+
+	var sourceSpec string
+	if p.namespace != "" {
+		sourceSpec = fmt.Sprintf("%s/%s:%s", p.namespace, p.pod, remoteJfr)
+	} else {
+		sourceSpec = fmt.Sprintf("%s:%s", p.pod, remoteJfr)
+	}
+
+	copyResultArgs := []string{"cp", sourceSpec, localName}
+	if err := retryKubectl(t.Copy, workDir, copyResultArgs...); err != nil {
+		return outcome, sessionError(ctx, "copying result", err)
+	}
+
+	// Перемещаем из рабочей папки в целевую папку
+	if err := moveFile(localTempFile, outputPath); err != nil {
+		return outcome, fmt.Errorf("moving file: %v", err)
+	}
+	outcome.jfrPath = outputPath
+
+	// Конвертируем JFR во все выбранные форматы
+	if len(p.formats) > 0 {
+		progress("Converting JFR...")
+
+		outcome.conversions = convertAll(ctx, outputPath, workDir, p.formats, filepath.Join(outputDir, base), p.converter, p.convertOpts, t.Conversion)
+
+		// Сохраняем путь к первому HTML файлу для кнопки браузера
+		for _, c := range outcome.conversions {
+			if c.err == nil && filepath.Ext(c.path) == ".html" {
+				outcome.htmlPath = c.path
+				break
+			}
+		}
+	}
+
+	// Сводка по записи: как и sidecar, не обязательна для результата
+	progress("Summarizing...")
+	summaryCtx, cancelSummary := context.WithTimeout(ctx, t.Conversion)
+	summary, err := summarizeFile(summaryCtx, outputPath, p.convertOpts)
+	cancelSummary()
+	if err != nil {
+		log.Printf("Не удалось построить сводку по записи: %v", err)
+	} else {
+		outcome.summary = summary
+		title := fmt.Sprintf("Recording summary: %s/%s, %s", p.namespace, p.pod, meta.Start.Format("2006-01-02 15:04:05"))
+		outcome.summaryFiles, err = writeSummaryFiles(summary, filepath.Join(outputDir, base), title)
+		if err != nil {
+			log.Printf("Не удалось записать сводку по записи: %v", err)
+		}
+	}
+
+	// Sidecar с метаданными: ошибка записи не отменяет сохраненные результаты
+	meta.JVM = readJVMMetadata(outputPath)
+	meta.Files = []string{filename}
+	for _, c := range outcome.conversions {
+		if c.err == nil {
+			meta.Files = append(meta.Files, filepath.Base(c.path))
+		}
+	}
+	for _, path := range outcome.summaryFiles {
+		meta.Files = append(meta.Files, filepath.Base(path))
+	}
+	if err := writeRecordingMetadata(metadataPath(outputPath), meta); err != nil {
+		log.Printf("Не удалось записать метаданные записи: %v", err)
+	} else {
+		outcome.metaPath = metadataPath(outputPath)
+	}
+
+	// Очищаем временные файлы в поде
+	progress("Cleaning up...")
+
+	// Удаляем файлы и папку профилировщика
+	cleanupArgs := kubectlExecArgs(p.namespace, p.pod, "rm", "-rf", remoteJfr, remoteTar, remoteDir)
+	retryKubectl(t.Exec, "", cleanupArgs...) // Игнорируем ошибки очистки
+
+	return outcome, nil
+}
+
+// kubeconfigPath полный путь к kubeconfig из ~/.kube
+func kubeconfigPath(name string) string {
+	return filepath.Join(getKubeDir(), name)
+}
+
+// sessionError формулирует ошибку шага с учетом общего лимита сессии
+func sessionError(ctx context.Context, step string, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s: recording session timed out", step)
+	}
+	return fmt.Errorf("%s: %v", step, err)
+}
+
+// convertWithJava конвертирует JFR с помощью jfr-converter.jar.
+// Конвертация идет в workDir, результат перемещается в outputBase + расширение, выбранное конвертером.
+func convertWithJava(ctx context.Context, jfrPath, workDir, format, outputBase string, options []string) (string, error) {
+	// Копируем JFR файл в рабочую папку для конвертации; сжатый JFR распаковывается
+	localTempFile := filepath.Join(workDir, filepath.Base(recordingBase(jfrPath))+".jfr")
+	data, err := readJFRData(jfrPath)
+	if err == nil {
+		err = os.WriteFile(localTempFile, data, 0644)
+	}
+	if err != nil {
+		return "", fmt.Errorf("preparing for conversion: %v", err)
+	}
+	defer os.Remove(localTempFile) // Удаляем временный файл
+
+	// Запускаем конвертер
+	args := append([]string{"-jar", javaConverterPath, "-o", format}, options...)
+	convertCmd := exec.CommandContext(ctx, "java", append(args, localTempFile)...)
+
+	// Устанавливаем атрибуты процесса для скрытия окна терминала (Windows)
+	setSysProcAttr(convertCmd)
+
+	// Захватываем stderr для подробной информации об ошибках
+	var stderr bytes.Buffer
+	convertCmd.Stderr = &stderr
+
+	if err := convertCmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("converting JFR: timed out")
+		}
+		errMsg := fmt.Sprintf("converting JFR: %v", err)
+		if stderr.Len() > 0 {
+			errMsg += fmt.Sprintf(" | Details: %s", stderr.String())
+		}
+		return "", fmt.Errorf("%s", errMsg)
+	}
+
+	// jfr-converter создает файл с тем же именем как входной, но с другим расширением
+	// Ищем любой файл с базовым именем JFR файла
+	baseNameWithoutExt := strings.TrimSuffix(localTempFile, ".jfr")
+	files, err := filepath.Glob(baseNameWithoutExt + ".*")
+	if err != nil || len(files) == 0 {
+		return "", fmt.Errorf("locating converted file: not found")
+	}
+
+	// Находим файл который НЕ JFR (созданный конвертером)
+	var convertedFile string
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".jfr") {
+			convertedFile = file
+			break
+		}
+	}
+	if convertedFile == "" {
+		return "", fmt.Errorf("locating converted file: only JFR found")
+	}
+
+	// Создаем правильное имя для выходного файла
+	finalOutputPath := outputBase + filepath.Ext(convertedFile)
+	if err := moveFile(convertedFile, finalOutputPath); err != nil {
+		return "", fmt.Errorf("moving converted file: %v", err)
+	}
+	return finalOutputPath, nil
+}
+
+// Функция для копирования файла
+func copyFile(src, dst string) error {
+	input, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, input, 0644)
+}
+
+// moveFile перемещает файл, в том числе между дисками, где os.Rename не работает
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// RetentionSettings правила хранения записей из истории. 0 - правило выключено.
+// Хранятся в ~/.k8s-jprof/retention.json; файл с выключенными правилами создается при первом запуске.
+// Правила касаются только записей, сделанных приложением, а не остальных файлов в папке
+type RetentionSettings struct {
+	MaxAgeDays        int   `json:"max_age_days"`         // удалять записи старше N дней
+	MaxTotalSizeMB    int64 `json:"max_total_size_mb"`    // удалять самые старые записи сверх общего размера
+	KeepLastPerTarget int   `json:"keep_last_per_target"` // хранить последние N записей каждого пода
+	CompressAfterDays int   `json:"compress_after_days"`  // сжимать JFR старше N дней в .jfr.gz
+	Auto              bool  `json:"auto"`                 // применять правила после каждой записи
+}
+
+func getRetentionFilePath() string {
+	return filepath.Join(getConfigDir(), "retention.json")
+}
+
+// loadRetentionSettings читает retention.json. Отрицательные значения выключают правило
+func loadRetentionSettings() RetentionSettings {
+	var r RetentionSettings
+	path := getRetentionFilePath()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Создаем файл, чтобы правила было видно и можно было поправить
+		if err := writeRetentionSettings(path, r); err != nil {
+			log.Printf("Warning: failed to write %s: %v", path, err)
+		}
+		return r
+	}
+	if err != nil {
+		log.Printf("Warning: failed to read %s: %v", path, err)
+		return r
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		log.Printf("Warning: invalid %s: %v", path, err)
+		return RetentionSettings{}
+	}
+	if r.MaxAgeDays < 0 || r.MaxTotalSizeMB < 0 || r.KeepLastPerTarget < 0 || r.CompressAfterDays < 0 {
+		log.Printf("Warning: negative values in %s are treated as disabled rules", path)
+	}
+	return r
+}
+
+func writeRetentionSettings(path string, r RetentionSettings) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// enabled включено ли хотя бы одно правило
+func (r RetentionSettings) enabled() bool {
+	return r.MaxAgeDays > 0 || r.MaxTotalSizeMB > 0 || r.KeepLastPerTarget > 0 || r.CompressAfterDays > 0
+}
+
+// retentionAction что правила хранения сделают с записью
+type retentionAction struct {
+	entry    HistoryEntry
+	compress bool // сжать JFR; иначе удалить запись с файлами
+	reason   string
+	size     int64 // размер файлов записи на момент планирования
+}
+
+// planRetention действия по правилам хранения, без изменений на диске.
+// Записи просматриваются от новых к старым: лимиты по количеству и размеру оставляют самые свежие
+func planRetention(entries []HistoryEntry, r RetentionSettings, now time.Time) []retentionAction {
+	sorted := append([]HistoryEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.After(sorted[j].Time) })
+
+	var actions []retentionAction
+	perTarget := map[string]int{}
+	var totalSize int64
+	for _, e := range sorted {
+		age := now.Sub(e.Time)
+		saved := e.Outcome != historyOutcomeFailed
+
+		size := e.diskSize()
+		reason := ""
+		switch {
+		case r.MaxAgeDays > 0 && age > time.Duration(r.MaxAgeDays)*24*time.Hour:
+			reason = fmt.Sprintf("older than %d days", r.MaxAgeDays)
+		case saved && r.KeepLastPerTarget > 0 && perTarget[e.target()] >= r.KeepLastPerTarget:
+			reason = fmt.Sprintf("more than %d recordings of this pod", r.KeepLastPerTarget)
+		case saved && r.MaxTotalSizeMB > 0 && totalSize+size > r.MaxTotalSizeMB<<20:
+			reason = fmt.Sprintf("total size over %d MB", r.MaxTotalSizeMB)
+		}
+		if reason != "" {
+			actions = append(actions, retentionAction{entry: e, reason: reason, size: size})
+			continue
+		}
+		if !saved {
+			continue
+		}
+
+		perTarget[e.target()]++
+		totalSize += size
+		if r.CompressAfterDays > 0 && age > time.Duration(r.CompressAfterDays)*24*time.Hour && filepath.Ext(e.JFRPath) == ".jfr" {
+			if _, err := os.Stat(e.JFRPath); err == nil {
+				actions = append(actions, retentionAction{entry: e, compress: true, reason: fmt.Sprintf("older than %d days", r.CompressAfterDays), size: size})
+			}
+		}
+	}
+	return actions
+}
+
+// retentionResult итог применения правил
+type retentionResult struct {
+	deleted    int
+	compressed int
+	freed      int64
+	errors     []string
+}
+
+func (r retentionResult) String() string {
+	message := fmt.Sprintf("Deleted %d recordings, compressed %d, freed %s", r.deleted, r.compressed, formatSize(r.freed))
+	if len(r.errors) > 0 {
+		message += " | Error: " + strings.Join(r.errors, "; ")
+	}
+	return message
+}
+
+// applyRetention выполняет действия: удаляет записи с файлами и сжимает старые JFR
+func applyRetention(actions []retentionAction) retentionResult {
+	var result retentionResult
+	removed := map[string]bool{}
+	compressed := map[string]string{} // ID записи -> путь сжатого JFR
+	for _, action := range actions {
+		e := action.entry
+		size := e.diskSize()
+		if action.compress {
+			path, err := compressJFR(e.JFRPath, e.MetaPath)
+			if err != nil {
+				result.errors = append(result.errors, fmt.Sprintf("compressing %s: %v", filepath.Base(e.JFRPath), err))
+				continue
+			}
+			compressed[e.ID] = path
+			e.JFRPath = path
+			result.compressed++
+			result.freed += size - e.diskSize()
+			continue
+		}
+		if err := removeRecordingFiles(e); err != nil {
+			result.errors = append(result.errors, err.Error())
+			continue
+		}
+		removed[e.ID] = true
+		result.deleted++
+		result.freed += size
+	}
+
+	if err := updateHistory(func(entries []HistoryEntry) []HistoryEntry {
+		kept := entries[:0]
+		for _, e := range entries {
+			if removed[e.ID] {
+				continue
+			}
+			if path, ok := compressed[e.ID]; ok {
+				e.JFRPath = path
+				e.Size = e.diskSize()
+			}
+			kept = append(kept, e)
+		}
+		return kept
+	}); err != nil {
+		result.errors = append(result.errors, err.Error())
+	}
+	return result
+}
+
+// compressJFR сжимает JFR в .jfr.gz рядом с ним, удаляет исходный файл и обновляет имя в sidecar
+func compressJFR(jfrPath, metaPath string) (string, error) {
+	input, err := os.Open(jfrPath)
+	if err != nil {
+		return "", err
+	}
+	defer input.Close()
+
+	gzPath := jfrPath + ".gz"
+	if err := writeFileAtomically(gzPath, func(file *os.File) error {
+		writer := gzip.NewWriter(file)
+		if _, err := io.Copy(writer, input); err != nil {
+			return err
+		}
+		return writer.Close()
+	}); err != nil {
+		return "", err
+	}
+	input.Close()
+	if err := os.Remove(jfrPath); err != nil {
+		return "", err
+	}
+
+	if metaPath != "" {
+		if err := renameMetadataFile(metaPath, filepath.Base(jfrPath), filepath.Base(gzPath)); err != nil {
+			log.Printf("Не удалось обновить метаданные записи: %v", err)
+		}
+	}
+	return gzPath, nil
+}
+
+// renameMetadataFile заменяет имя файла в списке файлов sidecar
+func renameMetadataFile(metaPath, oldName, newName string) error {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return err
+	}
+	var meta RecordingMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	for i := range meta.Files {
+		if meta.Files[i] == oldName {
+			meta.Files[i] = newName
+		}
+	}
+	return writeRecordingMetadata(metaPath, meta)
+}
+
+// retentionAppliedEvent правила хранения применены в фоне
+type retentionAppliedEvent struct {
+	result retentionResult
+}
+
+func (e retentionAppliedEvent) apply(a *Application) {
+	f := a.historyPanel.retention
+	f.applying = false
+	f.plan = nil
+	f.message = e.result.String()
+	a.historyPanel.reload()
+}
+
+// applyRetentionAfterRecording применяет правила, если они включены для каждой записи.
+// Вызывается из фоновой горутины записи после добавления записи в историю
+func applyRetentionAfterRecording(settings RetentionSettings) {
+	if !settings.Auto || !settings.enabled() {
+		return
+	}
+	entries, err := readHistoryFile(getHistoryFilePath())
+	if err != nil {
+		log.Printf("Правила хранения: %v", err)
+		return
+	}
+	log.Printf("Правила хранения: %s", applyRetention(planRetention(entries, settings, time.Now())))
+}
+
+// handleRetentionClicks обрабатывает кнопки раздела правил хранения
+func (a *Application) handleRetentionClicks(gtx layout.Context) {
+	p := a.historyPanel
+	f := p.retention
+	for f.toggleButton.Clicked(gtx) {
+		f.expanded = !f.expanded
+		if f.expanded {
+			f.set(a.retention)
+			f.plan = nil
+			f.previewed = false
+			f.message = ""
+		}
+	}
+	if f.auto.Update(gtx) {
+		a.saveRetention()
+	}
+	for f.previewButton.Clicked(gtx) {
+		if f.applying || !a.saveRetention() {
+			continue
+		}
+		f.plan = planRetention(p.entries, a.retention, time.Now())
+		f.previewed = true
+		var deletes, compresses int
+		for _, action := range f.plan {
+			if action.compress {
+				compresses++
+			} else {
+				deletes++
+			}
+		}
+		if len(f.plan) == 0 {
+			f.message = "Nothing to clean up"
+		} else {
+			f.message = fmt.Sprintf("Preview: %d recordings would be deleted, %d compressed", deletes, compresses)
+		}
+	}
+	for f.applyButton.Clicked(gtx) {
+		if !f.previewed || f.applying || len(f.plan) == 0 {
+			continue
+		}
+		f.applying = true
+		plan := f.plan
+		go func() {
+			a.post(retentionAppliedEvent{result: applyRetention(plan)})
+		}()
+	}
+}
+
+// RetentionForm раздел правил хранения в окне истории: правила, предпросмотр и применение
+type RetentionForm struct {
+	expanded      bool
+	toggleButton  widget.Clickable
+	maxAgeEditor  widget.Editor
+	maxSizeEditor widget.Editor
+	keepEditor    widget.Editor
+	compressEdit  widget.Editor
+	auto          widget.Bool
+	previewButton widget.Clickable
+	applyButton   widget.Clickable
+
+	plan      []retentionAction // действия последнего предпросмотра; Apply выполняет именно их
+	previewed bool
+	applying  bool
+	message   string
+}
+
+func NewRetentionForm() *RetentionForm {
+	f := &RetentionForm{}
+	for _, editor := range []*widget.Editor{&f.maxAgeEditor, &f.maxSizeEditor, &f.keepEditor, &f.compressEdit} {
+		editor.SingleLine = true
+	}
+	return f
+}
+
+// set заполняет форму из настроек
+func (f *RetentionForm) set(r RetentionSettings) {
+	number := func(v int64) string {
+		if v <= 0 {
+			return ""
+		}
+		return strconv.FormatInt(v, 10)
+	}
+	f.maxAgeEditor.SetText(number(int64(r.MaxAgeDays)))
+	f.maxSizeEditor.SetText(number(r.MaxTotalSizeMB))
+	f.keepEditor.SetText(number(int64(r.KeepLastPerTarget)))
+	f.compressEdit.SetText(number(int64(r.CompressAfterDays)))
+	f.auto.Value = r.Auto
+}
+
+// settings настройки из полей формы; пустое поле выключает правило
+func (f *RetentionForm) settings() (RetentionSettings, error) {
+	var r RetentionSettings
+	parse := func(name string, editor *widget.Editor) (int64, error) {
+		text := strings.TrimSpace(editor.Text())
+		if text == "" {
+			return 0, nil
+		}
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid %s %q", name, text)
+		}
+		return v, nil
+	}
+	var err error
+	var v int64
+	if v, err = parse("max age", &f.maxAgeEditor); err != nil {
+		return r, err
+	}
+	r.MaxAgeDays = int(v)
+	if r.MaxTotalSizeMB, err = parse("max total size", &f.maxSizeEditor); err != nil {
+		return r, err
+	}
+	if v, err = parse("keep last", &f.keepEditor); err != nil {
+		return r, err
+	}
+	r.KeepLastPerTarget = int(v)
+	if v, err = parse("compress after", &f.compressEdit); err != nil {
+		return r, err
+	}
+	r.CompressAfterDays = int(v)
+	r.Auto = f.auto.Value
+	return r, nil
+}
+
+// saveRetention сохраняет правила из формы; false - в форме ошибка
+func (a *Application) saveRetention() bool {
+	f := a.historyPanel.retention
+	settings, err := f.settings()
+	if err != nil {
+		f.message = "Error: " + err.Error()
+		return false
+	}
+	a.retention = settings
+	if err := writeRetentionSettings(getRetentionFilePath(), settings); err != nil {
+		f.message = "Error: " + err.Error()
+		return false
+	}
+	return true
+}
+
+// retentionRows строки раздела для списка окна истории
+func (a *Application) retentionRows(th *material.Theme) []layout.Widget {
+	p := a.historyPanel
+	f := p.retention
+
+	if !f.expanded {
+		return nil
+	}
+
+	editor := func(label string, e *widget.Editor, hint string) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Right: unit.Dp(12)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(material.Label(th, unit.Sp(13), label).Layout),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						gtx.Constraints.Min.X = gtx.Dp(unit.Dp(60))
+						gtx.Constraints.Max.X = gtx.Constraints.Min.X
+						return layout.Background{}.Layout(gtx,
+							func(gtx layout.Context) layout.Dimensions {
+								defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+								paint.Fill(gtx.Ops, color.NRGBA{R: 240, G: 240, B: 240, A: 255})
+								return layout.Dimensions{Size: gtx.Constraints.Min}
+							},
+							func(gtx layout.Context) layout.Dimensions {
+								return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+									ed := material.Editor(th, e, hint)
+									ed.TextSize = unit.Sp(13)
+									ed.HintColor = color.NRGBA{R: 150, G: 150, B: 150, A: 255}
+									return ed.Layout(gtx)
+								})
+							},
+						)
+					}),
+				)
+			})
+		})
+	}
+	button := func(click *widget.Clickable, label string, enabled bool) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				if enabled && click.Hovered() {
+					pointer.CursorPointer.Add(gtx.Ops)
+				}
+				btn := material.Button(th, click, label)
+				btn.TextSize = unit.Sp(12)
+				btn.Inset = layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)}
+				if enabled {
+					btn.Background = color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+					btn.Color = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+				} else {
+					btn.Background = color.NRGBA{R: 200, G: 200, B: 200, A: 255}
+					btn.Color = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+				}
+				return btn.Layout(gtx)
+			})
+		})
+	}
+
+	rows := []layout.Widget{
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				editor("Max age, days: ", &f.maxAgeEditor, "off"),
+				editor("Max size, MB: ", &f.maxSizeEditor, "off"),
+				editor("Keep last per pod: ", &f.keepEditor, "off"),
+				editor("Compress after, days: ", &f.compressEdit, "off"),
+			)
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			applyLabel := "Apply"
+			if f.applying {
+				applyLabel = "Applying..."
+			}
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				button(&f.previewButton, "Preview", !f.applying),
+				button(&f.applyButton, applyLabel, f.previewed && !f.applying && len(f.plan) > 0),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return material.CheckBox(th, &f.auto, "Apply after each recording").Layout(gtx)
+				}),
+			)
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			if f.message == "" {
+				return layout.Dimensions{}
+			}
+			label := material.Label(th, unit.Sp(12), f.message)
+			if strings.Contains(f.message, "Error") {
+				label.Color = color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+			} else {
+				label.Color = color.NRGBA{R: 50, G: 150, B: 50, A: 255}
+			}
+			return label.Layout(gtx)
+		},
+	}
+	for _, action := range f.plan {
+		action := action
+		rows = append(rows, func(gtx layout.Context) layout.Dimensions {
+			verb := "Delete"
+			textColor := color.NRGBA{R: 200, G: 50, B: 50, A: 255}
+			if action.compress {
+				verb = "Compress"
+				textColor = color.NRGBA{R: 200, G: 120, B: 0, A: 255}
+			}
+			line := fmt.Sprintf("%s %s, %s (%s): %s", verb, action.entry.target(),
+				action.entry.Time.Local().Format("2006-01-02 15:04"), formatSize(action.size), action.reason)
+			label := material.Label(th, unit.Sp(12), line)
+			label.Color = textColor
+			return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, label.Layout)
+		})
+	}
+	return rows
+}
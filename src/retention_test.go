@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// retentionNow момент планирования, от которого отсчитывается возраст записей
+var retentionNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// retentionEntry запись истории с JFR заданного размера во временной папке
+func retentionEntry(t *testing.T, dir, id, pod string, age time.Duration, size int64, outcome string) HistoryEntry {
+	t.Helper()
+	e := HistoryEntry{
+		ID:         id,
+		Time:       retentionNow.Add(-age),
+		Kubeconfig: "config",
+		Namespace:  "default",
+		Pod:        pod,
+		Outcome:    outcome,
+	}
+	if outcome == historyOutcomeFailed {
+		return e
+	}
+	e.JFRPath = filepath.Join(dir, id+".jfr")
+	file, err := os.Create(e.JFRPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := file.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func formatRetentionPlan(actions []retentionAction) []string {
+	var lines []string
+	for _, a := range actions {
+		verb := "delete"
+		if a.compress {
+			verb = "compress"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s) %d", verb, a.entry.ID, a.reason, a.size))
+	}
+	return lines
+}
+
+func TestPlanRetention(t *testing.T) {
+	const day = 24 * time.Hour
+	const mb = 1 << 20
+
+	tests := []struct {
+		name     string
+		settings RetentionSettings
+		entries  func(t *testing.T, dir string) []HistoryEntry
+		want     []string
+	}{
+		{
+			name: "rules disabled",
+			entries: func(t *testing.T, dir string) []HistoryEntry {
+				return []HistoryEntry{retentionEntry(t, dir, "old", "api", 400*day, 10, historyOutcomeOK)}
+			},
+		},
+		{
+			name:     "max age",
+			settings: RetentionSettings{MaxAgeDays: 30},
+			entries: func(t *testing.T, dir string) []HistoryEntry {
+				return []HistoryEntry{
+					retentionEntry(t, dir, "fresh", "api", 29*day, 10, historyOutcomeOK),
+					retentionEntry(t, dir, "old", "api", 31*day, 20, historyOutcomeOK),
+					retentionEntry(t, dir, "old-failed", "api", 31*day, 0, historyOutcomeFailed),
+				}
+			},
+			want: []string{
+				"delete old (older than 30 days) 20",
+				"delete old-failed (older than 30 days) 0",
+			},
+		},
+		{
+			// Неудачные записи не занимают место в лимите на под
+			name:     "keep last per target",
+			settings: RetentionSettings{KeepLastPerTarget: 2},
+			entries: func(t *testing.T, dir string) []HistoryEntry {
+				return []HistoryEntry{
+					retentionEntry(t, dir, "api-3", "api", 3*day, 10, historyOutcomeOK),
+					retentionEntry(t, dir, "api-1", "api", 1*day, 10, historyOutcomeOK),
+					retentionEntry(t, dir, "api-failed", "api", 0, 0, historyOutcomeFailed),
+					retentionEntry(t, dir, "api-2", "api", 2*day, 10, historyOutcomePartial),
+					retentionEntry(t, dir, "db-3", "db", 3*day, 10, historyOutcomeOK),
+				}
+			},
+			want: []string{"delete api-3 (more than 2 recordings of this pod) 10"},
+		},
+		{
+			name:     "max total size",
+			settings: RetentionSettings{MaxTotalSizeMB: 2},
+			entries: func(t *testing.T, dir string) []HistoryEntry {
+				return []HistoryEntry{
+					retentionEntry(t, dir, "newest", "api", 1*day, mb, historyOutcomeOK),
+					retentionEntry(t, dir, "middle", "db", 2*day, mb/2, historyOutcomeOK),
+					retentionEntry(t, dir, "older", "api", 3*day, mb, historyOutcomeOK),
+					retentionEntry(t, dir, "oldest", "db", 4*day, mb/2, historyOutcomeOK),
+				}
+			},
+			want: []string{"delete older (total size over 2 MB) 1048576"},
+		},
+		{
+			// Сжатые и пропавшие с диска JFR повторно не сжимаются
+			name:     "compress old JFR",
+			settings: RetentionSettings{CompressAfterDays: 7, MaxAgeDays: 30},
+			entries: func(t *testing.T, dir string) []HistoryEntry {
+				compressed := retentionEntry(t, dir, "compressed", "api", 10*day, 10, historyOutcomeOK)
+				if err := os.Rename(compressed.JFRPath, compressed.JFRPath+".gz"); err != nil {
+					t.Fatal(err)
+				}
+				compressed.JFRPath += ".gz"
+				missing := retentionEntry(t, dir, "missing", "api", 10*day, 10, historyOutcomeOK)
+				if err := os.Remove(missing.JFRPath); err != nil {
+					t.Fatal(err)
+				}
+				return []HistoryEntry{
+					retentionEntry(t, dir, "week", "api", 6*day, 10, historyOutcomeOK),
+					retentionEntry(t, dir, "old", "api", 8*day, 30, historyOutcomeOK),
+					compressed,
+					missing,
+					retentionEntry(t, dir, "expired", "api", 40*day, 40, historyOutcomeOK),
+				}
+			},
+			want: []string{
+				"compress old (older than 7 days) 30",
+				"delete expired (older than 30 days) 40",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			entries := tt.entries(t, dir)
+			before, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actions := planRetention(entries, tt.settings, retentionNow)
+			if got := formatRetentionPlan(actions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan =\n%q\nwant\n%q", got, tt.want)
+			}
+
+			// Планирование ничего не меняет на диске
+			after, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(after) != len(before) {
+				t.Errorf("dry run changed the folder: %d files before, %d after", len(before), len(after))
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Имена файлов настроек, которые запоминаются отдельно для каждого kubeconfig и namespace
+const (
+	asprofArgsSettingName = "asprof_args.mem"
+	formatSettingName     = "convert_format.mem"
+	folderSettingName     = "jfr_folder.mem"
+	namespaceSettingName  = "namespace.mem"
+	podSettingName        = "pod.mem"
+
+	usedNamespacesSettingName = "used_namespaces.mem"
+)
+
+// maxUsedNamespaces сколько успешно использованных namespaces запоминается для kubeconfig
+const maxUsedNamespaces = 20
+
+// getScopesDir возвращает корневую папку для настроек, привязанных к кластеру/namespace
+func getScopesDir() string {
+	return filepath.Join(getConfigDir(), "scopes")
+}
+
+// scopeDirName превращает имя kubeconfig или namespace в безопасное имя папки
+func scopeDirName(name string) string {
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+	safe := replacer.Replace(strings.TrimSpace(name))
+	if safe == "" || safe == "." || safe == ".." {
+		safe = "_"
+	}
+	return safe
+}
+
+// kubeconfigContextCache текущие контексты kubeconfig. kubectl запускается заново,
+// только если файл изменился, например после "kubectl config use-context"
+var kubeconfigContextCache = struct {
+	sync.Mutex
+	entries map[string]kubeconfigContextEntry
+}{entries: map[string]kubeconfigContextEntry{}}
+
+type kubeconfigContextEntry struct {
+	modTime time.Time
+	size    int64
+	context string
+}
+
+// kubeconfigCurrentContext текущий контекст kubeconfig из "kubectl config view --minify -o json".
+// Пустая строка - kubeconfig не читается или контекст не задан
+func kubeconfigCurrentContext(kubeconfig string) string {
+	path := kubeconfigPath(kubeconfig)
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	kubeconfigContextCache.Lock()
+	entry, ok := kubeconfigContextCache.entries[path]
+	kubeconfigContextCache.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.context
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadOperationTimeouts().List)
+	output, err := runKubectlOutput(ctx, "reading kubeconfig context", path, "config", "view", "--minify", "-o", "json")
+	cancel()
+	var meta RecordingMetadata
+	if err != nil {
+		log.Printf("Warning: failed to read the current context of %s: %v", kubeconfig, err)
+	} else {
+		parseKubeconfigMetadata(&meta, output)
+	}
+
+	kubeconfigContextCache.Lock()
+	kubeconfigContextCache.entries[path] = kubeconfigContextEntry{modTime: info.ModTime(), size: info.Size(), context: meta.Context}
+	kubeconfigContextCache.Unlock()
+	return meta.Context
+}
+
+// kubeconfigScopeName имя папки kubeconfig: файл и текущий контекст, чтобы настройки
+// не смешивались при переключении контекста внутри одного файла
+func kubeconfigScopeName(kubeconfig string) string {
+	name := scopeDirName(kubeconfig)
+	if contextName := kubeconfigCurrentContext(kubeconfig); contextName != "" {
+		name += "@" + scopeDirName(contextName)
+	}
+	return name
+}
+
+// getScopeDir возвращает папку настроек для kubeconfig с его текущим контекстом (и namespace, если он указан)
+func getScopeDir(kubeconfig, namespace string) string {
+	dir := filepath.Join(getScopesDir(), kubeconfigScopeName(kubeconfig))
+	if namespace != "" {
+		dir = filepath.Join(dir, scopeDirName(namespace))
+	}
+	return dir
+}
+
+// loadScopedSetting ищет значение сначала для namespace, затем для kubeconfig.
+// Глобальные *.mem файлы сюда не входят - они остаются запасным вариантом у вызывающего кода.
+func loadScopedSetting(name, kubeconfig, namespace string) (string, bool) {
+	if kubeconfig == "" {
+		return "", false
+	}
+
+	scopes := []string{}
+	if namespace != "" {
+		scopes = append(scopes, getScopeDir(kubeconfig, namespace))
+	}
+	scopes = append(scopes, getScopeDir(kubeconfig, ""))
+
+	for _, dir := range scopes {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	return "", false
+}
+
+// saveScopedSetting запоминает значение для kubeconfig и, если выбран, для namespace.
+// Значение уровня kubeconfig служит значением по умолчанию для еще не использованных namespaces.
+func saveScopedSetting(name, value, kubeconfig, namespace string) {
+	if kubeconfig == "" {
+		return
+	}
+
+	dirs := []string{getScopeDir(kubeconfig, "")}
+	if namespace != "" {
+		dirs = append(dirs, getScopeDir(kubeconfig, namespace))
+	}
+
+	for _, dir := range dirs {
+		writeScopedSetting(dir, name, value)
+	}
+}
+
+func writeScopedSetting(dir, name, value string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Warning: failed to create %s: %v", dir, err)
+		return
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(value), 0644); err != nil {
+		log.Printf("Warning: failed to write %s: %v", path, err)
+	}
+}
+
+// rememberSetting сохраняет значение для текущих kubeconfig/namespace
+func (a *Application) rememberSetting(name, value string) {
+	if a.kubeconfigSelector == nil || a.namespaceSelector == nil {
+		return
+	}
+	saveScopedSetting(name, value, a.kubeconfigSelector.GetSelectedConfig(), a.namespaceSelector.GetSelectedNamespace())
+}
+
+// restoreScopedSettings применяет аргументы, формат с опциями конвертации и папку, запомненные для текущих kubeconfig/namespace.
+// Если для области ничего не запомнено, берется глобальное значение из *.mem или значение по умолчанию,
+// чтобы не оставались настройки предыдущего кластера
+func (a *Application) restoreScopedSettings() {
+	if a.kubeconfigSelector == nil || a.namespaceSelector == nil || a.formatSelector == nil {
+		return
+	}
+	selectedConfig := a.kubeconfigSelector.GetSelectedConfig()
+	selectedNamespace := a.namespaceSelector.GetSelectedNamespace()
+
+	if args, ok := loadScopedSetting(asprofArgsSettingName, selectedConfig, selectedNamespace); ok && args != "" {
+		a.asprofArgs = args
+		a.asprofArgsEditor.SetText(args)
+	} else {
+		a.loadAsprofArgs()
+	}
+
+	if formats, ok := loadScopedSetting(formatSettingName, selectedConfig, selectedNamespace); !ok || !a.formatSelector.SetSelection(formats) {
+		a.formatSelector.loadSelection()
+	}
+
+	opts := loadConvertOptions()
+	if value, ok := loadScopedSetting(convertOptionsSettingName, selectedConfig, selectedNamespace); ok {
+		if scoped, ok := decodeConvertOptions(value); ok {
+			opts = scoped
+		}
+	}
+	a.convertOptions = opts
+	if a.convertOptionsForm != nil {
+		a.convertOptionsForm.set(opts)
+	}
+
+	if folder, ok := loadScopedSetting(folderSettingName, selectedConfig, selectedNamespace); ok && folder != "" {
+		a.selectedFolder = folder
+	} else {
+		a.loadSelectedFolder()
+	}
+}
+
+// restoreScopedNamespace возвращает namespace, последним использованный с этим kubeconfig
+func restoreScopedNamespace(kubeconfig string) string {
+	namespace, _ := loadScopedSetting(namespaceSettingName, kubeconfig, "")
+	return namespace
+}
+
+// saveScopedNamespace запоминает последний namespace для kubeconfig
+func saveScopedNamespace(kubeconfig, namespace string) {
+	if kubeconfig == "" || namespace == "" {
+		return
+	}
+	writeScopedSetting(getScopeDir(kubeconfig, ""), namespaceSettingName, namespace)
+}
+
+// loadUsedNamespaces возвращает namespaces, в которых пользователь успешно получал поды (последние - первыми)
+func loadUsedNamespaces(kubeconfig string) []string {
+	value, _ := loadScopedSetting(usedNamespacesSettingName, kubeconfig, "")
+	return strings.Fields(value)
+}
+
+// rememberUsedNamespace добавляет namespace в начало списка использованных для kubeconfig
+func rememberUsedNamespace(kubeconfig, namespace string) {
+	if kubeconfig == "" || namespace == "" {
+		return
+	}
+	used := []string{namespace}
+	for _, name := range loadUsedNamespaces(kubeconfig) {
+		if name != namespace && len(used) < maxUsedNamespaces {
+			used = append(used, name)
+		}
+	}
+	writeScopedSetting(getScopeDir(kubeconfig, ""), usedNamespacesSettingName, strings.Join(used, "\n"))
+}
+
+// saveScopedPod запоминает последний pod для namespace
+func saveScopedPod(kubeconfig, namespace, pod string) {
+	if kubeconfig == "" || namespace == "" {
+		return
+	}
+	writeScopedSetting(getScopeDir(kubeconfig, namespace), podSettingName, pod)
+}
+
+// restoreScopedPod возвращает pod, последним использованный в этом namespace
+func restoreScopedPod(kubeconfig, namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	// pod.mem пишется только на уровне namespace, поэтому уровень kubeconfig ничего не найдет
+	pod, _ := loadScopedSetting(podSettingName, kubeconfig, namespace)
+	return pod
+}
+
+// restoreStartupScope подменяет глобально сохраненные namespace/pod и настройки
+// запомненными для выбранного при запуске kubeconfig
+func (a *Application) restoreStartupScope() {
+	selectedConfig := a.kubeconfigSelector.GetSelectedConfig()
+	if selectedConfig == "" {
+		return
+	}
+	if namespace := restoreScopedNamespace(selectedConfig); namespace != "" {
+		a.namespaceSelector.selectedNamespace = namespace
+		if pod := restoreScopedPod(selectedConfig, namespace); pod != "" {
+			a.podSelector.selectedPod = pod
+		}
+	}
+	a.restoreScopedSettings()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"gioui.org/widget"
+)
+
+// appEvent изменение состояния приложения, подготовленное фоновой горутиной.
+// Все события применяются в UI-горутине между кадрами, поэтому поля Application
+// и селекторов меняются только из одной горутины.
+type appEvent interface {
+	apply(a *Application)
+}
+
+// eventQueue очередь событий от фоновых горутин
+type eventQueue struct {
+	mu     sync.Mutex
+	events []appEvent
+}
+
+// post ставит событие в очередь и запрашивает перерисовку. Безопасно вызывать из любой горутины
+func (a *Application) post(event appEvent) {
+	a.events.mu.Lock()
+	a.events.events = append(a.events.events, event)
+	invalidate := a.invalidate
+	a.events.mu.Unlock()
+
+	if invalidate != nil {
+		invalidate()
+	}
+}
+
+// applyPendingEvents применяет накопившиеся события. Вызывается только из UI-горутины перед кадром
+func (a *Application) applyPendingEvents() {
+	a.events.mu.Lock()
+	events := a.events.events
+	a.events.events = nil
+	a.events.mu.Unlock()
+
+	for _, event := range events {
+		event.apply(a)
+	}
+}
+
+// setInvalidate задает функцию перерисовки окна
+func (a *Application) setInvalidate(invalidate func()) {
+	a.events.mu.Lock()
+	a.invalidate = invalidate
+	a.events.mu.Unlock()
+}
+
+// namespacesLoadedEvent список namespaces для kubeconfig получен
+type namespacesLoadedEvent struct {
+	kubeconfig string
+	generation uint64 // номер запроса, см. NamespaceSelector.loadGeneration
+	namespaces []string
+	restricted bool // список построен без права list namespaces: первым идет namespace контекста
+}
+
+func (e namespacesLoadedEvent) apply(a *Application) {
+	ns := a.namespaceSelector
+
+	// Ответ на устаревший запрос: уже выполняется или выполнен более новый
+	if e.generation != ns.loadGeneration || e.kubeconfig != a.kubeconfigSelector.GetSelectedConfig() {
+		return
+	}
+	ns.loading = false
+	ns.cancelLoad = nil
+
+	ns.namespaces = e.namespaces
+	ns.restricted = e.restricted
+	saved := ns.selectedNamespace
+	ns.selectedNamespace = ""
+
+	if e.restricted {
+		// Проверить существование нельзя: сохраненный (в том числе введенный вручную) namespace
+		// оставляем, иначе выбираем namespace контекста
+		switch {
+		case saved != "" && validateNamespaceName(saved) == nil:
+			ns.selectedNamespace = saved
+			if !ns.hasNamespace(saved) {
+				ns.namespaces = append(ns.namespaces, saved)
+			}
+		case len(ns.namespaces) > 0:
+			ns.selectedNamespace = ns.namespaces[0]
+		}
+	} else {
+		// Проверяем, что сохраненный namespace все еще существует
+		if ns.hasNamespace(saved) {
+			ns.selectedNamespace = saved
+		}
+	}
+	ns.filterNamespaces()
+
+	// Загружаем поды для восстановленного namespace
+	if ns.selectedNamespace != "" {
+		a.podSelector.LoadPods(e.kubeconfig, ns.selectedNamespace, a)
+	}
+}
+
+// podsLoadedEvent список подов для namespace получен
+type podsLoadedEvent struct {
+	kubeconfig string
+	namespace  string
+	generation uint64 // номер запроса, см. PodSelector.loadGeneration
+	pods       []string
+}
+
+func (e podsLoadedEvent) apply(a *Application) {
+	ps := a.podSelector
+
+	// Ответ на устаревший запрос: пользователь успел выбрать другой kubeconfig или namespace
+	if e.generation != ps.loadGeneration || e.kubeconfig != a.kubeconfigSelector.GetSelectedConfig() || e.namespace != a.namespaceSelector.GetSelectedNamespace() {
+		return
+	}
+	ps.loading = false
+	ps.cancelLoad = nil
+
+	ps.pods = e.pods
+	rememberUsedNamespace(e.kubeconfig, e.namespace) // namespace доступен - предложим его, если список namespaces закрыт
+	ps.filteredPods = make([]string, len(e.pods))
+	copy(ps.filteredPods, e.pods)
+	ps.clickables = make([]widget.Clickable, len(e.pods))
+
+	// Проверяем, что сохраненный pod все еще существует
+	saved := ps.selectedPod
+	ps.selectedPod = ""
+	for _, podName := range e.pods {
+		if podName == saved {
+			ps.selectedPod = saved
+			break
+		}
+	}
+}
+
+// loadFailedEvent загрузка namespaces или подов не удалась
+type loadFailedEvent struct {
+	action     string // "loadNamespaces" или "loadPods" - для кнопки Retry
+	generation uint64 // номер запроса в соответствующем селекторе
+	err        error
+}
+
+func (e loadFailedEvent) apply(a *Application) {
+	// Ошибка устаревшего запроса не должна показывать overlay поверх актуальных данных
+	switch e.action {
+	case "loadNamespaces":
+		if e.generation != a.namespaceSelector.loadGeneration {
+			return
+		}
+		a.namespaceSelector.loading = false
+		a.namespaceSelector.cancelLoad = nil
+	case "loadPods":
+		if e.generation != a.podSelector.loadGeneration {
+			return
+		}
+		a.podSelector.loading = false
+		a.podSelector.cancelLoad = nil
+	}
+
+	var clusterErr *ClusterError
+	if !errors.As(e.err, &clusterErr) {
+		clusterErr = &ClusterError{Kind: clusterErrorUnknown, Op: e.action, Err: e.err}
+	}
+	a.clusterError = clusterErr
+	a.showErrorDetails = false
+	a.lastFailedAction = e.action
+}
+
+// folderChosenEvent диалог выбора папки закрыт
+type folderChosenEvent struct {
+	folder string // пусто, если пользователь отменил выбор
+}
+
+func (e folderChosenEvent) apply(a *Application) {
+	if e.folder != "" {
+		a.selectedFolder = e.folder
+		a.saveSelectedFolder()
+		a.rememberSetting(folderSettingName, e.folder)
+	}
+	// Убираем занавес в любом случае
+	a.isChoosingFolder = false
+	a.choosingMessage = ""
+}
+
+// initStageEvent смена этапа первоначальной инициализации
+type initStageEvent struct {
+	message string
+}
+
+func (e initStageEvent) apply(a *Application) {
+	a.isClearing = false
+	a.isInitializing = true
+	a.initializationMessage = e.message
+}
+
+// initFailedEvent критическая ошибка инициализации
+type initFailedEvent struct {
+	message string
+}
+
+func (e initFailedEvent) apply(a *Application) {
+	a.hasError = true
+	a.errorMessage = e.message
+	a.isClearing = false
+	a.isInitializing = false
+}
+
+// initCompletedEvent зависимости загружены - можно создавать селекторы и загружать данные
+type initCompletedEvent struct{}
+
+func (e initCompletedEvent) apply(a *Application) {
+	a.loadSelectorsAndSettings()
+	a.isInitializing = false
+}
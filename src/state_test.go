@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// recordedEvent записывает свое имя при применении и может поставить следующее событие
+type recordedEvent struct {
+	applied *[]string
+	name    string
+	next    appEvent
+}
+
+func (e recordedEvent) apply(a *Application) {
+	*e.applied = append(*e.applied, e.name)
+	if e.next != nil {
+		a.post(e.next)
+	}
+}
+
+func TestEventQueue(t *testing.T) {
+	a := &Application{}
+	var invalidations atomic.Int32
+	a.setInvalidate(func() { invalidations.Add(1) })
+
+	var applied []string
+	const goroutines, perGoroutine = 8, 50
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				a.post(recordedEvent{applied: &applied, name: fmt.Sprintf("%d/%d", g, i)})
+			}
+		}(g)
+	}
+	wg.Wait()
+	if got := invalidations.Load(); got != goroutines*perGoroutine {
+		t.Errorf("invalidations = %d, want one per event (%d)", got, goroutines*perGoroutine)
+	}
+
+	a.applyPendingEvents()
+	if len(applied) != goroutines*perGoroutine {
+		t.Fatalf("applied %d events, want %d", len(applied), goroutines*perGoroutine)
+	}
+	// События одной горутины применяются в порядке отправки
+	next := map[int]int{}
+	for _, name := range applied {
+		var g, i int
+		if _, err := fmt.Sscanf(name, "%d/%d", &g, &i); err != nil {
+			t.Fatal(err)
+		}
+		if i != next[g] {
+			t.Fatalf("event %s applied out of order, want %d/%d", name, g, next[g])
+		}
+		next[g]++
+	}
+
+	applied = nil
+	a.applyPendingEvents()
+	if len(applied) != 0 {
+		t.Errorf("events applied twice: %q", applied)
+	}
+}
+
+func TestEventQueuePostFromApply(t *testing.T) {
+	a := &Application{}
+	var applied []string
+	a.post(recordedEvent{applied: &applied, name: "first", next: recordedEvent{applied: &applied, name: "second"}})
+
+	// Событие, отправленное во время применения, ждет следующего кадра
+	a.applyPendingEvents()
+	if want := []string{"first"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("first frame applied %q, want %q", applied, want)
+	}
+	a.applyPendingEvents()
+	if want := []string{"first", "second"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("second frame applied %q, want %q", applied, want)
+	}
+}
+
+// loadingApplication приложение с выбранным kubeconfig "dev" и выполняющимися запросами namespaces и подов
+func loadingApplication(t *testing.T) *Application {
+	t.Helper()
+	tempHome(t)
+	a := &Application{
+		kubeconfigSelector: &KubeconfigSelector{selectedConfig: "dev"},
+		namespaceSelector:  &NamespaceSelector{selectedNamespace: "shop", loading: true, loadGeneration: 2},
+		podSelector:        &PodSelector{selectedPod: "api-1", loading: true, loadGeneration: 5},
+	}
+	// Применение namespaces запускает загрузку подов; в тесте она не нужна
+	t.Cleanup(a.podSelector.CancelLoad)
+	return a
+}
+
+func TestNamespacesLoadedEventGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      namespacesLoadedEvent
+		applied    bool
+		wantSelect string
+	}{
+		{
+			name:  "superseded request",
+			event: namespacesLoadedEvent{kubeconfig: "dev", generation: 1, namespaces: []string{"shop"}},
+		},
+		{
+			name:  "another kubeconfig",
+			event: namespacesLoadedEvent{kubeconfig: "prod", generation: 2, namespaces: []string{"shop"}},
+		},
+		{
+			name:       "current request keeps the saved namespace",
+			event:      namespacesLoadedEvent{kubeconfig: "dev", generation: 2, namespaces: []string{"default", "shop"}},
+			applied:    true,
+			wantSelect: "shop",
+		},
+		{
+			name:    "current request drops a deleted namespace",
+			event:   namespacesLoadedEvent{kubeconfig: "dev", generation: 2, namespaces: []string{"default"}},
+			applied: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := loadingApplication(t)
+			ns := a.namespaceSelector
+			tt.event.apply(a)
+
+			if !tt.applied {
+				if !ns.loading || len(ns.namespaces) != 0 || ns.selectedNamespace != "shop" {
+					t.Errorf("stale result changed the selector: loading=%v namespaces=%q selected=%q", ns.loading, ns.namespaces, ns.selectedNamespace)
+				}
+				return
+			}
+			if ns.loading {
+				t.Error("loading was not reset")
+			}
+			if !reflect.DeepEqual(ns.namespaces, tt.event.namespaces) {
+				t.Errorf("namespaces = %q, want %q", ns.namespaces, tt.event.namespaces)
+			}
+			if ns.selectedNamespace != tt.wantSelect {
+				t.Errorf("selected = %q, want %q", ns.selectedNamespace, tt.wantSelect)
+			}
+			// Поды загружаются заново (новым запросом) только для восстановленного namespace
+			if reloaded := a.podSelector.loadGeneration != 5; reloaded != (tt.wantSelect != "") {
+				t.Errorf("pods reloaded = %v, want %v", reloaded, tt.wantSelect != "")
+			}
+		})
+	}
+}
+
+func TestPodsLoadedEventGeneration(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   podsLoadedEvent
+		applied bool
+		wantPod string
+	}{
+		{
+			name:  "superseded request",
+			event: podsLoadedEvent{kubeconfig: "dev", namespace: "shop", generation: 4, pods: []string{"api-1"}},
+		},
+		{
+			name:  "another kubeconfig",
+			event: podsLoadedEvent{kubeconfig: "prod", namespace: "shop", generation: 5, pods: []string{"api-1"}},
+		},
+		{
+			name:  "another namespace",
+			event: podsLoadedEvent{kubeconfig: "dev", namespace: "default", generation: 5, pods: []string{"api-1"}},
+		},
+		{
+			name:    "current request keeps the saved pod",
+			event:   podsLoadedEvent{kubeconfig: "dev", namespace: "shop", generation: 5, pods: []string{"api-1", "api-2"}},
+			applied: true,
+			wantPod: "api-1",
+		},
+		{
+			name:    "current request drops a deleted pod",
+			event:   podsLoadedEvent{kubeconfig: "dev", namespace: "shop", generation: 5, pods: []string{"api-2"}},
+			applied: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := loadingApplication(t)
+			ps := a.podSelector
+			tt.event.apply(a)
+
+			if !tt.applied {
+				if !ps.loading || len(ps.pods) != 0 || ps.selectedPod != "api-1" {
+					t.Errorf("stale result changed the selector: loading=%v pods=%q selected=%q", ps.loading, ps.pods, ps.selectedPod)
+				}
+				return
+			}
+			if ps.loading {
+				t.Error("loading was not reset")
+			}
+			if !reflect.DeepEqual(ps.pods, tt.event.pods) {
+				t.Errorf("pods = %q, want %q", ps.pods, tt.event.pods)
+			}
+			if ps.selectedPod != tt.wantPod {
+				t.Errorf("selected = %q, want %q", ps.selectedPod, tt.wantPod)
+			}
+		})
+	}
+}
+
+func TestLoadFailedEventGeneration(t *testing.T) {
+	a := loadingApplication(t)
+	loadFailedEvent{action: "loadPods", generation: 4}.apply(a)
+	if a.lastFailedAction != "" || !a.podSelector.loading {
+		t.Error("failure of a superseded request was reported")
+	}
+	loadFailedEvent{action: "loadPods", generation: 5}.apply(a)
+	if a.lastFailedAction != "loadPods" || a.podSelector.loading {
+		t.Errorf("failure of the current request: action=%q loading=%v", a.lastFailedAction, a.podSelector.loading)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// summaryTopN сколько методов и потоков попадает в сводку
+const summaryTopN = 10
+
+// Суффиксы файлов сводки рядом с записью: ns__pod__timestamp.summary.md
+const (
+	summaryMarkdownSuffix = ".summary.md"
+	summaryJSONSuffix     = ".summary.json"
+)
+
+// SummaryItem метод, поток или категория с долей сэмплов
+type SummaryItem struct {
+	Name    string  `json:"name"`
+	Samples int64   `json:"samples"`
+	Share   float64 `json:"share"` // доля от всех сэмплов, 0..1
+}
+
+// RecordingSummary сводка по записи: где тратится время и какая часть приходится на GC, JIT и native-код
+type RecordingSummary struct {
+	Event      string        `json:"event"`
+	Samples    int64         `json:"samples"`
+	Duration   time.Duration `json:"duration_ns,omitempty"` // от первого до последнего события
+	TopSelf    []SummaryItem `json:"top_self"`
+	TopTotal   []SummaryItem `json:"top_total"`
+	HotPath    []SummaryItem `json:"hot_path"` // от корня к листу: на каждом шаге самый тяжелый вызов
+	Threads    []SummaryItem `json:"threads"`
+	Categories []SummaryItem `json:"categories"` // GC, JIT compiler, Native, Java
+}
+
+// Категории сэмплов определяются эвристически по потоку и фреймам HotSpot
+var (
+	summaryGCPattern       = regexp.MustCompile(`(?i)\b(gc|g1|zgc|shenandoah)\b|GC Thread|G1 |GCTask|CollectedHeap|ParallelGC|PSScavenge|PSParallelCompact|ConcurrentMark|ZDriver|ZWorker|Shenandoah`)
+	summaryCompilerPattern = regexp.MustCompile(`CompilerThread|CompileBroker::|C2Compiler|Compiler::compile|Compilation::|PhaseIdealLoop|PhaseChaitin`)
+)
+
+const (
+	summaryCategoryGC       = "GC"
+	summaryCategoryCompiler = "JIT compiler"
+	summaryCategoryNative   = "Native"
+	summaryCategoryJava     = "Java"
+)
+
+// summaryCategory категория стека: GC и компиляция по потоку или фрейму, native - по листовому фрейму
+func summaryCategory(thread string, frames []Frame) string {
+	if summaryGCPattern.MatchString(thread) {
+		return summaryCategoryGC
+	}
+	if summaryCompilerPattern.MatchString(thread) {
+		return summaryCategoryCompiler
+	}
+	for _, f := range frames {
+		if f.Type != FrameCpp && f.Type != FrameNative {
+			continue
+		}
+		if summaryGCPattern.MatchString(f.Name) {
+			return summaryCategoryGC
+		}
+		if summaryCompilerPattern.MatchString(f.Name) {
+			return summaryCategoryCompiler
+		}
+	}
+	if len(frames) > 0 {
+		switch frames[len(frames)-1].Type {
+		case FrameNative, FrameCpp, FrameKernel:
+			return summaryCategoryNative
+		}
+	}
+	return summaryCategoryJava
+}
+
+// SummarizeProfile строит сводку по профилю, прочитанному с корневым фреймом потока (Threads)
+func SummarizeProfile(p *Profile, topN int) *RecordingSummary {
+	s := &RecordingSummary{Event: p.Event}
+	if p.EndNanos > p.StartNanos && p.StartNanos != 0 {
+		s.Duration = time.Duration(p.EndNanos - p.StartNanos)
+	}
+
+	self := map[string]int64{}
+	total := map[string]int64{}
+	threads := map[string]int64{}
+	categories := map[string]int64{}
+	var stacks [][]Frame
+	var counts []int64
+	for _, stack := range p.Stacks {
+		if len(stack.Frames) == 0 {
+			continue
+		}
+		thread, frames := stack.Frames[0].Name, stack.Frames[1:]
+		s.Samples += stack.Samples
+		threads[thread] += stack.Samples
+		categories[summaryCategory(thread, frames)] += stack.Samples
+		if len(frames) == 0 {
+			continue
+		}
+		self[frames[len(frames)-1].Name] += stack.Samples
+		// Рекурсивный метод учитывается в total один раз на стек
+		seen := map[string]bool{}
+		for _, f := range frames {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				total[f.Name] += stack.Samples
+			}
+		}
+		stacks = append(stacks, frames)
+		counts = append(counts, stack.Samples)
+	}
+
+	s.TopSelf = s.topItems(self, topN)
+	s.TopTotal = s.topItems(total, topN)
+	s.Threads = s.topItems(threads, topN)
+	for _, name := range []string{summaryCategoryGC, summaryCategoryCompiler, summaryCategoryNative, summaryCategoryJava} {
+		s.Categories = append(s.Categories, s.item(name, categories[name]))
+	}
+	s.HotPath = s.hotPath(stacks, counts)
+	return s
+}
+
+func (s *RecordingSummary) item(name string, samples int64) SummaryItem {
+	item := SummaryItem{Name: name, Samples: samples}
+	if s.Samples > 0 {
+		item.Share = float64(samples) / float64(s.Samples)
+	}
+	return item
+}
+
+// topItems n записей с наибольшим числом сэмплов; при равенстве - по имени
+func (s *RecordingSummary) topItems(values map[string]int64, n int) []SummaryItem {
+	items := make([]SummaryItem, 0, len(values))
+	for name, samples := range values {
+		items = append(items, s.item(name, samples))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Samples != items[j].Samples {
+			return items[i].Samples > items[j].Samples
+		}
+		return items[i].Name < items[j].Name
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// hotPath спуск от корня: на каждой глубине выбирается фрейм с наибольшим числом сэмплов среди стеков с выбранным префиксом.
+// Спуск останавливается, когда собственное время фрейма больше, чем у самого тяжелого вызова
+func (s *RecordingSummary) hotPath(stacks [][]Frame, counts []int64) []SummaryItem {
+	var path []SummaryItem
+	selected := make([]int, len(stacks))
+	for i := range selected {
+		selected[i] = i
+	}
+	for depth := 0; len(selected) > 0; depth++ {
+		sums := map[string]int64{}
+		var ended int64 // self предыдущего фрейма пути
+		for _, i := range selected {
+			if depth < len(stacks[i]) {
+				sums[stacks[i][depth].Name] += counts[i]
+			} else {
+				ended += counts[i]
+			}
+		}
+		if len(sums) == 0 {
+			break
+		}
+		best := s.topItems(sums, 1)[0]
+		if ended > best.Samples {
+			break // время тратится в самом фрейме, а не в вызовах
+		}
+		path = append(path, best)
+		next := selected[:0]
+		for _, i := range selected {
+			if depth < len(stacks[i]) && stacks[i][depth].Name == best.Name {
+				next = append(next, i)
+			}
+		}
+		selected = next
+	}
+	return path
+}
+
+// formatPercent доля в процентах: "12.3%"
+func formatPercent(share float64) string {
+	return fmt.Sprintf("%.1f%%", 100*share)
+}
+
+// Text короткая сводка для окна результата
+func (s *RecordingSummary) Text() string {
+	if s.Samples == 0 {
+		return "Summary: no samples"
+	}
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Summary: %d %s samples", s.Samples, s.Event))
+	var top []string
+	for i, item := range s.TopSelf {
+		if i == 3 {
+			break
+		}
+		top = append(top, fmt.Sprintf("%s %s", shortMethodName(item.Name), formatPercent(item.Share)))
+	}
+	lines = append(lines, "Top self: "+strings.Join(top, ", "))
+	if n := len(s.HotPath); n > 0 {
+		leaf := s.HotPath[n-1]
+		lines = append(lines, fmt.Sprintf("Hot path (%d frames) ends in %s %s", n, shortMethodName(leaf.Name), formatPercent(leaf.Share)))
+	}
+	var categories []string
+	for _, c := range s.Categories {
+		categories = append(categories, c.Name+" "+formatPercent(c.Share))
+	}
+	lines = append(lines, strings.Join(categories, " | "))
+	return strings.Join(lines, "\n")
+}
+
+// shortMethodName имя метода без пакета: com.acme.Work.compute -> Work.compute
+func shortMethodName(name string) string {
+	parts := strings.Split(name, ".")
+	if len(parts) <= 2 {
+		return name
+	}
+	return strings.Join(parts[len(parts)-2:], ".")
+}
+
+// WriteMarkdown пишет сводку в markdown
+func (s *RecordingSummary) WriteMarkdown(w io.Writer, title string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n", title)
+	fmt.Fprintf(bw, "- Event: %s\n", s.Event)
+	fmt.Fprintf(bw, "- Samples: %d\n", s.Samples)
+	if d := s.Duration.Round(time.Millisecond); d > 0 {
+		fmt.Fprintf(bw, "- Duration: %s\n", d)
+	}
+
+	table := func(heading string, items []SummaryItem) {
+		fmt.Fprintf(bw, "\n## %s\n\n", heading)
+		if len(items) == 0 {
+			fmt.Fprintln(bw, "No samples.")
+			return
+		}
+		fmt.Fprintln(bw, "| # | Name | Samples | Share |")
+		fmt.Fprintln(bw, "|---|------|--------:|------:|")
+		for i, item := range items {
+			name := strings.ReplaceAll(item.Name, "|", `\|`)
+			fmt.Fprintf(bw, "| %d | `%s` | %d | %s |\n", i+1, name, item.Samples, formatPercent(item.Share))
+		}
+	}
+	table("Categories", s.Categories)
+	table(fmt.Sprintf("Top %d methods by self time", summaryTopN), s.TopSelf)
+	table(fmt.Sprintf("Top %d methods by total time", summaryTopN), s.TopTotal)
+	table("Hottest stack path (root to leaf)", s.HotPath)
+	table("Threads", s.Threads)
+	return bw.Flush()
+}
+
+// summarizeFile читает запись и строит сводку по первому найденному или выбранному виду событий
+func summarizeFile(ctx context.Context, path string, opts ConvertOptions) (*RecordingSummary, error) {
+	readOpts, err := opts.readOptions(opts.events()[0])
+	if err != nil {
+		return nil, err
+	}
+	readOpts.Threads = true
+	profile, err := ReadJFRProfile(ctx, path, readOpts)
+	if err != nil {
+		return nil, err
+	}
+	return SummarizeProfile(profile, summaryTopN), nil
+}
+
+// writeSummaryFiles пишет сводку в markdown и JSON рядом с outputBase и возвращает пути
+func writeSummaryFiles(s *RecordingSummary, outputBase, title string) ([]string, error) {
+	mdPath := outputBase + summaryMarkdownSuffix
+	if err := writeFileAtomically(mdPath, func(file *os.File) error {
+		return s.WriteMarkdown(file, title)
+	}); err != nil {
+		return nil, err
+	}
+	jsonPath := outputBase + summaryJSONSuffix
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return []string{mdPath}, err
+	}
+	if err := writeFileAtomically(jsonPath, func(file *os.File) error {
+		_, err := file.Write(append(data, '\n'))
+		return err
+	}); err != nil {
+		return []string{mdPath}, err
+	}
+	return []string{mdPath, jsonPath}, nil
+}
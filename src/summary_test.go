@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSummaryCategory(t *testing.T) {
+	java := Frame{Name: "com.acme.Work.compute", Type: FrameJIT}
+	tests := []struct {
+		name   string
+		thread string
+		frames []Frame
+		want   string
+	}{
+		{"GC thread", "[GC Thread#0]", []Frame{{Name: "GCTaskThread::run", Type: FrameCpp}}, summaryCategoryGC},
+		{"G1 thread", "[G1 Conc#0]", []Frame{{Name: "ConcurrentGCThread::run", Type: FrameCpp}}, summaryCategoryGC},
+		{"compiler thread", "[C2 CompilerThread0]", []Frame{{Name: "C2Compiler::compile_method", Type: FrameCpp}}, summaryCategoryCompiler},
+		{"allocation slow path", "[main tid=1]", []Frame{java, {Name: "G1CollectedHeap::mem_allocate", Type: FrameCpp}, {Name: "memset", Type: FrameNative}}, summaryCategoryGC},
+		{"Java method named like GC", "[main tid=1]", []Frame{{Name: "com.acme.GcStats.collect", Type: FrameJIT}}, summaryCategoryJava},
+		{"system call", "[main tid=1]", []Frame{java, {Name: "java.io.FileOutputStream.writeBytes", Type: FrameJIT}, {Name: "write", Type: FrameKernel}}, summaryCategoryNative},
+		{"VM thread", "[VM Thread]", []Frame{{Name: "VMThread::run", Type: FrameCpp}}, summaryCategoryNative},
+		{"Java", "[main tid=1]", []Frame{java}, summaryCategoryJava},
+		{"thread frame only", "[main tid=1]", nil, summaryCategoryJava},
+	}
+	for _, tt := range tests {
+		if got := summaryCategory(tt.thread, tt.frames); got != tt.want {
+			t.Errorf("%s: summaryCategory = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// summaryLines записи сводки в виде "имя сэмплы доля%"
+func summaryLines(items []SummaryItem) []string {
+	var lines []string
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("%s %d %s", item.Name, item.Samples, formatPercent(item.Share)))
+	}
+	return lines
+}
+
+func TestSummarizeProfile(t *testing.T) {
+	p, err := ReadCollapsedProfile(filepath.Join("testdata", "cpu.collapsed"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Event = "cpu"
+	s := SummarizeProfile(p, 3)
+
+	if s.Event != "cpu" || s.Samples != 102 || s.Duration != 0 {
+		t.Fatalf("event/samples/duration = %s/%d/%v, want cpu/102/0", s.Event, s.Samples, s.Duration)
+	}
+	tests := []struct {
+		name  string
+		items []SummaryItem
+		want  []string
+	}{
+		{"top self", s.TopSelf, []string{"java.lang.String.charAt 45 44.1%", "com.acme.Parser.parse 17 16.7%", "write 12 11.8%"}},
+		// При равенстве - по имени
+		{"top total", s.TopTotal, []string{"com.acme.Server.handle 83 81.4%", "java.lang.Thread.run 83 81.4%", "com.acme.Parser.parse 62 60.8%"}},
+		{"threads", s.Threads, []string{"[main tid=1] 83 81.4%", "[GC Thread#0] 8 7.8%", `[worker "a&b" <1>] 6 5.9%`}},
+		{"categories", s.Categories, []string{"GC 8 7.8%", "JIT compiler 5 4.9%", "Native 12 11.8%", "Java 77 75.5%"}},
+		{"hot path", s.HotPath, []string{
+			"java.lang.Thread.run 83 81.4%",
+			"com.acme.Server.handle 83 81.4%",
+			"com.acme.Parser.parse 62 60.8%",
+			"java.lang.String.charAt 45 44.1%",
+		}},
+	}
+	for _, tt := range tests {
+		if got := summaryLines(tt.items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s =\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+
+	want := "Summary: 102 cpu samples\n" +
+		"Top self: String.charAt 44.1%, Parser.parse 16.7%, write 11.8%\n" +
+		"Hot path (4 frames) ends in String.charAt 44.1%\n" +
+		"GC 7.8% | JIT compiler 4.9% | Native 11.8% | Java 75.5%"
+	if got := s.Text(); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSummaryHotPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			// Собственное время a больше, чем у самого тяжелого вызова: путь заканчивается на a
+			name:  "stops at heavy self time",
+			lines: []string{"[main];a 10", "[main];a;b 4", "[main];a;c 3"},
+			want:  []string{"a 17 100.0%"},
+		},
+		{
+			name:  "follows the heaviest callee",
+			lines: []string{"[main];a;b;x 5", "[main];a;c 6", "[main];a;b;y 4", "[worker];a;b 1"},
+			want:  []string{"a 16 100.0%", "b 10 62.5%", "x 5 31.2%"},
+		},
+		{
+			name:  "thread frames only",
+			lines: []string{"[main] 3"},
+		},
+	}
+	for _, tt := range tests {
+		s := SummarizeProfile(collapsedProfile("cpu", "samples", tt.lines...), summaryTopN)
+		if got := summaryLines(s.HotPath); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: hot path = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := SummarizeProfile(&Profile{Event: "wall"}, summaryTopN).Text(); got != "Summary: no samples" {
+		t.Errorf("empty profile: Text() = %q", got)
+	}
+}
@@ -3,9 +3,24 @@
 
 package main
 
-import "os/exec"
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
 
 // setSysProcAttr для non-Windows платформ - ничего не делает
 func setSysProcAttr(cmd *exec.Cmd) {
 	// На Unix-подобных системах нет необходимости скрывать окна
+}
+
+// processAlive работает ли процесс с данным PID: сигнал 0 только проверяет, что процесс существует
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
 }
\ No newline at end of file
@@ -14,4 +14,24 @@ func setSysProcAttr(cmd *exec.Cmd) {
 		HideWindow:    true,
 		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
 	}
+}
+
+const (
+	processQueryLimitedInformation = 0x1000
+	processStillActive             = 259 // STILL_ACTIVE
+)
+
+// processAlive работает ли процесс с данным PID
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Процесс есть, но открыть его нельзя (например, другой пользователь)
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == processStillActive
 }
\ No newline at end of file
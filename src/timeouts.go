@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// OperationTimeouts таймауты операций с кластером и политика повторов.
+// Хранятся в ~/.k8s-jprof/timeouts.json; файл с значениями по умолчанию создается при первом запуске.
+type OperationTimeouts struct {
+	List       time.Duration // get namespaces/pods, auth can-i
+	Exec       time.Duration // короткие команды в поде: test, tar, rm; запас на запуск и остановку asprof
+	Copy       time.Duration // kubectl cp в одну сторону
+	Conversion time.Duration // конвертация JFR
+	Retries    int           // повторы идемпотентных операций при сетевых ошибках и таймаутах
+}
+
+// timeoutsFile формат timeouts.json: длительности в виде "15s", "2m"
+type timeoutsFile struct {
+	List       string `json:"list"`
+	Exec       string `json:"exec"`
+	Copy       string `json:"copy"`
+	Conversion string `json:"conversion"`
+	Retries    *int   `json:"retries"`
+}
+
+// Паузы между повторами; переменные, чтобы тесты не ждали секундами
+var (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 10 * time.Second
+)
+
+func defaultOperationTimeouts() OperationTimeouts {
+	return OperationTimeouts{
+		List:       kubectlTimeout,
+		Exec:       time.Minute,
+		Copy:       5 * time.Minute,
+		Conversion: 5 * time.Minute,
+		Retries:    3,
+	}
+}
+
+func getTimeoutsFilePath() string {
+	return filepath.Join(getConfigDir(), "timeouts.json")
+}
+
+// loadOperationTimeouts читает timeouts.json. Некорректные значения заменяются значениями по умолчанию
+func loadOperationTimeouts() OperationTimeouts {
+	t := defaultOperationTimeouts()
+	path := getTimeoutsFilePath()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Создаем файл, чтобы значения было видно и можно было поправить
+		if err := writeOperationTimeouts(path, t); err != nil {
+			log.Printf("Warning: failed to write %s: %v", path, err)
+		}
+		return t
+	}
+	if err != nil {
+		log.Printf("Warning: failed to read %s: %v", path, err)
+		return t
+	}
+
+	var file timeoutsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("Warning: invalid %s: %v", path, err)
+		return t
+	}
+
+	parse := func(name, value string, target *time.Duration) {
+		if value == "" {
+			return
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			log.Printf("Warning: invalid %s timeout %q in %s", name, value, path)
+			return
+		}
+		*target = d
+	}
+	parse("list", file.List, &t.List)
+	parse("exec", file.Exec, &t.Exec)
+	parse("copy", file.Copy, &t.Copy)
+	parse("conversion", file.Conversion, &t.Conversion)
+	if file.Retries != nil && *file.Retries >= 0 {
+		t.Retries = *file.Retries
+	}
+	return t
+}
+
+func writeOperationTimeouts(path string, t OperationTimeouts) error {
+	retries := t.Retries
+	data, err := json.MarshalIndent(timeoutsFile{
+		List:       t.List.String(),
+		Exec:       t.Exec.String(),
+		Copy:       t.Copy.String(),
+		Conversion: t.Conversion.String(),
+		Retries:    &retries,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// SessionTimeout общий лимит записи: сама запись плюс все шаги вокруг нее с учетом повторов
+func (t OperationTimeouts) SessionTimeout(recording time.Duration) time.Duration {
+	attempts := time.Duration(t.Retries + 1)
+	return recording + t.Exec + // запуск asprof
+		attempts*(3*t.Exec+2*t.Copy) + // test, tar, rm и копирование в обе стороны
+		2*t.List + // метаданные: контекст kubeconfig и описание пода
+		t.Conversion + // конвертации идут параллельно, у каждой свой таймаут
+		t.Conversion // сводка по записи
+}
+
+// isRetryable можно ли повторить операцию после ошибки: только сетевые сбои и таймауты
+func isRetryable(err error) bool {
+	var clusterErr *ClusterError
+	if !errors.As(err, &clusterErr) {
+		return false
+	}
+	return clusterErr.Kind == clusterErrorUnreachable || clusterErr.Kind == clusterErrorTimeout
+}
+
+// retryDelay пауза после неудачной попытки attempt (с нуля): удваивается, но не больше retryMaxDelay
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 0; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// withRetry выполняет идемпотентную операцию с таймаутом на попытку и экспоненциальной паузой между попытками
+func withRetry(ctx context.Context, retries int, timeout time.Duration, op func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := op(attemptCtx)
+		cancel()
+
+		if err == nil || attempt >= retries || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+		delay := retryDelay(attempt)
+		log.Printf("Попытка %d не удалась, повтор через %v: %v", attempt+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fastRetries сокращает паузы между повторами на время теста
+func fastRetries(t *testing.T) {
+	t.Helper()
+	base, maxDelay := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = base, maxDelay })
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unreachable", &ClusterError{Kind: clusterErrorUnreachable}, true},
+		{"timeout", &ClusterError{Kind: clusterErrorTimeout}, true},
+		{"wrapped timeout", fmt.Errorf("copying JFR: %w", &ClusterError{Kind: clusterErrorTimeout}), true},
+		{"forbidden", &ClusterError{Kind: clusterErrorForbidden}, false},
+		{"not found", &ClusterError{Kind: clusterErrorNotFound}, false},
+		{"expired credentials", &ClusterError{Kind: clusterErrorAuthExpired}, false},
+		{"unknown cluster error", &ClusterError{Kind: clusterErrorUnknown}, false},
+		{"plain error", errors.New("connection refused"), false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for attempt, delay := range want {
+		if got := retryDelay(attempt); got != delay {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempt, got, delay)
+		}
+	}
+	// Удвоение не переполняется на больших номерах попыток
+	if got := retryDelay(100); got != retryMaxDelay {
+		t.Errorf("retryDelay(100) = %v, want %v", got, retryMaxDelay)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	fastRetries(t)
+	unreachable := &ClusterError{Kind: clusterErrorUnreachable}
+	timeout := &ClusterError{Kind: clusterErrorTimeout}
+	forbidden := &ClusterError{Kind: clusterErrorForbidden}
+
+	tests := []struct {
+		name      string
+		retries   int
+		results   []error // ошибка каждой попытки; последняя повторяется
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", retries: 3, results: []error{nil}, wantCalls: 1},
+		{name: "recovers after network errors", retries: 3, results: []error{unreachable, timeout, nil}, wantCalls: 3},
+		{name: "gives up after retries", retries: 2, results: []error{unreachable}, wantCalls: 3, wantErr: unreachable},
+		{name: "retries disabled", retries: 0, results: []error{timeout}, wantCalls: 1, wantErr: timeout},
+		{name: "not retryable", retries: 3, results: []error{forbidden}, wantCalls: 1, wantErr: forbidden},
+		{name: "stops at first permanent error", retries: 3, results: []error{unreachable, forbidden}, wantCalls: 2, wantErr: forbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := withRetry(context.Background(), tt.retries, time.Minute, func(ctx context.Context) error {
+				if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+					t.Errorf("attempt %d: deadline %v, want at most a minute from now", calls+1, deadline)
+				}
+				result := tt.results[len(tt.results)-1]
+				if calls < len(tt.results) {
+					result = tt.results[calls]
+				}
+				calls++
+				return result
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsOnCancel(t *testing.T) {
+	unreachable := &ClusterError{Kind: clusterErrorUnreachable}
+
+	// Контекст отменен во время попытки: повтора нет
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := withRetry(ctx, 3, time.Minute, func(context.Context) error {
+		calls++
+		cancel()
+		return unreachable
+	})
+	if calls != 1 || err != unreachable {
+		t.Errorf("canceled during attempt: calls = %d, error = %v, want 1, %v", calls, err, unreachable)
+	}
+
+	// Контекст отменен во время паузы: ожидание прерывается сразу
+	fastRetries(t)
+	retryBaseDelay, retryMaxDelay = time.Hour, time.Hour
+	ctx, cancel = context.WithCancel(context.Background())
+	calls = 0
+	start := time.Now()
+	err = withRetry(ctx, 3, time.Minute, func(context.Context) error {
+		calls++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return unreachable
+	})
+	if calls != 1 || err != unreachable {
+		t.Errorf("canceled during pause: calls = %d, error = %v, want 1, %v", calls, err, unreachable)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("canceled during pause: waited %v", elapsed)
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	timeouts := OperationTimeouts{
+		List:       time.Second,
+		Exec:       10 * time.Second,
+		Copy:       100 * time.Second,
+		Conversion: 1000 * time.Second,
+		Retries:    2,
+	}
+	// Запись + запуск asprof + 3 попытки (3 exec и 2 копирования) + 2 list + конвертация + сводка
+	want := time.Hour + 10*time.Second + 3*(30+200)*time.Second + 2*time.Second + 2000*time.Second
+	if got := timeouts.SessionTimeout(time.Hour); got != want {
+		t.Errorf("SessionTimeout(1h) = %v, want %v", got, want)
+	}
+
+	timeouts.Retries = 0
+	want = 10*time.Minute + 10*time.Second + (30+200)*time.Second + 2*time.Second + 2000*time.Second
+	if got := timeouts.SessionTimeout(10 * time.Minute); got != want {
+		t.Errorf("SessionTimeout(10m) without retries = %v, want %v", got, want)
+	}
+}
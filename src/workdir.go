@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Рабочие папки без PID владельца старше этого считаются оставшимися после аварийного завершения
+const staleWorkDirAge = time.Hour
+
+// workDirOwnerFile файл с PID процесса, которому принадлежит рабочая папка записи.
+// Запись может идти дольше staleWorkDirAge, поэтому папку работающего экземпляра не удаляем независимо от возраста
+const workDirOwnerFile = "owner.pid"
+
+// getWorkDir возвращает папку для временных файлов записей
+func getWorkDir() string {
+	return filepath.Join(getConfigDir(), "work")
+}
+
+// getLegacyTmpDir папка, в которую старые версии копировали kubeconfig
+func getLegacyTmpDir() string {
+	return filepath.Join(getConfigDir(), "tmp")
+}
+
+// createRecordingWorkDir создает приватную (0700) папку для временных файлов одной записи
+func createRecordingWorkDir() (string, error) {
+	if err := os.MkdirAll(getWorkDir(), 0700); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(getWorkDir(), "rec-")
+	if err != nil {
+		return "", err
+	}
+	// MkdirTemp уже создает 0700, но явно фиксируем права на случай нестандартного umask
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, workDirOwnerFile), []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// workDirAbandoned брошена ли рабочая папка: ее процесс завершился, а если PID не записан - по возрасту папки
+func workDirAbandoned(dir string, modTime time.Time) bool {
+	data, err := os.ReadFile(filepath.Join(dir, workDirOwnerFile))
+	if err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 {
+			return pid != os.Getpid() && !processAlive(pid)
+		}
+	}
+	return time.Since(modTime) >= staleWorkDirAge
+}
+
+// cleanupStaleTempFiles удаляет временные файлы, оставшиеся после аварийного завершения:
+// копию kubeconfig старых версий и брошенные рабочие папки записей
+func cleanupStaleTempFiles() {
+	if err := os.RemoveAll(getLegacyTmpDir()); err != nil {
+		log.Printf("Warning: failed to remove legacy temp directory: %v", err)
+	}
+
+	entries, err := os.ReadDir(getWorkDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		dir := filepath.Join(getWorkDir(), entry.Name())
+		if err != nil || !workDirAbandoned(dir, info.ModTime()) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Warning: failed to remove stale work directory %s: %v", entry.Name(), err)
+		}
+	}
+}
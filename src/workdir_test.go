@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// tempHome подменяет домашнюю папку, чтобы настройки и рабочие папки теста не попали в настоящую ~/.k8s-jprof
+func tempHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	return home
+}
+
+func TestCreateRecordingWorkDir(t *testing.T) {
+	tempHome(t)
+	dir, err := createRecordingWorkDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(dir) != getWorkDir() {
+		t.Errorf("work dir %s is not inside %s", dir, getWorkDir())
+	}
+	if info, err := os.Stat(dir); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0700 {
+		t.Errorf("work dir permissions = %v, want 0700", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, workDirOwnerFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("owner = %q, want %d", data, os.Getpid())
+	}
+}
+
+func TestCleanupStaleTempFiles(t *testing.T) {
+	tempHome(t)
+	old := time.Now().Add(-2 * staleWorkDirAge)
+	workDir := func(name, owner string, modTime time.Time) {
+		t.Helper()
+		dir := filepath.Join(getWorkDir(), name)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if owner != "" {
+			if err := os.WriteFile(filepath.Join(dir, workDirOwnerFile), []byte(owner), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Запись этого процесса идет дольше staleWorkDirAge
+	workDir("rec-running", strconv.Itoa(os.Getpid()), old)
+	workDir("rec-dead-owner", strconv.Itoa(math.MaxInt32), time.Now())
+	workDir("rec-old-without-owner", "", old)
+	workDir("rec-fresh-without-owner", "", time.Now())
+	workDir("rec-old-broken-owner", "not a pid", old)
+	if err := os.MkdirAll(getLegacyTmpDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanupStaleTempFiles()
+
+	want := map[string]bool{
+		"rec-running":             true,
+		"rec-dead-owner":          false,
+		"rec-old-without-owner":   false,
+		"rec-fresh-without-owner": true,
+		"rec-old-broken-owner":    false,
+	}
+	for name, kept := range want {
+		_, err := os.Stat(filepath.Join(getWorkDir(), name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s: exists = %v, want %v", name, exists, kept)
+		}
+	}
+	if _, err := os.Stat(getLegacyTmpDir()); !os.IsNotExist(err) {
+		t.Errorf("legacy temp dir was not removed: %v", err)
+	}
+}